@@ -0,0 +1,90 @@
+// Package auth provides a wrapper which authenticates and authorises api requests
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/micro/go-micro/v3/api/resolver"
+	"github.com/micro/go-micro/v3/api/server"
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/errors"
+	utilhttp "github.com/micro/go-micro/v3/util/http"
+)
+
+// Token returns the token or API key provided with the request, either using the
+// X-Api-Key header or the bearer scheme
+func Token(r *http.Request) string {
+	if key := r.Header.Get(auth.KeyHeader); len(key) > 0 {
+		return key
+	}
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, auth.BearerScheme) {
+		return strings.TrimPrefix(h, auth.BearerScheme)
+	}
+	return ""
+}
+
+// Wrapper returns a server wrapper which inspects the token or API key provided with each
+// request and verifies the account has access to the endpoint resolved by the resolver.
+// The account is added to the request context.
+func Wrapper(a auth.Auth, r resolver.Resolver) server.Wrapper {
+	return func(h http.Handler) http.Handler {
+		return &authHandler{auth: a, resolver: r, handler: h}
+	}
+}
+
+type authHandler struct {
+	auth     auth.Auth
+	resolver resolver.Resolver
+	handler  http.Handler
+}
+
+func (a *authHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var acc *auth.Account
+	if token := Token(req); len(token) > 0 {
+		var err error
+		if acc, err = a.auth.Inspect(token); err != nil {
+			writeError(w, errors.Unauthorized("go.micro.api", "Invalid token or API key"))
+			return
+		}
+		req = req.WithContext(auth.ContextWithAccount(req.Context(), acc))
+	}
+
+	// requests which can't be resolved can't be authorised so they're rejected
+	endpoint, err := a.resolver.Resolve(req)
+	switch {
+	case err == nil:
+	case acc == nil:
+		writeError(w, errors.Unauthorized("go.micro.api", "Unauthorized call to %v", req.URL.Path))
+		return
+	case err == resolver.ErrNotFound || err == resolver.ErrInvalidPath:
+		writeError(w, errors.Forbidden("go.micro.api", "Forbidden call to %v", req.URL.Path))
+		return
+	default:
+		writeError(w, errors.InternalServerError("go.micro.api", "Error resolving %v: %v", req.URL.Path, err))
+		return
+	}
+
+	res := &auth.Resource{Type: "service", Name: endpoint.Name, Endpoint: endpoint.Path}
+	err = a.auth.Verify(acc, res,
+		auth.VerifyNamespace(endpoint.Domain),
+		auth.VerifyContext(utilhttp.RequestToContext(req)),
+	)
+	switch {
+	case err == nil:
+		a.handler.ServeHTTP(w, req)
+	case err != auth.ErrForbidden:
+		writeError(w, errors.InternalServerError("go.micro.api", "Error verifying access to %v: %v", endpoint.Name, err))
+	case acc == nil:
+		writeError(w, errors.Unauthorized("go.micro.api", "Unauthorized call to %v", endpoint.Name))
+	default:
+		writeError(w, errors.Forbidden("go.micro.api", "Forbidden call to %v", endpoint.Name))
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	ce := errors.Parse(err.Error())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(ce.Code))
+	w.Write([]byte(ce.Error()))
+}
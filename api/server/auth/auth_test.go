@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v3/api/resolver"
+	"github.com/micro/go-micro/v3/api/resolver/path"
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/auth/jwt"
+	"github.com/micro/go-micro/v3/auth/noop"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+func TestWrapper(t *testing.T) {
+	pubKey, err := ioutil.ReadFile("../../../util/token/jwt/test/sample_key.pub")
+	if err != nil {
+		t.Fatalf("Unable to read public key: %v", err)
+	}
+	privKey, err := ioutil.ReadFile("../../../util/token/jwt/test/sample_key")
+	if err != nil {
+		t.Fatalf("Unable to read private key: %v", err)
+	}
+
+	a := jwt.NewAuth(
+		auth.Store(memory.NewStore()),
+		auth.PublicKey(string(pubKey)),
+		auth.PrivateKey(string(privKey)),
+	)
+	if err := a.Grant(&auth.Rule{
+		ID:       "admin",
+		Scope:    "admin",
+		Access:   auth.AccessGranted,
+		Resource: &auth.Resource{Type: "service", Name: "go.micro.api.foo", Endpoint: "*"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	acc, err := a.Generate("john", auth.WithScopes("admin", "user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := a.(auth.KeyManager).GenerateKey(acc, auth.WithKeyScopes("admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := a.(auth.KeyManager).GenerateKey(acc, auth.WithKeyScopes("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var account *auth.Account
+	h := Wrapper(a, path.NewResolver(resolver.WithServicePrefix("go.micro.api")))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account, _ = auth.AccountFromContext(r.Context())
+	}))
+
+	tt := []struct {
+		Name   string
+		Header string
+		Value  string
+		Code   int
+	}{
+		{Name: "NoToken", Code: http.StatusUnauthorized},
+		{Name: "InvalidKey", Header: auth.KeyHeader, Value: auth.KeyPrefix + "foo.bar", Code: http.StatusUnauthorized},
+		{Name: "Token", Header: "Authorization", Value: auth.BearerScheme + tok.AccessToken, Code: http.StatusOK},
+		{Name: "Key", Header: auth.KeyHeader, Value: admin.Token, Code: http.StatusOK},
+		{Name: "BearerKey", Header: "Authorization", Value: auth.BearerScheme + admin.Token, Code: http.StatusOK},
+		{Name: "KeyScopes", Header: auth.KeyHeader, Value: user.Token, Code: http.StatusForbidden},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			account = nil
+
+			req := httptest.NewRequest("GET", "/foo/bar", nil)
+			if len(tc.Header) > 0 {
+				req.Header.Set(tc.Header, tc.Value)
+			}
+			rsp := httptest.NewRecorder()
+			h.ServeHTTP(rsp, req)
+
+			if rsp.Code != tc.Code {
+				t.Fatalf("Expected status %v but got %v", tc.Code, rsp.Code)
+			}
+			if tc.Code == http.StatusOK && (account == nil || account.ID != "john") {
+				t.Errorf("Expected the account to be set in the context, got %v", account)
+			}
+		})
+	}
+}
+
+// failingAuth returns an error other than forbidden when verifying access
+type failingAuth struct {
+	auth.Auth
+}
+
+func (failingAuth) Verify(acc *auth.Account, res *auth.Resource, opts ...auth.VerifyOption) error {
+	return errors.New("store unavailable")
+}
+
+func TestWrapperVerifyError(t *testing.T) {
+	h := Wrapper(failingAuth{noop.NewAuth()}, path.NewResolver(resolver.WithServicePrefix("go.micro.api")))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the handler not to be called")
+	}))
+
+	req := httptest.NewRequest("GET", "/foo/bar", nil)
+	rsp := httptest.NewRecorder()
+	h.ServeHTTP(rsp, req)
+
+	if rsp.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %v but got %v", http.StatusInternalServerError, rsp.Code)
+	}
+}
+
+// failingResolver fails to resolve every request
+type failingResolver struct {
+	err error
+}
+
+func (f failingResolver) Resolve(r *http.Request, opts ...resolver.ResolveOption) (*resolver.Endpoint, error) {
+	return nil, f.err
+}
+
+func (failingResolver) String() string {
+	return "failing"
+}
+
+func TestWrapperResolveError(t *testing.T) {
+	// every token is valid with noop
+	a := noop.NewAuth()
+	tok := &auth.Token{AccessToken: "token"}
+
+	tt := []struct {
+		Name  string
+		Err   error
+		Token string
+		Code  int
+	}{
+		{Name: "NoAccount", Err: resolver.ErrNotFound, Code: http.StatusUnauthorized},
+		{Name: "NotFound", Err: resolver.ErrNotFound, Token: tok.AccessToken, Code: http.StatusForbidden},
+		{Name: "InvalidPath", Err: resolver.ErrInvalidPath, Token: tok.AccessToken, Code: http.StatusForbidden},
+		{Name: "Error", Err: errors.New("registry unavailable"), Token: tok.AccessToken, Code: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			h := Wrapper(a, failingResolver{tc.Err})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("Expected the handler not to be called")
+			}))
+
+			req := httptest.NewRequest("GET", "/foo/bar", nil)
+			if len(tc.Token) > 0 {
+				req.Header.Set("Authorization", auth.BearerScheme+tc.Token)
+			}
+			rsp := httptest.NewRecorder()
+			h.ServeHTTP(rsp, req)
+
+			if rsp.Code != tc.Code {
+				t.Fatalf("Expected status %v but got %v", tc.Code, rsp.Code)
+			}
+		})
+	}
+}
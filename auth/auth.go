@@ -10,12 +10,21 @@ import (
 const (
 	// BearerScheme used for Authorization header
 	BearerScheme = "Bearer "
+	// KeyHeader is the header used to provide an API key
+	KeyHeader = "X-Api-Key"
+	// KeyPrefix identifies an API key, so they can be provided wherever a token can
+	KeyPrefix = "key_"
 	// ScopePublic is the scope applied to a rule to allow access to the public
 	ScopePublic = ""
 	// ScopeAccount is the scope applied to a rule to limit to users with any valid account
 	ScopeAccount = "*"
 )
 
+var (
+	// DefaultNamespace rules apply to every namespace which doesn't have a rule of its own
+	DefaultNamespace = "micro"
+)
+
 var (
 	// ErrInvalidToken is when the token provided is not valid
 	ErrInvalidToken = errors.New("invalid token provided")
@@ -47,13 +56,31 @@ type Auth interface {
 	String() string
 }
 
+// Revoker is implemented by auth providers which can revoke tokens before they expire.
+// Check whether a provider supports revocation with a type assertion.
+type Revoker interface {
+	// RevokeTokens revokes the access and refresh tokens issued to an account before they expire
+	RevokeTokens(acc *Account, opts ...RevokeOption) error
+}
+
+// KeyManager is implemented by auth providers which can generate API keys.
+// Check whether a provider supports API keys with a type assertion.
+type KeyManager interface {
+	// GenerateKey generates a long lived API key for an account, which can be inspected like a token
+	GenerateKey(acc *Account, opts ...KeyOption) (*Key, error)
+	// RevokeKey revokes the API key with the id
+	RevokeKey(id string) error
+	// Keys returns the API keys generated for an account
+	Keys(acc *Account) ([]*Key, error)
+}
+
 // Account provided by an auth provider
 type Account struct {
 	// ID of the account e.g. email
 	ID string `json:"id"`
 	// Type of the account, e.g. service
 	Type string `json:"type"`
-	// Issuer of the account
+	// Issuer of the account, which is the namespace it belongs to
 	Issuer string `json:"issuer"`
 	// Any other associated metadata
 	Metadata map[string]string `json:"metadata"`
@@ -75,6 +102,26 @@ type Token struct {
 	Expiry time.Time `json:"expiry"`
 }
 
+// Key is a long lived API key for an account
+type Key struct {
+	// ID of the key, used to revoke it
+	ID string `json:"id"`
+	// Token is the API key itself, it's only returned when the key is generated
+	Token string `json:"token,omitempty"`
+	// Account the key was generated for
+	Account string `json:"account"`
+	// Issuer of the account
+	Issuer string `json:"issuer"`
+	// Scopes the key has access to, a subset of the account's scopes
+	Scopes []string `json:"scopes"`
+	// Time of key creation
+	Created time.Time `json:"created"`
+	// Time of key expiry, zero if the key doesn't expire
+	Expiry time.Time `json:"expiry"`
+	// LastUsed is when the key was last inspected
+	LastUsed time.Time `json:"last_used"`
+}
+
 // Expired returns a boolean indicating if the token needs to be refreshed
 func (t *Token) Expired() bool {
 	return t.Expiry.Unix() < time.Now().Unix()
@@ -114,6 +161,32 @@ type Rule struct {
 	// Priority the rule should take when verifying a request, the higher the value the sooner the
 	// rule will be applied
 	Priority int32
+	// Namespace the rule applies to, blank is the default namespace
+	Namespace string
+	// Condition which must be met for the rule to apply, if any
+	Condition *Condition
+}
+
+// Condition restricts when a rule applies. Every field which is set must match.
+type Condition struct {
+	// Metadata the account must have, e.g. team=payments
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Type of the account, e.g. service
+	Type string `json:"type,omitempty"`
+	// Request metadata from the context, e.g. Micro-From-Service=go.micro.service.foo
+	Request map[string]string `json:"request,omitempty"`
+	// Window of the day the rule applies in
+	Window *Window `json:"window,omitempty"`
+}
+
+// Window is a time of day, e.g. working hours. If End is before Start the window spans midnight.
+type Window struct {
+	// Start time in the format 15:04, inclusive
+	Start string `json:"start"`
+	// End time in the format 15:04, exclusive
+	End string `json:"end"`
+	// Timezone of the window, e.g. Europe/London. Defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
 }
 
 type accountKey struct{}
@@ -2,14 +2,22 @@
 package jwt
 
 import (
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/store"
 	"github.com/micro/go-micro/v3/util/token"
 	"github.com/micro/go-micro/v3/util/token/jwt"
 )
 
+var (
+	// ErrNoStore is returned when revoking tokens or managing API keys without a store
+	ErrNoStore = errors.New("a store is required to revoke tokens and manage API keys")
+)
+
 // NewAuth returns a new instance of the Auth service
 func NewAuth(opts ...auth.Option) auth.Auth {
 	j := new(jwtAuth)
@@ -21,6 +29,8 @@ type jwtAuth struct {
 	options auth.Options
 	token   token.Provider
 	rules   []*auth.Rule
+	// closed to stop refreshing the rules from the store
+	exit chan bool
 
 	sync.Mutex
 }
@@ -33,6 +43,7 @@ func (j *jwtAuth) Init(opts ...auth.Option) {
 	j.Lock()
 	defer j.Unlock()
 
+	st, interval := j.options.Store, refreshInterval(j.options)
 	for _, o := range opts {
 		o(&j.options)
 	}
@@ -41,6 +52,38 @@ func (j *jwtAuth) Init(opts ...auth.Option) {
 		token.WithPrivateKey(j.options.PrivateKey),
 		token.WithPublicKey(j.options.PublicKey),
 	)
+
+	// Init is called whenever the client token is refreshed, so the rules are
+	// only reloaded when the store or the interval to refresh them changes
+	if j.exit != nil && j.options.Store == st && refreshInterval(j.options) == interval {
+		return
+	}
+
+	// stop refreshing the rules from the previous store
+	if j.exit != nil {
+		close(j.exit)
+		j.exit = nil
+	}
+
+	// without a store the rules are only kept in memory
+	if j.options.Store == nil {
+		return
+	}
+
+	// watch before loading the rules so no changes are missed
+	w := watch(j.options.Store)
+
+	rules, err := readRules(j.options.Store)
+	if err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error loading auth rules: %v", err)
+		}
+	} else {
+		j.rules = rules
+	}
+
+	j.exit = make(chan bool)
+	go j.refresh(j.options.Store, w, refreshInterval(j.options), j.exit)
 }
 
 func (j *jwtAuth) Options() auth.Options {
@@ -71,6 +114,17 @@ func (j *jwtAuth) Generate(id string, opts ...auth.GenerateOption) (*auth.Accoun
 	}
 	account.Secret = secret.Token
 
+	// persist the account so it's shared with the other instances, recording the secret so
+	// it's revoked with the account's other tokens
+	if s := j.Options().Store; s != nil {
+		if err := writeAccount(s, account); err != nil {
+			return nil, err
+		}
+		if err := issue(s, account, secret.Token, secret.Expiry); err != nil {
+			return nil, err
+		}
+	}
+
 	// return the account
 	return account, nil
 }
@@ -78,7 +132,23 @@ func (j *jwtAuth) Generate(id string, opts ...auth.GenerateOption) (*auth.Accoun
 func (j *jwtAuth) Grant(rule *auth.Rule) error {
 	j.Lock()
 	defer j.Unlock()
-	j.rules = append(j.rules, rule)
+
+	if s := j.options.Store; s != nil {
+		if err := writeRule(s, rule); err != nil {
+			return err
+		}
+		return j.reload(s)
+	}
+
+	// replace any existing rule with the same id
+	rules := []*auth.Rule{}
+	for _, r := range j.rules {
+		if r.ID != rule.ID || namespace(r) != namespace(rule) {
+			rules = append(rules, r)
+		}
+	}
+
+	j.rules = append(rules, rule)
 	return nil
 }
 
@@ -86,9 +156,16 @@ func (j *jwtAuth) Revoke(rule *auth.Rule) error {
 	j.Lock()
 	defer j.Unlock()
 
+	if s := j.options.Store; s != nil {
+		if err := deleteRule(s, rule); err != nil {
+			return err
+		}
+		return j.reload(s)
+	}
+
 	rules := []*auth.Rule{}
 	for _, r := range j.rules {
-		if r.ID != rule.ID {
+		if r.ID != rule.ID || namespace(r) != namespace(rule) {
 			rules = append(rules, r)
 		}
 	}
@@ -101,51 +178,147 @@ func (j *jwtAuth) Verify(acc *auth.Account, res *auth.Resource, opts ...auth.Ver
 	j.Lock()
 	defer j.Unlock()
 
-	var options auth.VerifyOptions
-	for _, o := range opts {
-		o(&options)
-	}
-
-	return auth.VerifyAccess(j.rules, acc, res)
+	return auth.VerifyAccess(j.rules, acc, res, opts...)
 }
 
 func (j *jwtAuth) Rules(opts ...auth.RulesOption) ([]*auth.Rule, error) {
 	j.Lock()
 	defer j.Unlock()
-	return j.rules, nil
+
+	var options auth.RulesOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if len(options.Namespace) == 0 {
+		return j.rules, nil
+	}
+
+	// only return the rules in the namespace
+	rules := []*auth.Rule{}
+	for _, r := range j.rules {
+		if namespace(r) == options.Namespace {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
 }
 
 func (j *jwtAuth) Inspect(token string) (*auth.Account, error) {
-	return j.token.Inspect(token)
+	// API keys can be provided in place of a token
+	if id, secret, ok := parseKey(token); ok {
+		s := j.Options().Store
+		if s == nil {
+			return nil, auth.ErrInvalidToken
+		}
+		return inspectKey(s, id, secret)
+	}
+
+	return j.inspect(token)
+}
+
+// inspect the jwt, checking it hasn't been revoked
+func (j *jwtAuth) inspect(token string) (*auth.Account, error) {
+	acc, err := j.token.Inspect(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the token hasn't been revoked
+	if s := j.Options().Store; s != nil {
+		if ok, err := revoked(s, token); err != nil {
+			return nil, err
+		} else if ok {
+			return nil, auth.ErrInvalidToken
+		}
+	}
+
+	return acc, nil
 }
 
 func (j *jwtAuth) Token(opts ...auth.TokenOption) (*auth.Token, error) {
 	options := auth.NewTokenOptions(opts...)
+	s := j.Options().Store
 
-	secret := options.RefreshToken
-	if len(options.Secret) > 0 {
-		secret = options.Secret
-	}
+	var account *auth.Account
+	var err error
 
-	account, err := j.token.Inspect(secret)
+	switch {
+	case len(options.Secret) > 0:
+		account, err = j.inspect(options.Secret)
+	case s != nil:
+		// refresh tokens kept in the store can only be used once
+		account, err = useRefreshToken(s, options.RefreshToken)
+	default:
+		account, err = j.token.Inspect(options.RefreshToken)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// use the latest version of the account if it was generated by an instance with the store
+	if s != nil {
+		if acc, err := readAccount(s, account.Issuer, account.ID); err == nil {
+			account = acc
+		} else if err != store.ErrNotFound {
+			return nil, err
+		}
+	}
+
 	access, err := j.token.Generate(account, token.WithExpiry(options.Expiry))
 	if err != nil {
 		return nil, err
 	}
 
-	refresh, err := j.token.Generate(account, token.WithExpiry(options.Expiry+time.Hour))
+	// without a store the refresh token is a longer lived jwt
+	if s == nil {
+		refresh, err := j.token.Generate(account, token.WithExpiry(options.Expiry+time.Hour))
+		if err != nil {
+			return nil, err
+		}
+
+		return &auth.Token{
+			Created:      access.Created,
+			Expiry:       access.Expiry,
+			AccessToken:  access.Token,
+			RefreshToken: refresh.Token,
+		}, nil
+	}
+
+	refresh, err := newRefreshToken()
 	if err != nil {
 		return nil, err
 	}
+	if err := writeRefreshToken(s, account, refresh, access.Expiry.Add(time.Hour)); err != nil {
+		return nil, err
+	}
+
+	// record the access token so it can be revoked with the account's other tokens
+	if err := issue(s, account, access.Token, access.Expiry); err != nil {
+		return nil, err
+	}
 
 	return &auth.Token{
 		Created:      access.Created,
 		Expiry:       access.Expiry,
 		AccessToken:  access.Token,
-		RefreshToken: refresh.Token,
+		RefreshToken: refresh,
 	}, nil
 }
+
+func (j *jwtAuth) RevokeTokens(acc *auth.Account, opts ...auth.RevokeOption) error {
+	var options auth.RevokeOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	s := j.Options().Store
+	if s == nil {
+		return ErrNoStore
+	}
+
+	if len(options.Token) > 0 {
+		return revokeToken(s, acc, options.Token)
+	}
+	return revokeAll(s, acc)
+}
@@ -0,0 +1,464 @@
+package jwt
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+// eventually retries the condition until it's true or a second has passed
+func eventually(t *testing.T, cond func() bool, msg string) {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(msg)
+}
+
+func hasRule(a auth.Auth, id string) bool {
+	rules, err := a.Rules()
+	if err != nil {
+		return false
+	}
+	for _, r := range rules {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRulesStore(t *testing.T) {
+	s := memory.NewStore()
+	a := NewAuth(auth.Store(s))
+	b := NewAuth(auth.Store(s))
+
+	rule := &auth.Rule{
+		ID:       "public",
+		Scope:    auth.ScopePublic,
+		Access:   auth.AccessGranted,
+		Resource: &auth.Resource{Type: "service", Name: "go.micro.service.foo", Endpoint: "*"},
+	}
+	res := &auth.Resource{Type: "service", Name: "go.micro.service.foo", Endpoint: "Foo.Bar"}
+
+	if err := a.Grant(rule); err != nil {
+		t.Fatalf("Grant returned %v error, expected nil", err)
+	}
+	eventually(t, func() bool { return hasRule(b, "public") }, "Expected the rule to be shared")
+
+	if err := b.Verify(nil, res); err != nil {
+		t.Errorf("Verify returned %v error, expected nil", err)
+	}
+
+	// a new instance loads the rules when initialised
+	c := NewAuth(auth.Store(s))
+	if !hasRule(c, "public") {
+		t.Errorf("Expected the rule to be loaded")
+	}
+
+	if err := b.Revoke(rule); err != nil {
+		t.Fatalf("Revoke returned %v error, expected nil", err)
+	}
+	eventually(t, func() bool { return !hasRule(a, "public") }, "Expected the rule to be revoked")
+
+	if err := a.Verify(nil, res); err != auth.ErrForbidden {
+		t.Errorf("Verify returned %v error, expected %v", err, auth.ErrForbidden)
+	}
+}
+
+// unwatchable hides the Watch method of the store
+type unwatchable struct {
+	store.Store
+}
+
+func TestRulesRefresh(t *testing.T) {
+	s := unwatchable{memory.NewStore()}
+	a := NewAuth(auth.Store(s), WithRefreshInterval(10*time.Millisecond))
+
+	if err := writeRule(s, &auth.Rule{ID: "refreshed", Resource: &auth.Resource{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, func() bool { return hasRule(a, "refreshed") }, "Expected the rules to be refreshed")
+}
+
+func TestInitRefresh(t *testing.T) {
+	a := NewAuth(auth.Store(memory.NewStore())).(*jwtAuth)
+	exit := a.exit
+
+	// refreshing the client token shouldn't restart refreshing the rules
+	a.Init(auth.ClientToken(&auth.Token{AccessToken: "foo"}))
+	if a.exit != exit {
+		t.Fatal("Expected the rules refresh not to be restarted")
+	}
+
+	a.Init(auth.Store(memory.NewStore()))
+	if a.exit == exit {
+		t.Fatal("Expected the rules refresh to be restarted for a new store")
+	}
+	select {
+	case <-exit:
+	default:
+		t.Fatal("Expected the previous rules refresh to be stopped")
+	}
+}
+
+// keys returns the options with the test keys
+func keys(t *testing.T, opts ...auth.Option) []auth.Option {
+	pubKey, err := ioutil.ReadFile("../../util/token/jwt/test/sample_key.pub")
+	if err != nil {
+		t.Fatalf("Unable to read public key: %v", err)
+	}
+	privKey, err := ioutil.ReadFile("../../util/token/jwt/test/sample_key")
+	if err != nil {
+		t.Fatalf("Unable to read private key: %v", err)
+	}
+	return append(opts, auth.PublicKey(string(pubKey)), auth.PrivateKey(string(privKey)))
+}
+
+func TestAccountStore(t *testing.T) {
+	s := memory.NewStore()
+	opts := keys(t, auth.Store(s), auth.Issuer("micro"))
+	a := NewAuth(opts...)
+	b := NewAuth(opts...)
+
+	acc, err := a.Generate("john", auth.WithScopes("admin"))
+	if err != nil {
+		t.Fatalf("Generate returned %v error, expected nil", err)
+	}
+
+	stored, err := readAccount(s, "micro", "john")
+	if err != nil {
+		t.Fatalf("Expected the account to be stored, got %v", err)
+	}
+	if len(stored.Secret) > 0 {
+		t.Errorf("Expected the secret not to be stored")
+	}
+
+	// the scopes are changed by another instance
+	stored.Scopes = []string{"user"}
+	if err := writeAccount(s, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := b.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+
+	inspected, err := b.Inspect(tok.AccessToken)
+	if err != nil {
+		t.Fatalf("Inspect returned %v error, expected nil", err)
+	}
+	if len(inspected.Scopes) != 1 || inspected.Scopes[0] != "user" {
+		t.Errorf("Expected the stored scopes, got %v", inspected.Scopes)
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	a := NewAuth(keys(t, auth.Store(memory.NewStore()))...)
+
+	acc, err := a.Generate("john")
+	if err != nil {
+		t.Fatalf("Generate returned %v error, expected nil", err)
+	}
+	tok, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+
+	// the refresh token can't be used as an access token or vice versa
+	if _, err := a.Inspect(tok.RefreshToken); err == nil {
+		t.Errorf("Expected the refresh token not to be inspected")
+	}
+	if _, err := a.Token(auth.WithToken(tok.AccessToken)); err != auth.ErrInvalidToken {
+		t.Errorf("Token returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+
+	rotated, err := a.Token(auth.WithToken(tok.RefreshToken))
+	if err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+	if rotated.RefreshToken == tok.RefreshToken {
+		t.Errorf("Expected the refresh token to be rotated")
+	}
+
+	// reusing the old refresh token revokes all the account's tokens
+	if _, err := a.Token(auth.WithToken(tok.RefreshToken)); err != auth.ErrInvalidToken {
+		t.Errorf("Token returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+	if _, err := a.Token(auth.WithToken(rotated.RefreshToken)); err != auth.ErrInvalidToken {
+		t.Errorf("Token returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+	if _, err := a.Inspect(rotated.AccessToken); err != auth.ErrInvalidToken {
+		t.Errorf("Inspect returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+
+	// including its secret
+	if _, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret)); err != auth.ErrInvalidToken {
+		t.Errorf("Token returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+}
+
+func TestRevokeTokens(t *testing.T) {
+	s := memory.NewStore()
+	a := NewAuth(keys(t, auth.Store(s))...)
+	b := NewAuth(keys(t, auth.Store(s))...).(auth.Revoker)
+
+	acc, err := a.Generate("john")
+	if err != nil {
+		t.Fatalf("Generate returned %v error, expected nil", err)
+	}
+
+	tok1, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+	tok2, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+
+	// revoke a single token
+	if err := b.RevokeTokens(acc, auth.RevokeToken(tok1.AccessToken)); err != nil {
+		t.Fatalf("RevokeTokens returned %v error, expected nil", err)
+	}
+	if _, err := a.Inspect(tok1.AccessToken); err != auth.ErrInvalidToken {
+		t.Errorf("Inspect returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+	if _, err := a.Inspect(tok2.AccessToken); err != nil {
+		t.Errorf("Inspect returned %v error, expected nil", err)
+	}
+
+	// revoke all the account's tokens
+	if err := b.RevokeTokens(acc); err != nil {
+		t.Fatalf("RevokeTokens returned %v error, expected nil", err)
+	}
+	if _, err := a.Inspect(tok2.AccessToken); err != auth.ErrInvalidToken {
+		t.Errorf("Inspect returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+	if _, err := a.Token(auth.WithToken(tok1.RefreshToken)); err != auth.ErrInvalidToken {
+		t.Errorf("Token returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+
+	// the secret is revoked with the tokens so it can't be exchanged for a token
+	if _, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret)); err != auth.ErrInvalidToken {
+		t.Errorf("Token returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+
+	// a single secret can be revoked too
+	acc, err = a.Generate("jane")
+	if err != nil {
+		t.Fatalf("Generate returned %v error, expected nil", err)
+	}
+	if err := b.RevokeTokens(acc, auth.RevokeToken(acc.Secret)); err != nil {
+		t.Fatalf("RevokeTokens returned %v error, expected nil", err)
+	}
+	if _, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret)); err != auth.ErrInvalidToken {
+		t.Errorf("Token returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+
+	// tokens can't be revoked without a store
+	if err := NewAuth().(auth.Revoker).RevokeTokens(acc); err != ErrNoStore {
+		t.Errorf("RevokeTokens returned %v error, expected %v", err, ErrNoStore)
+	}
+}
+
+func TestRulesNamespace(t *testing.T) {
+	a := NewAuth(auth.Store(memory.NewStore()))
+
+	res := &auth.Resource{Type: "service", Name: "go.micro.service.foo", Endpoint: "Foo.Bar"}
+	catchall := &auth.Resource{Type: "*", Name: "*", Endpoint: "*"}
+
+	// the rules have the same id but different namespaces
+	rules := []*auth.Rule{
+		{ID: "account", Scope: auth.ScopeAccount, Resource: catchall},
+		{ID: "account", Scope: auth.ScopeAccount, Resource: catchall, Namespace: "foo"},
+		{ID: "account", Scope: auth.ScopeAccount, Resource: catchall, Namespace: "bar", Access: auth.AccessDenied},
+	}
+	for _, r := range rules {
+		if err := a.Grant(r); err != nil {
+			t.Fatalf("Grant returned %v error, expected nil", err)
+		}
+	}
+
+	all, err := a.Rules()
+	if err != nil || len(all) != 3 {
+		t.Fatalf("Expected 3 rules, got %v %v", len(all), err)
+	}
+
+	tt := []struct {
+		Name      string
+		Namespace string
+		Account   *auth.Account
+		Error     error
+	}{
+		{
+			Name:    "Default",
+			Account: &auth.Account{Issuer: auth.DefaultNamespace},
+		},
+		{
+			Name:      "Namespace",
+			Namespace: "foo",
+			Account:   &auth.Account{Issuer: "foo"},
+		},
+		{
+			Name:      "Denied",
+			Namespace: "bar",
+			Account:   &auth.Account{Issuer: "bar"},
+			Error:     auth.ErrForbidden,
+		},
+		{
+			Name:      "Fallback",
+			Namespace: "baz",
+			Account:   &auth.Account{Issuer: "baz"},
+		},
+		{
+			Name:      "OtherNamespace",
+			Namespace: "foo",
+			Account:   &auth.Account{Issuer: "baz"},
+			Error:     auth.ErrForbidden,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			if err := a.Verify(tc.Account, res, auth.VerifyNamespace(tc.Namespace)); err != tc.Error {
+				t.Errorf("Expected %v but got %v", tc.Error, err)
+			}
+
+			if len(tc.Namespace) == 0 {
+				return
+			}
+			nsRules, err := a.Rules(auth.RulesNamespace(tc.Namespace))
+			if err != nil {
+				t.Fatalf("Rules returned %v error, expected nil", err)
+			}
+			for _, r := range nsRules {
+				if r.Namespace != tc.Namespace {
+					t.Errorf("Expected rules in %v, got %v", tc.Namespace, r.Namespace)
+				}
+			}
+		})
+	}
+
+	// revoking the rule only removes it from its namespace
+	if err := a.Revoke(rules[1]); err != nil {
+		t.Fatalf("Revoke returned %v error, expected nil", err)
+	}
+	if nsRules, _ := a.Rules(auth.RulesNamespace("foo")); len(nsRules) != 0 {
+		t.Errorf("Expected the rule to be revoked, got %v", len(nsRules))
+	}
+	if all, _ := a.Rules(); len(all) != 2 {
+		t.Errorf("Expected 2 rules, got %v", len(all))
+	}
+}
+
+func TestKeys(t *testing.T) {
+	s := memory.NewStore()
+	a := NewAuth(keys(t, auth.Store(s), auth.Issuer("micro"))...)
+	km := a.(auth.KeyManager)
+
+	acc, err := a.Generate("john", auth.WithScopes("admin", "user"))
+	if err != nil {
+		t.Fatalf("Generate returned %v error, expected nil", err)
+	}
+
+	// the key can't have scopes the account wasn't granted
+	if _, err := km.GenerateKey(acc, auth.WithKeyScopes("root")); err != auth.ErrForbidden {
+		t.Errorf("GenerateKey returned %v error, expected %v", err, auth.ErrForbidden)
+	}
+
+	key, err := km.GenerateKey(acc, auth.WithKeyScopes("user"))
+	if err != nil {
+		t.Fatalf("GenerateKey returned %v error, expected nil", err)
+	}
+
+	// only the hash of the key is stored
+	recs, err := s.Read(KeysPrefix + key.ID)
+	if err != nil {
+		t.Fatalf("Expected the key to be stored, got %v", err)
+	}
+	if strings.Contains(string(recs[0].Value), key.Token[strings.Index(key.Token, ".")+1:]) {
+		t.Errorf("Expected the key not to be stored")
+	}
+
+	inspected, err := a.Inspect(key.Token)
+	if err != nil {
+		t.Fatalf("Inspect returned %v error, expected nil", err)
+	}
+	if inspected.ID != "john" || len(inspected.Scopes) != 1 || inspected.Scopes[0] != "user" {
+		t.Errorf("Expected john with the key's scopes, got %v %v", inspected.ID, inspected.Scopes)
+	}
+
+	// scopes removed from the account are removed from the key
+	if _, err := a.Generate("john", auth.WithScopes("admin")); err != nil {
+		t.Fatalf("Generate returned %v error, expected nil", err)
+	}
+	if inspected, err := a.Inspect(key.Token); err != nil || len(inspected.Scopes) != 0 {
+		t.Errorf("Expected the key to have no scopes, got %v %v", inspected, err)
+	}
+
+	// keys of accounts which don't exist aren't valid
+	if err := s.Delete(accountKey("micro", "john")); err != nil {
+		t.Fatalf("Delete returned %v error, expected nil", err)
+	}
+	if _, err := a.Inspect(key.Token); err != auth.ErrInvalidToken {
+		t.Errorf("Inspect returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+	if _, err := a.Generate("john", auth.WithScopes("admin", "user")); err != nil {
+		t.Fatalf("Generate returned %v error, expected nil", err)
+	}
+
+	// keys of other accounts aren't listed
+	other, err := a.Generate("jane")
+	if err != nil {
+		t.Fatalf("Generate returned %v error, expected nil", err)
+	}
+	if _, err := km.GenerateKey(other); err != nil {
+		t.Fatalf("GenerateKey returned %v error, expected nil", err)
+	}
+
+	listed, err := km.Keys(acc)
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("Expected 1 key, got %v %v", len(listed), err)
+	}
+	if listed[0].LastUsed.IsZero() {
+		t.Errorf("Expected the key's last used time to be set")
+	}
+	if len(listed[0].Token) > 0 {
+		t.Errorf("Expected the key not to be listed")
+	}
+
+	// a key with the wrong secret isn't valid
+	if _, err := a.Inspect(auth.KeyPrefix + key.ID + ".foo"); err != auth.ErrInvalidToken {
+		t.Errorf("Inspect returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+
+	if err := km.RevokeKey(key.ID); err != nil {
+		t.Fatalf("RevokeKey returned %v error, expected nil", err)
+	}
+	if listed, err := km.Keys(acc); err != nil || len(listed) != 0 {
+		t.Errorf("Expected no keys, got %v %v", len(listed), err)
+	}
+	if _, err := a.Inspect(key.Token); err != auth.ErrInvalidToken {
+		t.Errorf("Inspect returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+
+	// expired keys aren't valid
+	expired, err := km.GenerateKey(acc, auth.WithKeyExpiry(time.Millisecond))
+	if err != nil {
+		t.Fatalf("GenerateKey returned %v error, expected nil", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := a.Inspect(expired.Token); err != auth.ErrInvalidToken {
+		t.Errorf("Inspect returned %v error, expected %v", err, auth.ErrInvalidToken)
+	}
+}
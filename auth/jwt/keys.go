@@ -0,0 +1,228 @@
+package jwt
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// KeysPrefix to isolate API keys in the store
+	KeysPrefix = "keys/"
+	// AccountKeysPrefix to index the API keys generated for each account in the store
+	AccountKeysPrefix = "accountkeys/"
+	// KeyUsedInterval is how often the last used time of a key is written to the store
+	KeyUsedInterval = time.Minute
+)
+
+// apiKey is stored for each API key, the key itself is never stored
+type apiKey struct {
+	*auth.Key
+	// Hash of the key's secret
+	Hash string `json:"hash"`
+}
+
+// parseKey splits an API key into its id and secret
+func parseKey(key string) (string, string, bool) {
+	if !strings.HasPrefix(key, auth.KeyPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, auth.KeyPrefix), ".", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func accountKeysKey(issuer, account, id string) string {
+	return fmt.Sprintf("%v%v/%v/%v", AccountKeysPrefix, issuer, account, id)
+}
+
+func writeKey(s store.Store, k *apiKey) error {
+	bytes, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+
+	var expiry time.Duration
+	if !k.Expiry.IsZero() {
+		expiry = time.Until(k.Expiry)
+	}
+
+	return s.Write(&store.Record{Key: KeysPrefix + k.ID, Value: bytes, Expiry: expiry})
+}
+
+func readKey(s store.Store, id string) (*apiKey, error) {
+	recs, err := s.Read(KeysPrefix + id)
+	if err != nil {
+		return nil, err
+	}
+
+	var k *apiKey
+	if err := json.Unmarshal(recs[0].Value, &k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// keyScopes returns the scopes requested for the key, which must have been granted to the account
+func keyScopes(acc *auth.Account, scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return acc.Scopes, nil
+	}
+
+	granted := make(map[string]bool, len(acc.Scopes))
+	for _, s := range acc.Scopes {
+		granted[s] = true
+	}
+	for _, s := range scopes {
+		if !granted[s] {
+			return nil, auth.ErrForbidden
+		}
+	}
+	return scopes, nil
+}
+
+func (j *jwtAuth) GenerateKey(acc *auth.Account, opts ...auth.KeyOption) (*auth.Key, error) {
+	options := auth.NewKeyOptions(opts...)
+
+	s := j.Options().Store
+	if s == nil {
+		return nil, ErrNoStore
+	}
+
+	scopes, err := keyScopes(acc, options.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &auth.Key{
+		ID:      uuid.New().String(),
+		Account: acc.ID,
+		Issuer:  acc.Issuer,
+		Scopes:  scopes,
+		Created: time.Now(),
+	}
+	if options.Expiry > 0 {
+		key.Expiry = key.Created.Add(options.Expiry)
+	}
+
+	// index the key so the account's keys can be listed without reading every key
+	var expiry time.Duration
+	if !key.Expiry.IsZero() {
+		expiry = time.Until(key.Expiry)
+	}
+	if err := s.Write(&store.Record{Key: accountKeysKey(key.Issuer, key.Account, key.ID), Expiry: expiry}); err != nil {
+		return nil, err
+	}
+	if err := writeKey(s, &apiKey{Key: key, Hash: hash(secret)}); err != nil {
+		return nil, err
+	}
+
+	// the key is only ever returned to the caller here
+	generated := *key
+	generated.Token = auth.KeyPrefix + key.ID + "." + secret
+	return &generated, nil
+}
+
+func (j *jwtAuth) RevokeKey(id string) error {
+	s := j.Options().Store
+	if s == nil {
+		return ErrNoStore
+	}
+
+	k, err := readKey(s, id)
+	if err == store.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := s.Delete(KeysPrefix + id); err != nil {
+		return err
+	}
+	return s.Delete(accountKeysKey(k.Issuer, k.Account, id))
+}
+
+func (j *jwtAuth) Keys(acc *auth.Account) ([]*auth.Key, error) {
+	s := j.Options().Store
+	if s == nil {
+		return nil, ErrNoStore
+	}
+
+	prefix := accountKeysKey(acc.Issuer, acc.ID, "")
+	ids, err := s.List(store.ListPrefix(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*auth.Key{}
+	for _, id := range ids {
+		k, err := readKey(s, strings.TrimPrefix(id, prefix))
+		if err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k.Key)
+	}
+	return keys, nil
+}
+
+// inspectKey returns the account for an API key, limited to the scopes of the key the account
+// still has. Keys of accounts which no longer exist aren't valid.
+func inspectKey(s store.Store, id, secret string) (*auth.Account, error) {
+	k, err := readKey(s, id)
+	if err == store.ErrNotFound {
+		return nil, auth.ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(k.Hash)) != 1 {
+		return nil, auth.ErrInvalidToken
+	}
+	if !k.Expiry.IsZero() && time.Now().After(k.Expiry) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	// only record the usage periodically to avoid writing on every request
+	if now := time.Now(); now.Sub(k.LastUsed) > KeyUsedInterval {
+		k.LastUsed = now
+		if err := writeKey(s, k); err != nil {
+			return nil, err
+		}
+	}
+
+	acc, err := readAccount(s, k.Issuer, k.Account)
+	if err == store.ErrNotFound {
+		return nil, auth.ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	// scopes removed from the account since the key was generated are removed from the key
+	granted := make(map[string]bool, len(acc.Scopes))
+	for _, s := range acc.Scopes {
+		granted[s] = true
+	}
+	scopes := []string{}
+	for _, s := range k.Scopes {
+		if granted[s] {
+			scopes = append(scopes, s)
+		}
+	}
+	acc.Scopes = scopes
+
+	return acc, nil
+}
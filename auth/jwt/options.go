@@ -0,0 +1,36 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v3/auth"
+)
+
+var (
+	// DefaultRefreshInterval is how often the rules are reloaded from the store
+	DefaultRefreshInterval = time.Minute
+)
+
+type refreshIntervalKey struct{}
+
+// WithRefreshInterval sets how often the rules are reloaded from the store. Changes made by
+// other instances are picked up sooner if the store is watchable.
+func WithRefreshInterval(d time.Duration) auth.Option {
+	return func(o *auth.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, refreshIntervalKey{}, d)
+	}
+}
+
+func refreshInterval(o auth.Options) time.Duration {
+	if o.Context == nil {
+		return DefaultRefreshInterval
+	}
+	if d, ok := o.Context.Value(refreshIntervalKey{}).(time.Duration); ok && d > 0 {
+		return d
+	}
+	return DefaultRefreshInterval
+}
@@ -0,0 +1,178 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// RulesPrefix to isolate rules in the store
+	RulesPrefix = "rules/"
+	// AccountsPrefix to isolate accounts in the store
+	AccountsPrefix = "accounts/"
+)
+
+// readRules loads all the rules from the store
+func readRules(s store.Store) ([]*auth.Rule, error) {
+	recs, err := s.Read(RulesPrefix, store.ReadPrefix())
+	if err == store.ErrNotFound {
+		return []*auth.Rule{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*auth.Rule, 0, len(recs))
+	for _, r := range recs {
+		var rule *auth.Rule
+		if err := json.Unmarshal(r.Value, &rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	// keep the order stable so every instance evaluates rules of the same priority alike
+	sort.SliceStable(rules, func(i, j int) bool {
+		return ruleKey(rules[i]) < ruleKey(rules[j])
+	})
+
+	return rules, nil
+}
+
+// namespace returns the namespace of the rule
+func namespace(rule *auth.Rule) string {
+	if len(rule.Namespace) == 0 {
+		return auth.DefaultNamespace
+	}
+	return rule.Namespace
+}
+
+// ruleKey is unique for each rule id in a namespace
+func ruleKey(rule *auth.Rule) string {
+	return fmt.Sprintf("%v%v/%v", RulesPrefix, namespace(rule), rule.ID)
+}
+
+func writeRule(s store.Store, rule *auth.Rule) error {
+	bytes, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	return s.Write(&store.Record{Key: ruleKey(rule), Value: bytes})
+}
+
+func deleteRule(s store.Store, rule *auth.Rule) error {
+	return s.Delete(ruleKey(rule))
+}
+
+func accountKey(issuer, id string) string {
+	return fmt.Sprintf("%v%v/%v", AccountsPrefix, issuer, id)
+}
+
+// writeAccount persists the account, the secret is never stored
+func writeAccount(s store.Store, acc *auth.Account) error {
+	stored := *acc
+	stored.Secret = ""
+
+	bytes, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return s.Write(&store.Record{Key: accountKey(acc.Issuer, acc.ID), Value: bytes})
+}
+
+func readAccount(s store.Store, issuer, id string) (*auth.Account, error) {
+	recs, err := s.Read(accountKey(issuer, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var acc *auth.Account
+	if err := json.Unmarshal(recs[0].Value, &acc); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// reload the rules from the store, the lock must be held
+func (j *jwtAuth) reload(s store.Store) error {
+	rules, err := readRules(s)
+	if err != nil {
+		return err
+	}
+	j.rules = rules
+	return nil
+}
+
+// watch the rules in the store, returning nil if the store isn't watchable
+func watch(s store.Store) store.Watcher {
+	ws, ok := s.(store.Watchable)
+	if !ok {
+		return nil
+	}
+
+	opts := s.Options()
+	w, err := ws.Watch(store.WatchFrom(opts.Database, opts.Table), store.WatchPrefix(RulesPrefix))
+	if err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error watching auth rules: %v", err)
+		}
+		return nil
+	}
+	return w
+}
+
+// refresh reloads the rules from the store until exit is closed. The rules are reloaded
+// on an interval and whenever the watcher, if any, returns an event.
+func (j *jwtAuth) refresh(s store.Store, w store.Watcher, interval time.Duration, exit chan bool) {
+	update := make(chan bool, 1)
+
+	if w != nil {
+		defer w.Stop()
+
+		go func() {
+			for {
+				if _, err := w.Next(); err != nil {
+					return
+				}
+				select {
+				case update <- true:
+				default:
+				}
+			}
+		}()
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case <-t.C:
+		case <-update:
+		}
+
+		rules, err := readRules(s)
+		if err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error loading auth rules: %v", err)
+			}
+			continue
+		}
+
+		j.Lock()
+		select {
+		case <-exit:
+			// the auth was reinitialised whilst loading
+		default:
+			j.rules = rules
+		}
+		j.Unlock()
+	}
+}
@@ -0,0 +1,190 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// RefreshPrefix to isolate refresh tokens in the store
+	RefreshPrefix = "refresh/"
+	// IssuedPrefix to isolate the tokens issued to each account in the store
+	IssuedPrefix = "issued/"
+	// RevokedPrefix to isolate revoked tokens in the store
+	RevokedPrefix = "revoked/"
+	// DefaultRevokedExpiry is how long a token is kept in the revocation list if its expiry is unknown
+	DefaultRevokedExpiry = time.Hour * 24
+)
+
+// refreshToken is stored for each refresh token issued
+type refreshToken struct {
+	// Account the token was issued to
+	Account *auth.Account `json:"account"`
+	// Used is set once the token has been exchanged, using it again indicates it was stolen
+	Used bool `json:"used"`
+}
+
+// hash the token so the tokens themselves are never stored
+func hash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func issuedKey(acc *auth.Account, h string) string {
+	return fmt.Sprintf("%v%v/%v/%v", IssuedPrefix, acc.Issuer, acc.ID, h)
+}
+
+// newRefreshToken returns a random opaque refresh token
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// issue records the token against the account so it can be revoked
+func issue(s store.Store, acc *auth.Account, token string, expiry time.Time) error {
+	return s.Write(&store.Record{
+		Key:    issuedKey(acc, hash(token)),
+		Expiry: time.Until(expiry),
+	})
+}
+
+// writeRefreshToken stores a new refresh token for the account
+func writeRefreshToken(s store.Store, acc *auth.Account, token string, expiry time.Time) error {
+	stored := *acc
+	stored.Secret = ""
+
+	bytes, err := json.Marshal(&refreshToken{Account: &stored})
+	if err != nil {
+		return err
+	}
+
+	if err := s.Write(&store.Record{
+		Key:    RefreshPrefix + hash(token),
+		Value:  bytes,
+		Expiry: time.Until(expiry),
+	}); err != nil {
+		return err
+	}
+
+	return issue(s, acc, token, expiry)
+}
+
+// useRefreshToken marks the refresh token as used and returns the account it was issued to.
+// If the token was already used all of the account's tokens are revoked.
+func useRefreshToken(s store.Store, token string) (*auth.Account, error) {
+	recs, err := s.Read(RefreshPrefix + hash(token))
+	if err == store.ErrNotFound {
+		return nil, auth.ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+	rec := recs[0]
+
+	var rt *refreshToken
+	if err := json.Unmarshal(rec.Value, &rt); err != nil {
+		return nil, err
+	}
+
+	// the token has been used before so it's been leaked, revoke everything
+	if rt.Used {
+		if err := revokeAll(s, rt.Account); err != nil {
+			return nil, err
+		}
+		return nil, auth.ErrInvalidToken
+	}
+
+	rt.Used = true
+	bytes, err := json.Marshal(rt)
+	if err != nil {
+		return nil, err
+	}
+
+	// the version check catches the token being used concurrently
+	err = s.Write(&store.Record{
+		Key:    rec.Key,
+		Value:  bytes,
+		Expiry: rec.Expiry,
+	}, store.WriteIfVersion(rec.Version))
+	if err == store.ErrVersionConflict {
+		if err := revokeAll(s, rt.Account); err != nil {
+			return nil, err
+		}
+		return nil, auth.ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	return rt.Account, nil
+}
+
+// revoked returns true if the token has been revoked
+func revoked(s store.Store, token string) (bool, error) {
+	_, err := s.Read(RevokedPrefix + hash(token))
+	if err == store.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// revoke the token with the hash, keeping it in the revocation list until it expires
+func revoke(s store.Store, h string, expiry time.Duration) error {
+	if err := s.Write(&store.Record{Key: RevokedPrefix + h, Expiry: expiry}); err != nil {
+		return err
+	}
+	return s.Delete(RefreshPrefix + h)
+}
+
+// revokeToken revokes a single token issued to the account
+func revokeToken(s store.Store, acc *auth.Account, token string) error {
+	h := hash(token)
+
+	expiry := DefaultRevokedExpiry
+	if recs, err := s.Read(issuedKey(acc, h)); err == nil {
+		expiry = recs[0].Expiry
+	} else if err != store.ErrNotFound {
+		return err
+	}
+
+	if err := revoke(s, h, expiry); err != nil {
+		return err
+	}
+	return s.Delete(issuedKey(acc, h))
+}
+
+// revokeAll revokes all the tokens issued to the account
+func revokeAll(s store.Store, acc *auth.Account) error {
+	prefix := issuedKey(acc, "")
+
+	recs, err := s.Read(prefix, store.ReadPrefix())
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	for _, r := range recs {
+		expiry := r.Expiry
+		if expiry == 0 {
+			expiry = DefaultRevokedExpiry
+		}
+		if err := revoke(s, r.Key[len(prefix):], expiry); err != nil {
+			return err
+		}
+		if err := s.Delete(r.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -65,6 +65,35 @@ func (n *noop) Rules(opts ...auth.RulesOption) ([]*auth.Rule, error) {
 	return []*auth.Rule{}, nil
 }
 
+// RevokeTokens issued to an account
+func (n *noop) RevokeTokens(acc *auth.Account, opts ...auth.RevokeOption) error {
+	return nil
+}
+
+// GenerateKey for an account
+func (n *noop) GenerateKey(acc *auth.Account, opts ...auth.KeyOption) (*auth.Key, error) {
+	options := auth.NewKeyOptions(opts...)
+	id := uuid.New().String()
+
+	return &auth.Key{
+		ID:      id,
+		Token:   auth.KeyPrefix + id + "." + uuid.New().String(),
+		Account: acc.ID,
+		Issuer:  acc.Issuer,
+		Scopes:  options.Scopes,
+	}, nil
+}
+
+// RevokeKey with the id
+func (n *noop) RevokeKey(id string) error {
+	return nil
+}
+
+// Keys generated for an account
+func (n *noop) Keys(acc *auth.Account) ([]*auth.Key, error) {
+	return []*auth.Key{}, nil
+}
+
 // Verify an account has access to a resource
 func (n *noop) Verify(acc *auth.Account, res *auth.Resource, opts ...auth.VerifyOption) error {
 	return nil
@@ -257,3 +257,49 @@ func RulesNamespace(ns string) RulesOption {
 		o.Namespace = ns
 	}
 }
+
+type RevokeOptions struct {
+	// Token to revoke, all the account's tokens are revoked if blank
+	Token string
+}
+
+type RevokeOption func(o *RevokeOptions)
+
+// RevokeToken only revokes the token t, e.g. when logging out
+func RevokeToken(t string) RevokeOption {
+	return func(o *RevokeOptions) {
+		o.Token = t
+	}
+}
+
+type KeyOptions struct {
+	// Scopes the key has access to, all of the account's scopes if blank
+	Scopes []string
+	// Expiry is the time the key should live for, it doesn't expire if zero
+	Expiry time.Duration
+}
+
+type KeyOption func(o *KeyOptions)
+
+// WithKeyScopes limits the key to a subset of the account's scopes
+func WithKeyScopes(s ...string) KeyOption {
+	return func(o *KeyOptions) {
+		o.Scopes = s
+	}
+}
+
+// WithKeyExpiry for the key
+func WithKeyExpiry(ex time.Duration) KeyOption {
+	return func(o *KeyOptions) {
+		o.Expiry = ex
+	}
+}
+
+// NewKeyOptions from a slice of options
+func NewKeyOptions(opts ...KeyOption) KeyOptions {
+	var options KeyOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
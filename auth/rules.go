@@ -1,15 +1,58 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/micro/go-micro/v3/metadata"
 )
 
 // VerifyAccess an account has access to a resource using the rules provided. If the account does not have
 // access an error will be returned. If there are no rules provided which match the resource, an error
-// will be returned
-func VerifyAccess(rules []*Rule, acc *Account, res *Resource) error {
+// will be returned. The rules in the namespace from the options are used, falling back to the rules in
+// the default namespace. Accounts issued in another namespace are only granted access by public rules.
+// If no namespace is provided the account's issuer is used.
+func VerifyAccess(rules []*Rule, acc *Account, res *Resource, opts ...VerifyOption) error {
+	var options VerifyOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	if len(options.Namespace) == 0 && acc != nil {
+		options.Namespace = acc.Issuer
+	}
+	ns := namespace(options.Namespace)
+
+	// accounts can't match the rules of another namespace
+	if acc != nil && namespace(acc.Issuer) != ns {
+		acc = nil
+	}
+
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	now := time.Now()
+
+	if ok, err := verify(ctx, inNamespace(rules, ns), acc, res, now); ok {
+		return err
+	}
+
+	// fallback to the default rules
+	if ns != DefaultNamespace {
+		if ok, err := verify(ctx, inNamespace(rules, DefaultNamespace), acc, res, now); ok {
+			return err
+		}
+	}
+
+	// if no rules matched then return forbidden
+	return ErrForbidden
+}
+
+// verify the account has access to the resource, returning false if none of the rules apply
+func verify(ctx context.Context, rules []*Rule, acc *Account, res *Resource, now time.Time) (bool, error) {
 	// the rule is only to be applied if the type matches the resource or is catch-all (*)
 	validTypes := []string{"*", res.Type}
 
@@ -38,6 +81,9 @@ func VerifyAccess(rules []*Rule, acc *Account, res *Resource) error {
 		if !include(validEndpoints, rule.Resource.Endpoint) {
 			continue
 		}
+		if !conditionMet(ctx, rule.Condition, acc, now) {
+			continue
+		}
 		filteredRules = append(filteredRules, rule)
 	}
 
@@ -50,9 +96,9 @@ func VerifyAccess(rules []*Rule, acc *Account, res *Resource) error {
 	for _, rule := range filteredRules {
 		// a blank scope indicates the rule applies to everyone, even nil accounts
 		if rule.Scope == ScopePublic && rule.Access == AccessDenied {
-			return ErrForbidden
+			return true, ErrForbidden
 		} else if rule.Scope == ScopePublic && rule.Access == AccessGranted {
-			return nil
+			return true, nil
 		}
 
 		// all further checks require an account
@@ -62,21 +108,103 @@ func VerifyAccess(rules []*Rule, acc *Account, res *Resource) error {
 
 		// this rule applies to any account
 		if rule.Scope == ScopeAccount && rule.Access == AccessDenied {
-			return ErrForbidden
+			return true, ErrForbidden
 		} else if rule.Scope == ScopeAccount && rule.Access == AccessGranted {
-			return nil
+			return true, nil
 		}
 
 		// if the account has the necessary scope
 		if include(acc.Scopes, rule.Scope) && rule.Access == AccessDenied {
-			return ErrForbidden
+			return true, ErrForbidden
 		} else if include(acc.Scopes, rule.Scope) && rule.Access == AccessGranted {
-			return nil
+			return true, nil
 		}
 	}
 
-	// if no rules matched then return forbidden
-	return ErrForbidden
+	return false, nil
+}
+
+// conditionMet returns true if there's no condition or the account and request meet it
+func conditionMet(ctx context.Context, c *Condition, acc *Account, now time.Time) bool {
+	if c == nil {
+		return true
+	}
+
+	// account conditions can't be met without an account
+	if (len(c.Metadata) > 0 || len(c.Type) > 0) && acc == nil {
+		return false
+	}
+	for k, v := range c.Metadata {
+		if val, ok := acc.Metadata[k]; !ok || val != v {
+			return false
+		}
+	}
+	if len(c.Type) > 0 && !strings.EqualFold(c.Type, acc.Type) {
+		return false
+	}
+
+	for k, v := range c.Request {
+		if val, ok := metadata.Get(ctx, k); !ok || val != v {
+			return false
+		}
+	}
+
+	if c.Window != nil && !inWindow(c.Window, now) {
+		return false
+	}
+
+	return true
+}
+
+// inWindow returns true if the time of day is within the window. Invalid windows are never met.
+func inWindow(w *Window, now time.Time) bool {
+	loc := time.UTC
+	if len(w.Timezone) > 0 {
+		var err error
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return false
+		}
+	}
+
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return false
+	}
+
+	// compare the minutes since midnight
+	now = now.In(loc)
+	mins := now.Hour()*60 + now.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	// the window spans midnight
+	if to < from {
+		return mins >= from || mins < to
+	}
+	return mins >= from && mins < to
+}
+
+// namespace returns the namespace, or the default namespace if blank
+func namespace(ns string) string {
+	if len(ns) == 0 {
+		return DefaultNamespace
+	}
+	return ns
+}
+
+// inNamespace returns the rules which belong to the namespace
+func inNamespace(rules []*Rule, ns string) []*Rule {
+	filtered := make([]*Rule, 0, len(rules))
+	for _, rule := range rules {
+		if namespace(rule.Namespace) == ns {
+			filtered = append(filtered, rule)
+		}
+	}
+	return filtered
 }
 
 // include is a helper function which checks to see if the slice contains the value. includes is
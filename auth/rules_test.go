@@ -1,7 +1,11 @@
 package auth
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/metadata"
 )
 
 func TestVerify(t *testing.T) {
@@ -286,3 +290,282 @@ func TestVerify(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyNamespace(t *testing.T) {
+	res := &Resource{
+		Type:     "service",
+		Name:     "go.micro.service.foo",
+		Endpoint: "Foo.Bar",
+	}
+
+	catchallResource := &Resource{
+		Type:     "*",
+		Name:     "*",
+		Endpoint: "*",
+	}
+
+	tt := []struct {
+		Name      string
+		Rules     []*Rule
+		Account   *Account
+		Namespace string
+		Error     error
+	}{
+		{
+			Name:    "DefaultNamespace",
+			Account: &Account{Issuer: DefaultNamespace},
+			Rules: []*Rule{
+				&Rule{Scope: "*", Resource: catchallResource},
+			},
+		},
+		{
+			Name:      "Namespace",
+			Account:   &Account{Issuer: "foo"},
+			Namespace: "foo",
+			Rules: []*Rule{
+				&Rule{Scope: "*", Resource: catchallResource, Namespace: "foo"},
+			},
+		},
+		{
+			Name:      "OtherNamespaceRule",
+			Account:   &Account{Issuer: "foo"},
+			Namespace: "foo",
+			Rules: []*Rule{
+				&Rule{Scope: "*", Resource: catchallResource, Namespace: "bar"},
+			},
+			Error: ErrForbidden,
+		},
+		{
+			Name:      "OtherNamespaceAccount",
+			Account:   &Account{Issuer: "bar", Scopes: []string{"admin"}},
+			Namespace: "foo",
+			Rules: []*Rule{
+				&Rule{Scope: "admin", Resource: catchallResource, Namespace: "foo"},
+			},
+			Error: ErrForbidden,
+		},
+		{
+			Name:      "OtherNamespaceAccountPublic",
+			Account:   &Account{Issuer: "bar"},
+			Namespace: "foo",
+			Rules: []*Rule{
+				&Rule{Scope: ScopePublic, Resource: catchallResource, Namespace: "foo"},
+			},
+		},
+		{
+			Name:      "DefaultNamespaceAccount",
+			Account:   &Account{Issuer: DefaultNamespace},
+			Namespace: "foo",
+			Rules: []*Rule{
+				&Rule{Scope: "*", Resource: catchallResource, Namespace: "foo"},
+			},
+			Error: ErrForbidden,
+		},
+		{
+			Name:      "FallbackToDefault",
+			Account:   &Account{Issuer: "foo"},
+			Namespace: "foo",
+			Rules: []*Rule{
+				&Rule{Scope: "*", Resource: catchallResource},
+			},
+		},
+		{
+			Name:      "NamespaceOverridesDefault",
+			Account:   &Account{Issuer: "foo"},
+			Namespace: "foo",
+			Rules: []*Rule{
+				&Rule{Scope: "*", Resource: catchallResource, Priority: 10},
+				&Rule{Scope: "*", Resource: catchallResource, Access: AccessDenied, Namespace: "foo"},
+			},
+			Error: ErrForbidden,
+		},
+		{
+			Name:      "FallbackWhenNoRuleApplies",
+			Account:   &Account{Issuer: "foo", Scopes: []string{"user"}},
+			Namespace: "foo",
+			Rules: []*Rule{
+				&Rule{Scope: "admin", Resource: catchallResource, Namespace: "foo"},
+				&Rule{Scope: "user", Resource: catchallResource},
+			},
+		},
+		{
+			Name:    "IssuerNamespace",
+			Account: &Account{Issuer: "foo"},
+			Rules: []*Rule{
+				&Rule{Scope: "*", Resource: catchallResource},
+			},
+		},
+		{
+			Name:      "NoAccount",
+			Namespace: "foo",
+			Rules: []*Rule{
+				&Rule{Scope: "*", Resource: catchallResource, Namespace: "foo"},
+			},
+			Error: ErrForbidden,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := VerifyAccess(tc.Rules, tc.Account, res, VerifyNamespace(tc.Namespace))
+			if err != tc.Error {
+				t.Errorf("Expected %v but got %v", tc.Error, err)
+			}
+		})
+	}
+}
+
+func TestVerifyConditions(t *testing.T) {
+	res := &Resource{
+		Type:     "service",
+		Name:     "go.micro.service.billing",
+		Endpoint: "Billing.Refund",
+	}
+
+	// only service accounts from the payments team may refund
+	refund := func(c *Condition) []*Rule {
+		return []*Rule{
+			&Rule{Scope: "*", Resource: res, Condition: c},
+		}
+	}
+
+	payments := &Account{Type: "service", Metadata: map[string]string{"team": "payments"}}
+	ctx := metadata.Set(context.Background(), "Micro-From-Service", "go.micro.service.orders")
+
+	// a window around now which is always open
+	now := time.Now().UTC()
+	open := &Window{
+		Start: now.Add(-time.Hour).Format("15:04"),
+		End:   now.Add(time.Hour).Format("15:04"),
+	}
+	closed := &Window{
+		Start: now.Add(time.Hour).Format("15:04"),
+		End:   now.Add(2 * time.Hour).Format("15:04"),
+	}
+
+	tt := []struct {
+		Name    string
+		Rules   []*Rule
+		Account *Account
+		Context context.Context
+		Error   error
+	}{
+		{
+			Name:    "Metadata",
+			Account: payments,
+			Rules:   refund(&Condition{Metadata: map[string]string{"team": "payments"}}),
+		},
+		{
+			Name:    "MetadataMismatch",
+			Account: &Account{Metadata: map[string]string{"team": "orders"}},
+			Rules:   refund(&Condition{Metadata: map[string]string{"team": "payments"}}),
+			Error:   ErrForbidden,
+		},
+		{
+			Name:    "MetadataMissing",
+			Account: &Account{},
+			Rules:   refund(&Condition{Metadata: map[string]string{"team": "payments"}}),
+			Error:   ErrForbidden,
+		},
+		{
+			Name:    "Type",
+			Account: payments,
+			Rules:   refund(&Condition{Type: "service", Metadata: map[string]string{"team": "payments"}}),
+		},
+		{
+			Name:    "TypeMismatch",
+			Account: &Account{Type: "user", Metadata: map[string]string{"team": "payments"}},
+			Rules:   refund(&Condition{Type: "service", Metadata: map[string]string{"team": "payments"}}),
+			Error:   ErrForbidden,
+		},
+		{
+			Name:  "NoAccount",
+			Rules: []*Rule{&Rule{Scope: ScopePublic, Resource: res, Condition: &Condition{Type: "service"}}},
+			Error: ErrForbidden,
+		},
+		{
+			Name:    "Request",
+			Account: payments,
+			Context: ctx,
+			Rules:   refund(&Condition{Request: map[string]string{"Micro-From-Service": "go.micro.service.orders"}}),
+		},
+		{
+			Name:    "RequestMismatch",
+			Account: payments,
+			Context: ctx,
+			Rules:   refund(&Condition{Request: map[string]string{"Micro-From-Service": "go.micro.service.foo"}}),
+			Error:   ErrForbidden,
+		},
+		{
+			Name:    "RequestNoContext",
+			Account: payments,
+			Rules:   refund(&Condition{Request: map[string]string{"Micro-From-Service": "go.micro.service.orders"}}),
+			Error:   ErrForbidden,
+		},
+		{
+			Name:    "WindowOpen",
+			Account: payments,
+			Rules:   refund(&Condition{Window: open}),
+		},
+		{
+			Name:    "WindowClosed",
+			Account: payments,
+			Rules:   refund(&Condition{Window: closed}),
+			Error:   ErrForbidden,
+		},
+		{
+			Name:    "UnmetDenyIgnored",
+			Account: payments,
+			Rules: []*Rule{
+				&Rule{Scope: "*", Resource: res, Access: AccessDenied, Priority: 1, Condition: &Condition{Type: "user"}},
+				&Rule{Scope: "*", Resource: res},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := VerifyAccess(tc.Rules, tc.Account, res, VerifyContext(tc.Context))
+			if err != tc.Error {
+				t.Errorf("Expected %v but got %v", tc.Error, err)
+			}
+		})
+	}
+}
+
+func TestInWindow(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tt := []struct {
+		Name   string
+		Window *Window
+		Time   time.Time
+		Expect bool
+	}{
+		{"Within", &Window{Start: "09:00", End: "17:00"}, at("2020-01-01T12:00:00Z"), true},
+		{"Start", &Window{Start: "09:00", End: "17:00"}, at("2020-01-01T09:00:00Z"), true},
+		{"End", &Window{Start: "09:00", End: "17:00"}, at("2020-01-01T17:00:00Z"), false},
+		{"Before", &Window{Start: "09:00", End: "17:00"}, at("2020-01-01T08:59:00Z"), false},
+		{"OvernightLate", &Window{Start: "22:00", End: "06:00"}, at("2020-01-01T23:30:00Z"), true},
+		{"OvernightEarly", &Window{Start: "22:00", End: "06:00"}, at("2020-01-01T05:00:00Z"), true},
+		{"OvernightDay", &Window{Start: "22:00", End: "06:00"}, at("2020-01-01T12:00:00Z"), false},
+		{"Timezone", &Window{Start: "09:00", End: "17:00", Timezone: "America/New_York"}, at("2020-01-01T15:00:00Z"), true},
+		{"TimezoneOutside", &Window{Start: "09:00", End: "17:00", Timezone: "America/New_York"}, at("2020-01-01T12:00:00Z"), false},
+		{"InvalidTimezone", &Window{Start: "09:00", End: "17:00", Timezone: "Nowhere"}, at("2020-01-01T12:00:00Z"), false},
+		{"InvalidTime", &Window{Start: "9am", End: "17:00"}, at("2020-01-01T12:00:00Z"), false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			if got := inWindow(tc.Window, tc.Time); got != tc.Expect {
+				t.Errorf("Expected %v but got %v", tc.Expect, got)
+			}
+		})
+	}
+}
@@ -13,6 +13,11 @@ type Broker interface {
 	String() string
 }
 
+// Pinger is implemented by brokers which can check they're connected, e.g. for health checks
+type Pinger interface {
+	Ping() error
+}
+
 // Handler is used to process messages via a subscription of a topic.
 // The handler is passed a publication interface which contains the
 // message and optional Ack method to acknowledge receipt of the message.
@@ -26,6 +26,7 @@ import (
 	"github.com/micro/go-micro/v3/registry/mdns"
 	maddr "github.com/micro/go-micro/v3/util/addr"
 	mnet "github.com/micro/go-micro/v3/util/net"
+	"github.com/micro/go-micro/v3/util/ring"
 	mls "github.com/micro/go-micro/v3/util/tls"
 	"golang.org/x/net/http2"
 )
@@ -48,7 +49,9 @@ type httpBroker struct {
 
 	// offline message inbox
 	mtx   sync.RWMutex
-	inbox map[string][][]byte
+	inbox map[string][]*inboxMessage
+	// hash rings of the nodes of each queue
+	queues map[string]*queueRing
 }
 
 type httpSubscriber struct {
@@ -58,6 +61,20 @@ type httpSubscriber struct {
 	fn    broker.Handler
 	svc   *registry.Service
 	hb    *httpBroker
+	// the messages with the same partition key are handled one at a time
+	partitions broker.Partitions
+}
+
+// inboxMessage is an encoded message waiting to be published
+type inboxMessage struct {
+	key  string
+	body []byte
+}
+
+// queueRing assigns the partition keys of a queue's messages to its nodes
+type queueRing struct {
+	ring  *ring.Hash
+	nodes map[string]bool
 }
 
 type httpEvent struct {
@@ -136,7 +153,8 @@ func newHttpBroker(opts ...broker.Option) broker.Broker {
 		subscribers: make(map[string][]*httpSubscriber),
 		exit:        make(chan chan error),
 		mux:         http.NewServeMux(),
-		inbox:       make(map[string][][]byte),
+		inbox:       make(map[string][]*inboxMessage),
+		queues:      make(map[string]*queueRing),
 	}
 
 	// specify the message handler
@@ -183,7 +201,7 @@ func (h *httpSubscriber) Unsubscribe() error {
 	return h.hb.unsubscribe(h)
 }
 
-func (h *httpBroker) saveMessage(topic string, msg []byte) {
+func (h *httpBroker) saveMessage(topic string, msg *inboxMessage) {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
 
@@ -202,7 +220,7 @@ func (h *httpBroker) saveMessage(topic string, msg []byte) {
 	h.inbox[topic] = c
 }
 
-func (h *httpBroker) getMessage(topic string, num int) [][]byte {
+func (h *httpBroker) getMessage(topic string, num int) []*inboxMessage {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
 
@@ -226,6 +244,37 @@ func (h *httpBroker) getMessage(topic string, num int) [][]byte {
 	return c
 }
 
+// partition returns the node of the queue the partition key is assigned to. The ring of the
+// queue is kept between publishes and updated as its nodes change.
+func (h *httpBroker) partition(queue string, nodes []*registry.Node, key string) *registry.Node {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	q, ok := h.queues[queue]
+	if !ok {
+		q = &queueRing{ring: ring.NewHash(), nodes: make(map[string]bool)}
+		h.queues[queue] = q
+	}
+
+	current := make(map[string]*registry.Node, len(nodes))
+	for _, node := range nodes {
+		current[node.Id] = node
+		if !q.nodes[node.Id] {
+			q.ring.Add(node.Id)
+			q.nodes[node.Id] = true
+		}
+	}
+	for id := range q.nodes {
+		if _, ok := current[id]; !ok {
+			q.ring.Remove(id)
+			delete(q.nodes, id)
+		}
+	}
+
+	id, _ := q.ring.Get(key)
+	return current[id]
+}
+
 func (h *httpBroker) subscribe(s *httpSubscriber) error {
 	h.Lock()
 	defer h.Unlock()
@@ -332,20 +381,28 @@ func (h *httpBroker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	id := req.Form.Get("id")
 
 	//nolint:prealloc
-	var subs []broker.Handler
+	var subs []*httpSubscriber
 
 	h.RLock()
-	for _, subscriber := range h.subscribers[topic] {
-		if id != subscriber.id {
+	for pattern, subscribers := range h.subscribers {
+		if !broker.MatchTopic(pattern, topic) {
 			continue
 		}
-		subs = append(subs, subscriber.fn)
+		for _, subscriber := range subscribers {
+			if id != subscriber.id {
+				continue
+			}
+			subs = append(subs, subscriber)
+		}
 	}
 	h.RUnlock()
 
 	// execute the handler
-	for _, fn := range subs {
-		p.err = fn(p)
+	key := m.Header[broker.PartitionKeyHeader]
+	for _, sub := range subs {
+		unlock := sub.partitions.Lock(key)
+		p.err = sub.fn(p)
+		unlock()
 	}
 }
 
@@ -460,6 +517,16 @@ func (h *httpBroker) Disconnect() error {
 	return err
 }
 
+func (h *httpBroker) Ping() error {
+	h.RLock()
+	defer h.RUnlock()
+
+	if !h.running {
+		return errors.New("not connected")
+	}
+	return nil
+}
+
 func (h *httpBroker) Init(opts ...broker.Option) error {
 	h.RLock()
 	if h.running {
@@ -512,6 +579,11 @@ func (h *httpBroker) Options() broker.Options {
 }
 
 func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
 	// create the message first
 	m := &broker.Message{
 		Header: make(map[string]string),
@@ -523,6 +595,9 @@ func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.P
 	}
 
 	m.Header["Micro-Topic"] = topic
+	if len(options.PartitionKey) > 0 {
+		m.Header[broker.PartitionKeyHeader] = options.PartitionKey
+	}
 
 	// encode the message
 	b, err := h.opts.Codec.Marshal(m)
@@ -531,7 +606,7 @@ func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.P
 	}
 
 	// save the message
-	h.saveMessage(topic, b)
+	h.saveMessage(topic, &inboxMessage{key: options.PartitionKey, body: b})
 
 	// now attempt to get the service
 	h.RLock()
@@ -565,7 +640,7 @@ func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.P
 		return nil
 	}
 
-	srv := func(s []*registry.Service, b []byte) {
+	srv := func(s []*registry.Service, msg *inboxMessage) {
 		for _, service := range s {
 			var nodes []*registry.Node
 
@@ -575,8 +650,8 @@ func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.P
 					continue
 				}
 
-				// look for nodes for the topic
-				if node.Metadata["topic"] != topic {
+				// look for nodes for the topic or a wildcard topic matching it
+				if !broker.MatchTopic(node.Metadata["topic"], topic) {
 					continue
 				}
 
@@ -596,23 +671,27 @@ func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.P
 				// publish to all nodes
 				for _, node := range nodes {
 					// publish async
-					if err := pub(node, topic, b); err == nil {
+					if err := pub(node, topic, msg.body); err == nil {
 						success = true
 					}
 				}
 
 				// save if it failed to publish at least once
 				if !success {
-					h.saveMessage(topic, b)
+					h.saveMessage(topic, msg)
 				}
 			default:
-				// select node to publish to
+				// select node to publish to, the messages with a partition key are
+				// consistently published to the same node of the queue
 				node := nodes[rand.Int()%len(nodes)]
+				if len(msg.key) > 0 {
+					node = h.partition(topic+"/"+service.Version, nodes, msg.key)
+				}
 
 				// publish async to one node
-				if err := pub(node, topic, b); err != nil {
+				if err := pub(node, topic, msg.body); err != nil {
 					// if failed save it
-					h.saveMessage(topic, b)
+					h.saveMessage(topic, msg)
 				}
 			}
 		}
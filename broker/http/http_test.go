@@ -234,6 +234,126 @@ func TestBroker(t *testing.T) {
 	}
 }
 
+func TestPartitionBroker(t *testing.T) {
+	m := newTestRegistry()
+
+	msg := &broker.Message{
+		Header: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: []byte(`{"message": "Hello World"}`),
+	}
+
+	received := make(chan int, 100)
+
+	// a queue with a subscriber on each broker
+	var brokers []broker.Broker
+	for i := 0; i < 4; i++ {
+		i := i
+		b := NewBroker(broker.Registry(m))
+
+		if err := b.Init(); err != nil {
+			t.Fatalf("Unexpected init error: %v", err)
+		}
+
+		if err := b.Connect(); err != nil {
+			t.Fatalf("Unexpected connect error: %v", err)
+		}
+		defer b.Disconnect()
+
+		if _, err := b.Subscribe("test", func(p broker.Event) error {
+			received <- i
+			return nil
+		}, broker.Queue("shared")); err != nil {
+			t.Fatalf("Unexpected subscribe error: %v", err)
+		}
+		brokers = append(brokers, b)
+	}
+
+	// the messages with the same key are sent to the same subscriber of the queue
+	for i := 0; i < 20; i++ {
+		if err := brokers[0].Publish("test", msg, broker.PartitionKey("1")); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+
+	subs := make(map[int]bool)
+	for i := 0; i < 20; i++ {
+		select {
+		case sub := <-received:
+			subs[sub] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for message %d", i)
+		}
+	}
+	if len(subs) != 1 {
+		t.Fatalf("Expected the messages to be received by one subscriber, got %v", subs)
+	}
+}
+
+func TestWildcardBroker(t *testing.T) {
+	m := newTestRegistry()
+	b := NewBroker(broker.Registry(m))
+
+	if err := b.Init(); err != nil {
+		t.Fatalf("Unexpected init error: %v", err)
+	}
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+
+	subscribe := func(pattern string) chan string {
+		ch := make(chan string, 10)
+		if _, err := b.Subscribe(pattern, func(p broker.Event) error {
+			ch <- p.Topic()
+			return nil
+		}); err != nil {
+			t.Fatalf("Unexpected subscribe error: %v", err)
+		}
+		return ch
+	}
+
+	one := subscribe("orders.*")
+	all := subscribe("orders.>")
+
+	for _, topic := range []string{"orders.created", "orders.eu.created", "users.created"} {
+		if err := b.Publish(topic, &broker.Message{Body: []byte(topic)}); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+
+	receive := func(ch chan string, expected ...string) {
+		got := make(map[string]bool)
+		for range expected {
+			select {
+			case topic := <-ch:
+				got[topic] = true
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for %v", expected)
+			}
+		}
+		for _, topic := range expected {
+			if !got[topic] {
+				t.Fatalf("Expected to receive %s, got %v", topic, got)
+			}
+		}
+
+		select {
+		case topic := <-ch:
+			t.Fatalf("Unexpected message for %s", topic)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	receive(one, "orders.created")
+	receive(all, "orders.created", "orders.eu.created")
+
+	if err := b.Disconnect(); err != nil {
+		t.Fatalf("Unexpected disconnect error: %v", err)
+	}
+}
+
 func TestConcurrentSubBroker(t *testing.T) {
 	m := newTestRegistry()
 	b := NewBroker(broker.Registry(m))
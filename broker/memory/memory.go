@@ -13,6 +13,7 @@ import (
 	"github.com/micro/go-micro/v3/logger"
 	maddr "github.com/micro/go-micro/v3/util/addr"
 	mnet "github.com/micro/go-micro/v3/util/net"
+	"github.com/micro/go-micro/v3/util/ring"
 )
 
 type memoryBroker struct {
@@ -22,6 +23,15 @@ type memoryBroker struct {
 	sync.RWMutex
 	connected   bool
 	Subscribers map[string][]*memorySubscriber
+	// the members of the queues by topic and queue name
+	queues map[string]*memoryQueue
+}
+
+// memoryQueue is a queue of subscribers, the messages with a partition key are consistently
+// delivered to the member of the queue they hash to on the ring
+type memoryQueue struct {
+	members []*memorySubscriber
+	ring    *ring.Hash
 }
 
 type memoryEvent struct {
@@ -35,8 +45,11 @@ type memorySubscriber struct {
 	id      string
 	topic   string
 	exit    chan bool
+	cancel  context.CancelFunc
 	handler broker.Handler
 	opts    broker.SubscribeOptions
+	// messages with the same partition key are handled one at a time
+	partitions broker.Partitions
 }
 
 func (m *memoryBroker) Options() broker.Options {
@@ -83,6 +96,16 @@ func (m *memoryBroker) Disconnect() error {
 	return nil
 }
 
+func (m *memoryBroker) Ping() error {
+	m.RLock()
+	defer m.RUnlock()
+
+	if !m.connected {
+		return errors.New("not connected")
+	}
+	return nil
+}
+
 func (m *memoryBroker) Init(opts ...broker.Option) error {
 	for _, o := range opts {
 		o(&m.opts)
@@ -91,15 +114,28 @@ func (m *memoryBroker) Init(opts ...broker.Option) error {
 }
 
 func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
 	m.RLock()
 	if !m.connected {
 		m.RUnlock()
 		return errors.New("not connected")
 	}
 
-	subs, ok := m.Subscribers[topic]
+	// subscribers to the topic, then those to wildcard topics matching it
+	var subs []*memorySubscriber
+	subs = append(subs, m.Subscribers[topic]...)
+	for pattern, s := range m.Subscribers {
+		if pattern != topic && broker.MatchTopic(pattern, topic) {
+			subs = append(subs, s...)
+		}
+	}
+	subs = m.partition(subs, options.PartitionKey)
 	m.RUnlock()
-	if !ok {
+	if len(subs) == 0 {
 		return nil
 	}
 
@@ -121,7 +157,11 @@ func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker
 	}
 
 	for _, sub := range subs {
-		if err := sub.handler(p); err != nil {
+		unlock := sub.partitions.Lock(options.PartitionKey)
+		err := sub.handler(p)
+		unlock()
+
+		if err != nil {
 			p.err = err
 			if eh := m.opts.ErrorHandler; eh != nil {
 				eh(p)
@@ -134,6 +174,52 @@ func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker
 	return nil
 }
 
+// partition returns the subscribers to deliver a message to, those without a queue and a member
+// of each queue chosen by the partition key. It's called with the lock held.
+func (m *memoryBroker) partition(subs []*memorySubscriber, key string) []*memorySubscriber {
+	var picked []*memorySubscriber
+	queues := make(map[string]bool)
+
+	for _, sub := range subs {
+		if len(sub.opts.Queue) == 0 {
+			picked = append(picked, sub)
+			continue
+		}
+
+		name := queueName(sub)
+		if queues[name] {
+			continue
+		}
+		queues[name] = true
+
+		if q, ok := m.queues[name]; ok {
+			picked = append(picked, q.pick(key))
+		}
+	}
+
+	return picked
+}
+
+// pick the member of the queue to deliver the message to, a random member if there isn't
+// a partition key
+func (q *memoryQueue) pick(key string) *memorySubscriber {
+	if len(key) == 0 {
+		return q.members[rand.Intn(len(q.members))]
+	}
+
+	id, _ := q.ring.Get(key)
+	for _, sub := range q.members {
+		if sub.id == id {
+			return sub
+		}
+	}
+	return q.members[0]
+}
+
+func queueName(sub *memorySubscriber) string {
+	return sub.topic + "/" + sub.opts.Queue
+}
+
 func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
 	m.RLock()
 	if !m.connected {
@@ -147,16 +233,36 @@ func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...b
 		o(&options)
 	}
 
+	// the retries of the subscriber's messages stop once it unsubscribes
+	parent := options.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	retries := options
+	retries.Context = ctx
+
 	sub := &memorySubscriber{
 		exit:    make(chan bool, 1),
+		cancel:  cancel,
 		id:      uuid.New().String(),
 		topic:   topic,
-		handler: handler,
+		handler: broker.RetryHandler(m, handler, retries),
 		opts:    options,
 	}
 
 	m.Lock()
 	m.Subscribers[topic] = append(m.Subscribers[topic], sub)
+	if len(options.Queue) > 0 {
+		// add the subscriber to the ring of the queue once, rather than on every publish
+		q, ok := m.queues[queueName(sub)]
+		if !ok {
+			q = &memoryQueue{ring: ring.NewHash()}
+			m.queues[queueName(sub)] = q
+		}
+		q.members = append(q.members, sub)
+		q.ring.Add(sub.id)
+	}
 	m.Unlock()
 
 	go func() {
@@ -170,12 +276,31 @@ func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...b
 			newSubscribers = append(newSubscribers, sb)
 		}
 		m.Subscribers[topic] = newSubscribers
+		if q, ok := m.queues[queueName(sub)]; ok {
+			m.removeMember(q, sub)
+		}
 		m.Unlock()
 	}()
 
 	return sub, nil
 }
 
+// removeMember removes the subscriber from the queue, deleting the queue if it was the last.
+// It's called with the lock held.
+func (m *memoryBroker) removeMember(q *memoryQueue, sub *memorySubscriber) {
+	for i, member := range q.members {
+		if member.id == sub.id {
+			q.members = append(q.members[:i], q.members[i+1:]...)
+			break
+		}
+	}
+	q.ring.Remove(sub.id)
+
+	if len(q.members) == 0 {
+		delete(m.queues, queueName(sub))
+	}
+}
+
 func (m *memoryBroker) String() string {
 	return "memory"
 }
@@ -219,6 +344,7 @@ func (m *memorySubscriber) Topic() string {
 }
 
 func (m *memorySubscriber) Unsubscribe() error {
+	m.cancel()
 	m.exit <- true
 	return nil
 }
@@ -236,5 +362,6 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 	return &memoryBroker{
 		opts:        options,
 		Subscribers: make(map[string][]*memorySubscriber),
+		queues:      make(map[string]*memoryQueue),
 	}
 }
@@ -1,8 +1,11 @@
 package memory
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/micro/go-micro/v3/broker"
 )
@@ -48,3 +51,317 @@ func TestMemoryBroker(t *testing.T) {
 		t.Fatalf("Unexpected connect error %v", err)
 	}
 }
+
+func TestMemoryBrokerDeadLetter(t *testing.T) {
+	tt := []struct {
+		Name       string
+		Fail       int
+		Attempts   int
+		DeadLetter bool
+	}{
+		{Name: "Succeeded", Fail: 0, Attempts: 1},
+		{Name: "Retried", Fail: 2, Attempts: 3},
+		{Name: "DeadLettered", Fail: 3, Attempts: 3, DeadLetter: true},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			b := NewBroker()
+			if err := b.Connect(); err != nil {
+				t.Fatalf("Unexpected connect error %v", err)
+			}
+			defer b.Disconnect()
+
+			attempts := make(chan int, 10)
+			var count int
+			if _, err := b.Subscribe("test", func(e broker.Event) error {
+				count++
+				attempts <- count
+				if count <= tc.Fail {
+					return errors.New("failed")
+				}
+				return nil
+			}, broker.MaxAttempts(3), broker.DeadLetter("dead"), broker.Backoff(func(int) time.Duration {
+				return time.Millisecond
+			})); err != nil {
+				t.Fatalf("Unexpected error subscribing %v", err)
+			}
+
+			dead := make(chan *broker.Message, 1)
+			if _, err := b.Subscribe("dead", func(e broker.Event) error {
+				dead <- e.Message()
+				return nil
+			}); err != nil {
+				t.Fatalf("Unexpected error subscribing %v", err)
+			}
+
+			msg := &broker.Message{Header: map[string]string{"id": "1"}, Body: []byte(`hello world`)}
+			if err := b.Publish("test", msg); err != nil {
+				t.Fatalf("Unexpected error publishing %v", err)
+			}
+
+			for i := 1; i <= tc.Attempts; i++ {
+				select {
+				case <-attempts:
+				case <-time.After(time.Second):
+					t.Fatalf("Expected %d attempts, got %d", tc.Attempts, i-1)
+				}
+			}
+
+			if !tc.DeadLetter {
+				select {
+				case m := <-dead:
+					t.Fatalf("Unexpected dead letter %v", m.Header)
+				case <-time.After(50 * time.Millisecond):
+				}
+				return
+			}
+
+			var m *broker.Message
+			select {
+			case m = <-dead:
+			case <-time.After(time.Second):
+				t.Fatal("Expected a dead letter")
+			}
+			expected := map[string]string{
+				"id":                            "1",
+				broker.DeadLetterTopicHeader:    "test",
+				broker.DeadLetterErrorHeader:    "failed",
+				broker.DeadLetterAttemptsHeader: "3",
+			}
+			for k, v := range expected {
+				if got := m.Header[k]; got != v {
+					t.Fatalf("Expected header %s to be %s, got %s", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryBrokerWildcard(t *testing.T) {
+	b := NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	received := make(map[string][]string)
+	for _, pattern := range []string{"orders.created", "orders.*", "orders.>", "*.created"} {
+		pattern := pattern
+		if _, err := b.Subscribe(pattern, func(e broker.Event) error {
+			received[pattern] = append(received[pattern], e.Topic())
+			return nil
+		}); err != nil {
+			t.Fatalf("Unexpected error subscribing %v", err)
+		}
+	}
+
+	for _, topic := range []string{"orders.created", "orders.eu.created", "users.created"} {
+		if err := b.Publish(topic, &broker.Message{Body: []byte(`hello world`)}); err != nil {
+			t.Fatalf("Unexpected error publishing %v", err)
+		}
+	}
+
+	expected := map[string][]string{
+		"orders.created": {"orders.created"},
+		"orders.*":       {"orders.created"},
+		"orders.>":       {"orders.created", "orders.eu.created"},
+		"*.created":      {"orders.created", "users.created"},
+	}
+	for pattern, topics := range expected {
+		if got := fmt.Sprintf("%v", received[pattern]); got != fmt.Sprintf("%v", topics) {
+			t.Fatalf("Expected %s to receive %v, got %v", pattern, topics, got)
+		}
+	}
+}
+
+func TestMemoryBrokerPartitionKey(t *testing.T) {
+	b := NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	// the subscriber each message was delivered to by key
+	received := make(map[string]map[int]bool)
+	for i := 0; i < 3; i++ {
+		i := i
+		if _, err := b.Subscribe("orders", func(e broker.Event) error {
+			key := e.Message().Header["key"]
+			if received[key] == nil {
+				received[key] = make(map[int]bool)
+			}
+			received[key][i] = true
+			return nil
+		}, broker.Queue("queue")); err != nil {
+			t.Fatalf("Unexpected error subscribing %v", err)
+		}
+	}
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("order-%d", i%10)
+		msg := &broker.Message{Header: map[string]string{"key": key}, Body: []byte(`hello world`)}
+		if err := b.Publish("orders", msg, broker.PartitionKey(key)); err != nil {
+			t.Fatalf("Unexpected error publishing %v", err)
+		}
+	}
+
+	if len(received) != 10 {
+		t.Fatalf("Expected messages for 10 keys, got %d", len(received))
+	}
+	for key, subs := range received {
+		if len(subs) != 1 {
+			t.Fatalf("Expected the messages for %s delivered to one subscriber, got %d", key, len(subs))
+		}
+	}
+}
+
+func TestMemoryBrokerRetryUnsubscribe(t *testing.T) {
+	b := NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	attempts := make(chan bool, 10)
+	sub, err := b.Subscribe("test", func(e broker.Event) error {
+		attempts <- true
+		return errors.New("failed")
+	}, broker.MaxAttempts(3), broker.Backoff(func(int) time.Duration {
+		return time.Hour
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	// the message is retried before the publish returns
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Publish("test", &broker.Message{Body: []byte(`hello world`)})
+	}()
+	<-attempts
+
+	// the retries stop once the subscriber unsubscribes
+	sub.Unsubscribe()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Expected the error of the failed attempt")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the retries to stop")
+	}
+	if len(attempts) != 0 {
+		t.Fatalf("Expected no more attempts, got %d", len(attempts))
+	}
+}
+
+func TestMemoryBrokerRetryOrder(t *testing.T) {
+	b := NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	var mtx sync.Mutex
+	var handled []string
+	failed := make(chan bool)
+	if _, err := b.Subscribe("test", func(e broker.Event) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		id := e.Message().Header["id"]
+		if id == "1" && failed != nil {
+			close(failed)
+			failed = nil
+			return errors.New("failed")
+		}
+		handled = append(handled, id)
+		return nil
+	}, broker.MaxAttempts(3), broker.Backoff(func(int) time.Duration {
+		return 10 * time.Millisecond
+	})); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	first := failed
+	done := make(chan bool)
+	go func() {
+		b.Publish("test", &broker.Message{Header: map[string]string{"id": "1"}}, broker.PartitionKey("key"))
+		close(done)
+	}()
+
+	// the message with the same key published whilst the first is retried waits for it
+	<-first
+	b.Publish("test", &broker.Message{Header: map[string]string{"id": "2"}}, broker.PartitionKey("key"))
+	<-done
+
+	if len(handled) != 2 || handled[0] != "1" || handled[1] != "2" {
+		t.Fatalf("Expected the messages to be handled in order, got %v", handled)
+	}
+}
+
+func TestMemoryBrokerQueueUnsubscribe(t *testing.T) {
+	b := NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	received := make(chan int, 10)
+	var subs []broker.Subscriber
+	for i := 0; i < 2; i++ {
+		i := i
+		sub, err := b.Subscribe("orders", func(e broker.Event) error {
+			received <- i
+			return nil
+		}, broker.Queue("queue"))
+		if err != nil {
+			t.Fatalf("Unexpected error subscribing %v", err)
+		}
+		subs = append(subs, sub)
+	}
+
+	// find the member the key is assigned to and unsubscribe it
+	if err := b.Publish("orders", &broker.Message{}, broker.PartitionKey("order-1")); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+	member := <-received
+	subs[member].Unsubscribe()
+
+	// the queue's ring is updated once the member has unsubscribed
+	mb := b.(*memoryBroker)
+	for i := 0; ; i++ {
+		mb.RLock()
+		n := len(mb.queues["orders/queue"].members)
+		mb.RUnlock()
+		if n == 1 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("Expected the member to be removed from the queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := b.Publish("orders", &broker.Message{}, broker.PartitionKey("order-1")); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+	if got := <-received; got == member {
+		t.Fatalf("Expected the key to be reassigned from member %d", member)
+	}
+
+	subs[1-member].Unsubscribe()
+	for i := 0; ; i++ {
+		mb.RLock()
+		_, ok := mb.queues["orders/queue"]
+		mb.RUnlock()
+		if !ok {
+			break
+		}
+		if i > 100 {
+			t.Fatal("Expected the queue to be removed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -83,6 +83,16 @@ func (n *natsBroker) Address() string {
 	return ""
 }
 
+func (n *natsBroker) Ping() error {
+	n.RLock()
+	defer n.RUnlock()
+
+	if n.conn == nil || !n.conn.IsConnected() {
+		return errors.New("not connected")
+	}
+	return nil
+}
+
 func (n *natsBroker) setAddrs(addrs []string) []string {
 	//nolint:prealloc
 	var cAddrs []string
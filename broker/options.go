@@ -3,6 +3,7 @@ package broker
 import (
 	"context"
 	"crypto/tls"
+	"time"
 
 	"github.com/micro/go-micro/v3/codec"
 	"github.com/micro/go-micro/v3/registry"
@@ -26,6 +27,10 @@ type Options struct {
 }
 
 type PublishOptions struct {
+	// PartitionKey of the message, queue subscribers consistently receive the
+	// messages with the same key and handle them one at a time
+	PartitionKey string
+
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -39,6 +44,16 @@ type SubscribeOptions struct {
 	// will create a shared subscription where each
 	// receives a subset of messages.
 	Queue string
+	// MaxAttempts is the number of times a message is delivered before it's
+	// moved to the dead letter topic, or dropped if there isn't one. The
+	// broker's default applies if zero.
+	MaxAttempts int
+	// Backoff returns how long to wait before redelivering a message after the
+	// attempt failed, util/backoff is used if nil
+	Backoff func(attempt int) time.Duration
+	// DeadLetter is the topic messages are published to once they've failed
+	// MaxAttempts times
+	DeadLetter string
 
 	// Other options for implementations of the interface
 	// can be stored in a context
@@ -56,6 +71,18 @@ func PublishContext(ctx context.Context) PublishOption {
 	}
 }
 
+// PartitionKey sets the partition key of the message, e.g. the id of the
+// entity it's about so its messages are handled in order. It's honoured by
+// the memory and store brokers. The http broker sends the messages with the
+// same key to the same node, which handles them one at a time, but they're
+// published asynchronously so may arrive out of order. The nats and tunnel
+// brokers ignore it.
+func PartitionKey(key string) PublishOption {
+	return func(o *PublishOptions) {
+		o.PartitionKey = key
+	}
+}
+
 type SubscribeOption func(*SubscribeOptions)
 
 func NewSubscribeOptions(opts ...SubscribeOption) SubscribeOptions {
@@ -101,6 +128,29 @@ func ErrorHandler(h Handler) Option {
 	}
 }
 
+// MaxAttempts sets the number of times a message is delivered before it's
+// moved to the dead letter topic or dropped
+func MaxAttempts(n int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.MaxAttempts = n
+	}
+}
+
+// Backoff sets how long to wait before redelivering a failed message
+func Backoff(fn func(attempt int) time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Backoff = fn
+	}
+}
+
+// DeadLetter sets the topic messages are published to once they've failed
+// the max attempts
+func DeadLetter(topic string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.DeadLetter = topic
+	}
+}
+
 // Queue sets the name of the queue to share messages on
 func Queue(name string) SubscribeOption {
 	return func(o *SubscribeOptions) {
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// ProcessedPrefix is the prefix of the ids of the events each consumer has processed
+	ProcessedPrefix = "processed/"
+	// DefaultRetention is how long the ids of the processed events are kept
+	DefaultRetention = 7 * 24 * time.Hour
+	// ProcessingLease is how long an event is claimed for whilst it's handled. If the consumer
+	// stops before handling it the claim expires, so the event is handled when it's redelivered.
+	ProcessingLease = time.Minute
+)
+
+// Idempotent wraps the handler so it handles each event relayed from an outbox once. The ids of
+// the events it handles are recorded in the store under the consumer's name, the events with a
+// recorded id are acked without being handled again. Messages without an id are always handled.
+func Idempotent(s store.Store, name string, h broker.Handler) broker.Handler {
+	return func(e broker.Event) error {
+		id := e.Message().Header[IdHeader]
+
+		handled, err := once(s, name, id, func() error {
+			return h(e)
+		})
+		if err != nil {
+			return err
+		}
+		if !handled {
+			// a duplicate, ack it so it isn't redelivered
+			return e.Ack()
+		}
+
+		return nil
+	}
+}
+
+// IdempotentSubscriber returns a subscriber wrapper which handles each event relayed from an
+// outbox once, the same as Idempotent
+func IdempotentSubscriber(s store.Store, name string) server.SubscriberWrapper {
+	return func(fn server.SubscriberFunc) server.SubscriberFunc {
+		return func(ctx context.Context, msg server.Message) error {
+			_, err := once(s, name, msg.Header()[IdHeader], func() error {
+				return fn(ctx, msg)
+			})
+			return err
+		}
+	}
+}
+
+// once calls fn unless the event with the id has been processed by the consumer. The id is
+// claimed for the ProcessingLease before fn is called, so concurrent deliveries of the event
+// don't both call it, and kept for the DefaultRetention once fn succeeds. The claim is released
+// if fn fails so the event is handled when it's redelivered. It returns whether fn was called.
+func once(s store.Store, name, id string, fn func() error) (bool, error) {
+	if len(id) == 0 {
+		return true, fn()
+	}
+
+	key := ProcessedPrefix + name + "/" + id
+	claim := []byte(uuid.New().String())
+
+	err := s.Write(&store.Record{
+		Key:    key,
+		Value:  claim,
+		Expiry: ProcessingLease,
+	}, store.WriteIfVersion(0))
+	if err == store.ErrVersionConflict {
+		// processed, or being processed, by another delivery
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := fn(); err != nil {
+		if derr := s.Delete(key); derr != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[outbox] failed to release event %s of %s: %v", id, name, derr)
+			}
+		}
+		return true, err
+	}
+
+	if err := retain(s, key, claim); err != nil {
+		// the event is handled again if it's redelivered after the claim expires
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[outbox] failed to record event %s of %s as processed: %v", id, name, err)
+		}
+	}
+
+	return true, nil
+}
+
+// retain keeps the claimed id for the DefaultRetention, unless the claim expired and the event
+// has been claimed by another delivery
+func retain(s store.Store, key string, claim []byte) error {
+	recs, err := s.Read(key)
+	if err == store.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(recs) == 0 || !bytes.Equal(recs[0].Value, claim) {
+		return nil
+	}
+
+	err = s.Write(&store.Record{
+		Key:    key,
+		Value:  []byte(time.Now().Format(time.RFC3339)),
+		Expiry: DefaultRetention,
+	}, store.WriteIfVersion(recs[0].Version))
+	if err == store.ErrVersionConflict {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/store"
+)
+
+type Options struct {
+	// Store the events are written to, it must be transactional
+	Store store.Store
+	// Broker the relay publishes the events to
+	Broker broker.Broker
+	// Database and Table the events are written to, the business data must be
+	// written to the same as the transactions apply to a single table
+	Database, Table string
+	// Interval between the relay checking for events
+	Interval time.Duration
+}
+
+type Option func(o *Options)
+
+// Store sets the store the events are written to
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Broker sets the broker the events are published to
+func Broker(b broker.Broker) Option {
+	return func(o *Options) {
+		o.Broker = b
+	}
+}
+
+// Database sets the database the events are written to
+func Database(db string) Option {
+	return func(o *Options) {
+		o.Database = db
+	}
+}
+
+// Table sets the table the events are written to
+func Table(t string) Option {
+	return func(o *Options) {
+		o.Table = t
+	}
+}
+
+// Interval sets how often the relay checks for events
+func Interval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
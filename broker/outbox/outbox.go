@@ -0,0 +1,272 @@
+// Package outbox provides a transactional outbox. Events are written to the store in the same
+// transaction as the business data and a relay publishes them to the broker, so an event is
+// published if and only if the data is written. Events are delivered at least once, consumers
+// use the id in the IdHeader to ignore duplicates.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/store"
+)
+
+const (
+	// IdHeader is the header of the event's id, which is the same every time it's published
+	IdHeader = "Micro-Outbox-Id"
+)
+
+var (
+	// DefaultInterval is how often the relay checks for events
+	DefaultInterval = time.Second
+	// EventsPrefix is the prefix of the events waiting to be published
+	EventsPrefix = "outbox/"
+	// FailedPrefix is the prefix the events which can't be decoded are moved to, so they don't
+	// hold up those written after them
+	FailedPrefix = "outbox-failed/"
+	// BatchSize is the number of events published at a time
+	BatchSize uint = 100
+
+	// ErrNotTransactional is returned when the store can't write the events with the data
+	ErrNotTransactional = errors.New("store is not transactional")
+	// ErrMissingBroker is returned by the relay when the outbox has no broker to publish to
+	ErrMissingBroker = errors.New("missing broker")
+)
+
+// Event is a message to publish to the topic once the transaction it's written in is applied
+type Event struct {
+	Id        string            `json:"id"`
+	Topic     string            `json:"topic"`
+	Header    map[string]string `json:"header"`
+	Body      []byte            `json:"body"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// NewEvent returns an event to publish the message to the topic
+func NewEvent(topic string, msg *broker.Message) *Event {
+	return &Event{
+		Id:        uuid.New().String(),
+		Topic:     topic,
+		Header:    msg.Header,
+		Body:      msg.Body,
+		Timestamp: time.Now(),
+	}
+}
+
+// key orders the events by the time they were created
+func (e *Event) key() string {
+	return fmt.Sprintf("%s%020d/%s", EventsPrefix, e.Timestamp.UnixNano(), e.Id)
+}
+
+// Outbox writes events with the business data and relays them to the broker
+type Outbox struct {
+	options Options
+
+	// wakes the relay once events are written
+	notify chan bool
+	// the events are relayed one at a time
+	relay sync.Mutex
+
+	sync.Mutex
+	running bool
+	exit    chan bool
+	done    chan bool
+}
+
+// NewOutbox returns an outbox, the store defaults to store.DefaultStore and the broker must be
+// set for the events to be relayed
+func NewOutbox(opts ...Option) *Outbox {
+	options := Options{
+		Store:    store.DefaultStore,
+		Interval: DefaultInterval,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &Outbox{
+		options: options,
+		notify:  make(chan bool, 1),
+	}
+}
+
+// Options returns the options of the outbox
+func (o *Outbox) Options() Options {
+	return o.options
+}
+
+// Transact applies the operations, e.g. the writes of the business data, and writes the events
+// in a single transaction. Nothing is written if the transaction fails.
+func (o *Outbox) Transact(ops []store.Operation, events ...*Event) error {
+	tx, ok := o.options.Store.(store.Transactional)
+	if !ok {
+		return ErrNotTransactional
+	}
+
+	for _, ev := range events {
+		val, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, store.WriteOp(&store.Record{Key: ev.key(), Value: val}))
+	}
+
+	if err := tx.Transact(ops, store.TransactIn(o.options.Database, o.options.Table)); err != nil {
+		return err
+	}
+
+	// publish the events without waiting for the interval
+	if len(events) > 0 {
+		select {
+		case o.notify <- true:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Relay publishes the events in the outbox in the order they were written, deleting them once
+// published. It returns the number of events published, stopping at the first which fails so
+// it's retried before those written after it. Events which can't be decoded are moved to the
+// FailedPrefix. Relay is called by the relay which is started, or can be called directly.
+func (o *Outbox) Relay() (int, error) {
+	if o.options.Broker == nil {
+		return 0, ErrMissingBroker
+	}
+
+	o.relay.Lock()
+	defer o.relay.Unlock()
+
+	var count int
+
+	for {
+		recs, err := o.options.Store.Read(EventsPrefix,
+			store.ReadPrefix(),
+			store.ReadLimit(BatchSize),
+			store.ReadFrom(o.options.Database, o.options.Table),
+		)
+		if err == store.ErrNotFound {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+
+		for _, rec := range recs {
+			var ev *Event
+			if err := json.Unmarshal(rec.Value, &ev); err != nil || ev == nil {
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("[outbox] failed to decode event %s, moving it to %s: %v", rec.Key, FailedPrefix, err)
+				}
+				if err := o.moveAside(rec); err != nil {
+					return count, err
+				}
+				continue
+			}
+
+			header := make(map[string]string, len(ev.Header)+1)
+			for k, v := range ev.Header {
+				header[k] = v
+			}
+			header[IdHeader] = ev.Id
+
+			if err := o.options.Broker.Publish(ev.Topic, &broker.Message{Header: header, Body: ev.Body}); err != nil {
+				return count, err
+			}
+			count++
+
+			// if the delete fails the event is published again, consumers ignore it by its id
+			if err := o.options.Store.Delete(rec.Key, store.DeleteFrom(o.options.Database, o.options.Table)); err != nil {
+				return count, err
+			}
+		}
+
+		if uint(len(recs)) < BatchSize {
+			return count, nil
+		}
+	}
+}
+
+// moveAside moves the record of an event which can't be decoded to the FailedPrefix
+func (o *Outbox) moveAside(rec *store.Record) error {
+	failed := &store.Record{
+		Key:   FailedPrefix + strings.TrimPrefix(rec.Key, EventsPrefix),
+		Value: rec.Value,
+	}
+
+	if tx, ok := o.options.Store.(store.Transactional); ok {
+		return tx.Transact([]store.Operation{
+			store.WriteOp(failed),
+			store.DeleteOp(rec.Key),
+		}, store.TransactIn(o.options.Database, o.options.Table))
+	}
+
+	if err := o.options.Store.Write(failed, store.WriteTo(o.options.Database, o.options.Table)); err != nil {
+		return err
+	}
+	return o.options.Store.Delete(rec.Key, store.DeleteFrom(o.options.Database, o.options.Table))
+}
+
+// Start the relay, which publishes the events every interval or once they're written
+func (o *Outbox) Start() error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.running {
+		return nil
+	}
+
+	o.running = true
+	o.exit = make(chan bool)
+	o.done = make(chan bool)
+
+	go o.run(o.exit, o.done)
+
+	return nil
+}
+
+// Stop the relay, waiting for it to finish publishing
+func (o *Outbox) Stop() error {
+	o.Lock()
+	defer o.Unlock()
+
+	if !o.running {
+		return nil
+	}
+
+	close(o.exit)
+	<-o.done
+	o.running = false
+
+	return nil
+}
+
+func (o *Outbox) run(exit, done chan bool) {
+	defer close(done)
+
+	t := time.NewTicker(o.options.Interval)
+	defer t.Stop()
+
+	for {
+		if _, err := o.Relay(); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[outbox] failed to relay events: %v", err)
+			}
+		}
+
+		select {
+		case <-exit:
+			return
+		case <-o.notify:
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package outbox
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/broker"
+	bmemory "github.com/micro/go-micro/v3/broker/memory"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+func newBroker(t *testing.T) broker.Broker {
+	b := bmemory.NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	return b
+}
+
+func TestOutbox(t *testing.T) {
+	s := memory.NewStore()
+	b := newBroker(t)
+	defer b.Disconnect()
+
+	var received []*broker.Message
+	if _, err := b.Subscribe("orders", func(e broker.Event) error {
+		received = append(received, e.Message())
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	o := NewOutbox(Store(s), Broker(b))
+
+	created := NewEvent("orders", &broker.Message{Header: map[string]string{"type": "created"}, Body: []byte(`123`)})
+	paid := NewEvent("orders", &broker.Message{Header: map[string]string{"type": "paid"}, Body: []byte(`123`)})
+	ops := []store.Operation{store.WriteOp(&store.Record{Key: "order/123", Value: []byte(`paid`)})}
+	if err := o.Transact(ops, created, paid); err != nil {
+		t.Fatalf("Unexpected error writing events %v", err)
+	}
+
+	// the events aren't published until they're relayed
+	if len(received) > 0 {
+		t.Fatalf("Unexpected %d messages before relaying", len(received))
+	}
+	if recs, err := s.Read("order/123"); err != nil || len(recs) != 1 {
+		t.Fatalf("Expected the order to be written, got %v %v", recs, err)
+	}
+
+	n, err := o.Relay()
+	if err != nil {
+		t.Fatalf("Unexpected error relaying %v", err)
+	}
+	if n != 2 || len(received) != 2 {
+		t.Fatalf("Expected 2 events relayed, got %d published %d received", n, len(received))
+	}
+	for i, ev := range []*Event{created, paid} {
+		if got := received[i].Header[IdHeader]; got != ev.Id {
+			t.Fatalf("Expected event %d to have id %s, got %s", i, ev.Id, got)
+		}
+		if got := received[i].Header["type"]; got != ev.Header["type"] {
+			t.Fatalf("Expected event %d to be %s, got %s", i, ev.Header["type"], got)
+		}
+	}
+
+	// the events are deleted once published
+	if n, err := o.Relay(); err != nil || n != 0 {
+		t.Fatalf("Expected no events to relay, got %d %v", n, err)
+	}
+}
+
+func TestOutboxTransact(t *testing.T) {
+	t.Run("NotTransactional", func(t *testing.T) {
+		o := NewOutbox()
+		err := o.Transact(nil, NewEvent("orders", &broker.Message{}))
+		if err != ErrNotTransactional {
+			t.Fatalf("Expected %v, got %v", ErrNotTransactional, err)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		s := memory.NewStore()
+		if err := s.Write(&store.Record{Key: "order/123", Value: []byte(`created`)}); err != nil {
+			t.Fatalf("Unexpected error writing %v", err)
+		}
+
+		o := NewOutbox(Store(s), Broker(newBroker(t)))
+
+		// the stored order has moved on so neither the order or the event are written
+		ops := []store.Operation{store.WriteOp(&store.Record{Key: "order/123", Value: []byte(`paid`)}).IfVersion(100)}
+		if err := o.Transact(ops, NewEvent("orders", &broker.Message{})); err != store.ErrVersionConflict {
+			t.Fatalf("Expected %v, got %v", store.ErrVersionConflict, err)
+		}
+		if n, err := o.Relay(); err != nil || n != 0 {
+			t.Fatalf("Expected no events to relay, got %d %v", n, err)
+		}
+	})
+
+	t.Run("MissingBroker", func(t *testing.T) {
+		o := NewOutbox(Store(memory.NewStore()))
+		if _, err := o.Relay(); err != ErrMissingBroker {
+			t.Fatalf("Expected %v, got %v", ErrMissingBroker, err)
+		}
+	})
+}
+
+func TestOutboxUndecodable(t *testing.T) {
+	s := memory.NewStore()
+	b := newBroker(t)
+	defer b.Disconnect()
+
+	var received []string
+	if _, err := b.Subscribe("orders", func(e broker.Event) error {
+		received = append(received, e.Message().Header[IdHeader])
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	o := NewOutbox(Store(s), Broker(b))
+
+	// a record which isn't an event, written before the event
+	bad := &store.Record{Key: EventsPrefix + "0/bad", Value: []byte(`{`)}
+	if err := s.Write(bad); err != nil {
+		t.Fatalf("Unexpected error writing %v", err)
+	}
+	ev := NewEvent("orders", &broker.Message{})
+	if err := o.Transact(nil, ev); err != nil {
+		t.Fatalf("Unexpected error writing events %v", err)
+	}
+
+	// the record is moved aside rather than holding up the event
+	n, err := o.Relay()
+	if err != nil {
+		t.Fatalf("Unexpected error relaying %v", err)
+	}
+	if n != 1 || len(received) != 1 || received[0] != ev.Id {
+		t.Fatalf("Expected event %s to be relayed, got %d published %v received", ev.Id, n, received)
+	}
+	if _, err := s.Read(bad.Key); err != store.ErrNotFound {
+		t.Fatalf("Expected the record to be moved, got %v", err)
+	}
+	recs, err := s.Read(FailedPrefix + "0/bad")
+	if err != nil || len(recs) != 1 || string(recs[0].Value) != string(bad.Value) {
+		t.Fatalf("Expected the record to be moved to %s, got %v %v", FailedPrefix, recs, err)
+	}
+}
+
+func TestOutboxStart(t *testing.T) {
+	b := newBroker(t)
+	defer b.Disconnect()
+
+	ch := make(chan string, 10)
+	if _, err := b.Subscribe("orders", func(e broker.Event) error {
+		ch <- e.Message().Header[IdHeader]
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	o := NewOutbox(Store(memory.NewStore()), Broker(b), Interval(time.Minute))
+	if err := o.Start(); err != nil {
+		t.Fatalf("Unexpected error starting %v", err)
+	}
+	defer o.Stop()
+
+	// the relay publishes the events once they're written rather than waiting for the interval
+	ev := NewEvent("orders", &broker.Message{})
+	if err := o.Transact(nil, ev); err != nil {
+		t.Fatalf("Unexpected error writing events %v", err)
+	}
+
+	select {
+	case id := <-ch:
+		if id != ev.Id {
+			t.Fatalf("Expected event %s, got %s", ev.Id, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the event")
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	s := memory.NewStore()
+	b := newBroker(t)
+	defer b.Disconnect()
+
+	var handled []string
+	fail := true
+	if _, err := b.Subscribe("orders", Idempotent(s, "consumer", func(e broker.Event) error {
+		id := e.Message().Header[IdHeader]
+		if id == "2" && fail {
+			fail = false
+			return errors.New("failed")
+		}
+		handled = append(handled, id)
+		return nil
+	})); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	for _, id := range []string{"1", "1", "2", "2", "", ""} {
+		msg := &broker.Message{Header: map[string]string{IdHeader: id}}
+		b.Publish("orders", msg)
+	}
+
+	// duplicates are ignored, failed events are handled again and those without an id always
+	expected := []string{"1", "2", "", ""}
+	if len(handled) != len(expected) {
+		t.Fatalf("Expected %v handled, got %v", expected, handled)
+	}
+	for i, id := range expected {
+		if handled[i] != id {
+			t.Fatalf("Expected %v handled, got %v", expected, handled)
+		}
+	}
+}
+
+func TestIdempotentConcurrent(t *testing.T) {
+	s := memory.NewStore()
+
+	// the event is redelivered whilst the first delivery is being handled
+	started := make(chan bool)
+	release := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		handled, err := once(s, "consumer", "1", func() error {
+			close(started)
+			<-release
+			return nil
+		})
+		if !handled || err != nil {
+			t.Errorf("Expected the first delivery to be handled, got %v %v", handled, err)
+		}
+	}()
+	<-started
+
+	handled, err := once(s, "consumer", "1", func() error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if handled {
+		t.Fatal("Expected the redelivery not to be handled")
+	}
+
+	close(release)
+	<-done
+}
+
+func TestIdempotentLease(t *testing.T) {
+	s := memory.NewStore()
+	key := ProcessedPrefix + "consumer/1"
+
+	lease := ProcessingLease
+	ProcessingLease = 50 * time.Millisecond
+	defer func() { ProcessingLease = lease }()
+
+	// the consumer stops whilst handling the event, so the claim isn't released
+	if err := s.Write(&store.Record{Key: key, Expiry: ProcessingLease}, store.WriteIfVersion(0)); err != nil {
+		t.Fatalf("Unexpected error writing %v", err)
+	}
+	if handled, err := once(s, "consumer", "1", func() error { return nil }); handled || err != nil {
+		t.Fatalf("Expected the event not to be handled whilst claimed, got %v %v", handled, err)
+	}
+
+	// the event is handled when it's redelivered once the claim expires
+	time.Sleep(2 * ProcessingLease)
+	handled, err := once(s, "consumer", "1", func() error {
+		recs, err := s.Read(key)
+		if err != nil || len(recs) != 1 || recs[0].Expiry > ProcessingLease {
+			t.Errorf("Expected the event to be claimed for the lease, got %v %v", recs, err)
+		}
+		return nil
+	})
+	if !handled || err != nil {
+		t.Fatalf("Expected the event to be handled, got %v %v", handled, err)
+	}
+
+	// and the id is kept once it's handled
+	recs, err := s.Read(key)
+	if err != nil || len(recs) != 1 || recs[0].Expiry <= ProcessingLease {
+		t.Fatalf("Expected the id to be kept for the retention, got %v %v", recs, err)
+	}
+}
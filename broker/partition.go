@@ -0,0 +1,50 @@
+package broker
+
+import "sync"
+
+// PartitionKeyHeader is the header of the partition key for brokers which pass it with the message
+const PartitionKeyHeader = "Micro-Partition-Key"
+
+// Partitions serialises the handling of messages by partition key, the messages with the same
+// key are handled one at a time while those with different keys are handled in parallel
+type Partitions struct {
+	mtx  sync.Mutex
+	keys map[string]*partition
+}
+
+type partition struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock the partition key, the returned func unlocks it. Messages without a key aren't locked.
+func (p *Partitions) Lock(key string) func() {
+	if len(key) == 0 {
+		return func() {}
+	}
+
+	p.mtx.Lock()
+	if p.keys == nil {
+		p.keys = make(map[string]*partition)
+	}
+	part, ok := p.keys[key]
+	if !ok {
+		part = new(partition)
+		p.keys[key] = part
+	}
+	part.refs++
+	p.mtx.Unlock()
+
+	part.Lock()
+
+	return func() {
+		part.Unlock()
+
+		p.mtx.Lock()
+		part.refs--
+		if part.refs == 0 {
+			delete(p.keys, key)
+		}
+		p.mtx.Unlock()
+	}
+}
@@ -0,0 +1,44 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPartitions(t *testing.T) {
+	var p Partitions
+	var mtx sync.Mutex
+	running := make(map[string]int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("order-%d", i%2)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.Lock(key)()
+
+			mtx.Lock()
+			running[key]++
+			n := running[key]
+			mtx.Unlock()
+
+			if n > 1 {
+				t.Errorf("Expected messages with key %s to be handled one at a time", key)
+			}
+			time.Sleep(time.Millisecond)
+
+			mtx.Lock()
+			running[key]--
+			mtx.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if len(p.keys) != 0 {
+		t.Fatalf("Expected the keys to be released, got %d", len(p.keys))
+	}
+}
@@ -0,0 +1,108 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/micro/go-micro/v3/util/backoff"
+)
+
+const (
+	// DeadLetterTopicHeader is the header of the topic a dead lettered message was published to
+	DeadLetterTopicHeader = "Micro-Dead-Letter-Topic"
+	// DeadLetterErrorHeader is the header of the error the message last failed with
+	DeadLetterErrorHeader = "Micro-Dead-Letter-Error"
+	// DeadLetterAttemptsHeader is the header of the number of times the message was delivered
+	DeadLetterAttemptsHeader = "Micro-Dead-Letter-Attempts"
+)
+
+// RetryBackoff returns how long to wait before redelivering a message after the attempt failed
+func (o SubscribeOptions) RetryBackoff(attempt int) time.Duration {
+	if o.Backoff != nil {
+		return o.Backoff(attempt)
+	}
+	return backoff.Do(attempt)
+}
+
+// DeadLetterMessage returns a copy of the message with the reason it failed in the headers
+func DeadLetterMessage(topic string, msg *Message, attempts int, err error) *Message {
+	header := make(map[string]string, len(msg.Header)+3)
+	for k, v := range msg.Header {
+		header[k] = v
+	}
+	header[DeadLetterTopicHeader] = topic
+	header[DeadLetterAttemptsHeader] = fmt.Sprintf("%d", attempts)
+	if err != nil {
+		header[DeadLetterErrorHeader] = err.Error()
+	}
+
+	return &Message{
+		Header: header,
+		Body:   msg.Body,
+	}
+}
+
+// RetryHandler wraps the handler so failed messages are retried, waiting for the backoff
+// between attempts, until the subscriber's max attempts are reached. The message is then
+// published to the dead letter topic, if there is one. The retries happen before the handler
+// returns, so messages with the same partition key are still handled in order. They stop once
+// the subscriber's context is done, returning the error so brokers which redeliver messages
+// can do so. The handler is returned unchanged if max attempts isn't set.
+func RetryHandler(b Broker, h Handler, opts SubscribeOptions) Handler {
+	if opts.MaxAttempts <= 0 {
+		return h
+	}
+
+	return func(e Event) error {
+		var err error
+		for attempt := 1; ; attempt++ {
+			if err = h(e); err == nil {
+				return nil
+			}
+			if attempt >= opts.MaxAttempts {
+				break
+			}
+			if !wait(opts.Context, opts.RetryBackoff(attempt)) {
+				return err
+			}
+		}
+
+		return deadLetter(b, e, opts, err)
+	}
+}
+
+// wait for the duration, returning false if the context is done first
+func wait(ctx context.Context, d time.Duration) bool {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// deadLetter publishes the message to the dead letter topic and acks it, the error is
+// returned if there isn't a dead letter topic
+func deadLetter(b Broker, e Event, opts SubscribeOptions, err error) error {
+	if len(opts.DeadLetter) == 0 {
+		return err
+	}
+	if perr := b.Publish(opts.DeadLetter, DeadLetterMessage(e.Topic(), e.Message(), opts.MaxAttempts, err)); perr != nil {
+		return perr
+	}
+
+	// the message was moved to the dead letter topic, it's acked by the broker if auto ack
+	// is enabled
+	if opts.AutoAck {
+		return nil
+	}
+	return e.Ack()
+}
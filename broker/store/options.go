@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/store"
+)
+
+type storeKey struct{}
+type ackTimeoutKey struct{}
+type retentionKey struct{}
+
+// Store persists the messages, offsets and unacked events. It defaults to the memory store,
+// use a durable store such as the file store for messages to survive restarts.
+func Store(s store.Store) broker.Option {
+	return setBrokerOption(storeKey{}, s)
+}
+
+// AckTimeout is how long to wait for an event to be acked before it's redelivered
+func AckTimeout(d time.Duration) broker.Option {
+	return setBrokerOption(ackTimeoutKey{}, d)
+}
+
+// Retention is how long messages are kept for, they're kept indefinitely if zero
+func Retention(d time.Duration) broker.Option {
+	return setBrokerOption(retentionKey{}, d)
+}
+
+// setBrokerOption returns a function to setup a context with given value
+func setBrokerOption(k, v interface{}) broker.Option {
+	return func(o *broker.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
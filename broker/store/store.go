@@ -0,0 +1,695 @@
+// Package store provides a durable broker which persists messages in a store. Queues track
+// their offset in each topic and events which aren't acked within the ack timeout are
+// redelivered, giving at-least-once delivery on a single node.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/memory"
+	"github.com/micro/go-micro/v3/util/ring"
+)
+
+var (
+	// DefaultAckTimeout is how long to wait for an event to be acked before it's redelivered
+	DefaultAckTimeout = 30 * time.Second
+	// DefaultRetention is how long messages are kept for
+	DefaultRetention = 24 * time.Hour
+
+	// MessagesPrefix is the prefix of the messages, keyed by topic and sequence
+	MessagesPrefix = "messages/"
+	// SequencePrefix is the prefix of the last sequence published to each topic
+	SequencePrefix = "sequence/"
+	// OffsetsPrefix is the prefix of the next sequence to deliver to each queue
+	OffsetsPrefix = "offsets/"
+	// PendingPrefix is the prefix of the events delivered to each queue but not yet acked
+	PendingPrefix = "pending/"
+
+	// BatchSize is the number of messages read from the store at a time
+	BatchSize uint = 100
+)
+
+type storeBroker struct {
+	opts broker.Options
+
+	sync.RWMutex
+	connected  bool
+	store      store.Store
+	ackTimeout time.Duration
+	retention  time.Duration
+	// last sequence published to each topic
+	sequences map[string]uint64
+	// queues by topic and name
+	queues map[string]map[string]*queue
+}
+
+// queue of subscribers which share the messages published to a topic. Subscribers without a
+// queue get their own which isn't durable, they only receive messages published after they
+// subscribe.
+type queue struct {
+	broker     *storeBroker
+	store      store.Store
+	ackTimeout time.Duration
+	topic      string
+	name       string
+	durable    bool
+	notify     chan bool
+	exit       chan bool
+
+	// next sequence to deliver, only used by the run loop
+	offset uint64
+
+	sync.Mutex
+	subscribers []*storeSubscriber
+	next        int
+	// assigns the messages with a partition key to the subscribers
+	ring *ring.Hash
+}
+
+type storeSubscriber struct {
+	id      string
+	queue   *queue
+	handler broker.Handler
+	opts    broker.SubscribeOptions
+}
+
+type storeEvent struct {
+	topic   string
+	message *broker.Message
+	err     error
+	ack     func() error
+}
+
+// pending is an event which has been delivered but not acked
+type pending struct {
+	Attempts int       `json:"attempts"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// sequenceKey formats the sequence so the keys sort in order
+func sequenceKey(seq uint64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+func parseSequence(key, prefix string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(key, prefix), 10, 64)
+}
+
+// escape the topic or queue name for use in a key, so it doesn't contain the "/" separating
+// it from the rest of the key and the keys of nested topics don't share its prefix
+func escape(name string) string {
+	return url.PathEscape(name)
+}
+
+func messagesKey(topic string) string {
+	return MessagesPrefix + escape(topic) + "/"
+}
+
+func (b *storeBroker) Options() broker.Options {
+	return b.opts
+}
+
+func (b *storeBroker) Address() string {
+	return ""
+}
+
+func (b *storeBroker) Connect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	b.connected = true
+	return nil
+}
+
+func (b *storeBroker) Disconnect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.connected {
+		return nil
+	}
+
+	// stop delivering, the durable queues continue from their offsets once resubscribed
+	for _, queues := range b.queues {
+		for _, q := range queues {
+			close(q.exit)
+		}
+	}
+	b.queues = make(map[string]map[string]*queue)
+	b.connected = false
+
+	return nil
+}
+
+func (b *storeBroker) Ping() error {
+	b.RLock()
+	defer b.RUnlock()
+
+	if !b.connected {
+		return errors.New("not connected")
+	}
+	return nil
+}
+
+func (b *storeBroker) Init(opts ...broker.Option) error {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, o := range opts {
+		o(&b.opts)
+	}
+	b.configure()
+	return nil
+}
+
+func (b *storeBroker) configure() {
+	ctx := b.opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if s, ok := ctx.Value(storeKey{}).(store.Store); ok {
+		b.store = s
+	}
+	if d, ok := ctx.Value(ackTimeoutKey{}).(time.Duration); ok && d > 0 {
+		b.ackTimeout = d
+	}
+	if d, ok := ctx.Value(retentionKey{}).(time.Duration); ok {
+		b.retention = d
+	}
+}
+
+// sequence returns the last sequence published to the topic, the lock must be held
+func (b *storeBroker) sequence(topic string) (uint64, error) {
+	if seq, ok := b.sequences[topic]; ok {
+		return seq, nil
+	}
+
+	var seq uint64
+	recs, err := b.store.Read(SequencePrefix + escape(topic))
+	if err == nil && len(recs) > 0 {
+		seq, err = strconv.ParseUint(string(recs[0].Value), 10, 64)
+	}
+	if err != nil && err != store.ErrNotFound {
+		return 0, err
+	}
+
+	b.sequences[topic] = seq
+	return seq, nil
+}
+
+func (b *storeBroker) Publish(topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var options broker.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// the partition key is kept with the message so it's delivered to the same subscriber
+	if len(options.PartitionKey) > 0 {
+		header := make(map[string]string, len(msg.Header)+1)
+		for k, v := range msg.Header {
+			header[k] = v
+		}
+		header[broker.PartitionKeyHeader] = options.PartitionKey
+		msg = &broker.Message{Header: header, Body: msg.Body}
+	}
+
+	val, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	b.Lock()
+	if !b.connected {
+		b.Unlock()
+		return errors.New("not connected")
+	}
+
+	seq, err := b.sequence(topic)
+	if err != nil {
+		b.Unlock()
+		return err
+	}
+	seq++
+
+	// write the sequence first, if the message isn't written the sequence is skipped
+	if err := b.store.Write(&store.Record{Key: SequencePrefix + escape(topic), Value: []byte(strconv.FormatUint(seq, 10))}); err != nil {
+		b.Unlock()
+		return err
+	}
+	b.sequences[topic] = seq
+
+	rec := &store.Record{
+		Key:    messagesKey(topic) + sequenceKey(seq),
+		Value:  val,
+		Expiry: b.retention,
+	}
+	if err := b.store.Write(rec); err != nil {
+		b.Unlock()
+		return err
+	}
+
+	queues := make([]*queue, 0, len(b.queues[topic]))
+	for _, q := range b.queues[topic] {
+		queues = append(queues, q)
+	}
+	b.Unlock()
+
+	for _, q := range queues {
+		q.wake()
+	}
+
+	return nil
+}
+
+func (b *storeBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	options := broker.NewSubscribeOptions(opts...)
+
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.connected {
+		return nil, errors.New("not connected")
+	}
+
+	name := options.Queue
+	if len(name) == 0 {
+		name = uuid.New().String()
+	}
+
+	q, ok := b.queues[topic][name]
+	if !ok {
+		q = &queue{
+			broker:     b,
+			store:      b.store,
+			ackTimeout: b.ackTimeout,
+			topic:      topic,
+			name:       name,
+			durable:    len(options.Queue) > 0,
+			notify:     make(chan bool, 1),
+			exit:       make(chan bool),
+			ring:       ring.NewHash(),
+		}
+
+		if q.durable {
+			// continue from the queue's offset or the first message if it's new
+			recs, err := b.store.Read(q.offsetKey())
+			if err == nil && len(recs) > 0 {
+				q.offset, err = strconv.ParseUint(string(recs[0].Value), 10, 64)
+			}
+			if err != nil && err != store.ErrNotFound {
+				return nil, err
+			}
+		} else {
+			seq, err := b.sequence(topic)
+			if err != nil {
+				return nil, err
+			}
+			q.offset = seq + 1
+		}
+
+		if b.queues[topic] == nil {
+			b.queues[topic] = make(map[string]*queue)
+		}
+		b.queues[topic][name] = q
+		go q.run()
+	}
+
+	sub := &storeSubscriber{
+		id:      uuid.New().String(),
+		queue:   q,
+		handler: handler,
+		opts:    options,
+	}
+
+	q.Lock()
+	q.subscribers = append(q.subscribers, sub)
+	q.ring.Add(sub.id)
+	q.Unlock()
+	q.wake()
+
+	return sub, nil
+}
+
+func (b *storeBroker) String() string {
+	return "store"
+}
+
+func (q *queue) offsetKey() string {
+	return OffsetsPrefix + escape(q.topic) + "/" + escape(q.name)
+}
+
+func (q *queue) pendingKey() string {
+	return PendingPrefix + escape(q.topic) + "/" + escape(q.name) + "/"
+}
+
+func (q *queue) wake() {
+	select {
+	case q.notify <- true:
+	default:
+	}
+}
+
+// run delivers the messages to the queue's subscribers until they've all unsubscribed
+func (q *queue) run() {
+	t := time.NewTicker(q.ackTimeout / 2)
+	defer t.Stop()
+
+	for {
+		q.redeliver()
+		q.deliver()
+
+		select {
+		case <-q.exit:
+			q.close()
+			return
+		case <-q.notify:
+		case <-t.C:
+		}
+	}
+}
+
+// close deletes the pending events of a queue which isn't durable
+func (q *queue) close() {
+	if q.durable {
+		return
+	}
+
+	recs, err := q.store.Read(q.pendingKey(), store.ReadPrefix())
+	if err != nil {
+		return
+	}
+	for _, rec := range recs {
+		q.store.Delete(rec.Key)
+	}
+}
+
+func (q *queue) stopped() bool {
+	select {
+	case <-q.exit:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver the messages published since the queue's offset
+func (q *queue) deliver() {
+	prefix := messagesKey(q.topic)
+	start := prefix + sequenceKey(q.offset)
+
+	for !q.stopped() {
+		// the range ends at the character after the digits
+		recs, err := q.store.Read(prefix, store.ReadPrefix(), store.ReadRange(start, prefix+":"), store.ReadLimit(BatchSize))
+		if err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[store] failed to read messages of %s: %v", q.topic, err)
+			}
+			return
+		}
+		if len(recs) == 0 {
+			return
+		}
+
+		for _, rec := range recs {
+			if q.stopped() {
+				return
+			}
+
+			// the next batch starts after the record, even if it isn't a message, so it
+			// isn't read again
+			start = rec.Key + "\x00"
+
+			seq, err := parseSequence(rec.Key, prefix)
+			if err != nil {
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("[store] skipping invalid message key %s of %s", rec.Key, q.topic)
+				}
+				continue
+			}
+
+			// record the delivery before moving the offset past the message, so it's
+			// redelivered if it isn't acked, even after a restart
+			p := pending{Attempts: 1, Deadline: time.Now().Add(q.ackTimeout)}
+			if err := q.writePending(seq, p); err != nil {
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("[store] failed to write pending event of %s: %v", q.topic, err)
+				}
+				return
+			}
+
+			q.offset = seq + 1
+			if q.durable {
+				if err := q.store.Write(&store.Record{Key: q.offsetKey(), Value: []byte(strconv.FormatUint(q.offset, 10))}); err != nil {
+					if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+						logger.Errorf("[store] failed to write offset of %s: %v", q.topic, err)
+					}
+				}
+			}
+
+			q.dispatch(seq, p, rec.Value)
+		}
+	}
+}
+
+// redeliver the events which weren't acked in time
+func (q *queue) redeliver() {
+	prefix := q.pendingKey()
+
+	recs, err := q.store.Read(prefix, store.ReadPrefix())
+	if err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[store] failed to read pending events of %s: %v", q.topic, err)
+		}
+		return
+	}
+
+	for _, rec := range recs {
+		if q.stopped() {
+			return
+		}
+
+		seq, err := parseSequence(rec.Key, prefix)
+		if err != nil {
+			continue
+		}
+
+		var p pending
+		if err := json.Unmarshal(rec.Value, &p); err != nil || time.Now().Before(p.Deadline) {
+			continue
+		}
+
+		msgs, err := q.store.Read(messagesKey(q.topic) + sequenceKey(seq))
+		if err == store.ErrNotFound || (err == nil && len(msgs) == 0) {
+			// the message is no longer retained
+			q.store.Delete(rec.Key)
+			continue
+		} else if err != nil {
+			continue
+		}
+
+		p.Attempts++
+		p.Deadline = time.Now().Add(q.ackTimeout)
+		if err := q.writePending(seq, p); err != nil {
+			continue
+		}
+
+		q.dispatch(seq, p, msgs[0].Value)
+	}
+}
+
+func (q *queue) writePending(seq uint64, p pending) error {
+	val, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return q.store.Write(&store.Record{Key: q.pendingKey() + sequenceKey(seq), Value: val})
+}
+
+// dispatch the message to the next subscriber
+func (q *queue) dispatch(seq uint64, p pending, val []byte) {
+	var msg *broker.Message
+	if err := json.Unmarshal(val, &msg); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[store] failed to unmarshal message of %s: %v", q.topic, err)
+		}
+		return
+	}
+
+	q.Lock()
+	if len(q.subscribers) == 0 {
+		q.Unlock()
+		return
+	}
+	sub := q.subscribers[q.next%len(q.subscribers)]
+	q.next++
+	// the messages with a partition key go to the subscriber it hashes to, since the
+	// queue delivers one message at a time they're handled in order
+	if key := msg.Header[broker.PartitionKeyHeader]; len(key) > 0 {
+		id, _ := q.ring.Get(key)
+		for _, s := range q.subscribers {
+			if s.id == id {
+				sub = s
+				break
+			}
+		}
+	}
+	q.Unlock()
+
+	key := q.pendingKey() + sequenceKey(seq)
+	ev := &storeEvent{
+		topic:   q.topic,
+		message: msg,
+		ack: func() error {
+			return q.store.Delete(key)
+		},
+	}
+
+	max := sub.opts.MaxAttempts
+	if max > 0 && p.Attempts > max {
+		// the last attempt wasn't acked in time
+		q.deadLetter(sub, ev, max, errors.New("not acked"))
+		return
+	}
+
+	if err := sub.handler(ev); err != nil {
+		ev.err = err
+		if eh := q.broker.opts.ErrorHandler; eh != nil {
+			eh(ev)
+		}
+
+		// the event is redelivered after the ack timeout, or the backoff if the
+		// subscriber has max attempts
+		if max <= 0 {
+			return
+		}
+		if p.Attempts >= max {
+			q.deadLetter(sub, ev, max, err)
+			return
+		}
+		p.Deadline = time.Now().Add(sub.opts.RetryBackoff(p.Attempts))
+		if err := q.writePending(seq, p); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[store] failed to write pending event of %s: %v", q.topic, err)
+			}
+		}
+		return
+	}
+
+	if sub.opts.AutoAck {
+		if err := ev.Ack(); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[store] failed to ack event of %s: %v", q.topic, err)
+			}
+		}
+	}
+}
+
+// deadLetter publishes the event to the subscriber's dead letter topic, if it has one, and
+// acks it so it isn't delivered again
+func (q *queue) deadLetter(sub *storeSubscriber, ev *storeEvent, attempts int, err error) {
+	if len(sub.opts.DeadLetter) > 0 {
+		msg := broker.DeadLetterMessage(q.topic, ev.message, attempts, err)
+		if err := q.broker.Publish(sub.opts.DeadLetter, msg); err != nil {
+			// the event is redelivered and dead lettered again
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[store] failed to publish event of %s to %s: %v", q.topic, sub.opts.DeadLetter, err)
+			}
+			return
+		}
+	}
+
+	if err := ev.Ack(); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[store] failed to ack event of %s: %v", q.topic, err)
+		}
+	}
+}
+
+func (e *storeEvent) Topic() string {
+	return e.topic
+}
+
+func (e *storeEvent) Message() *broker.Message {
+	return e.message
+}
+
+func (e *storeEvent) Ack() error {
+	return e.ack()
+}
+
+func (e *storeEvent) Error() error {
+	return e.err
+}
+
+func (s *storeSubscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *storeSubscriber) Topic() string {
+	return s.queue.topic
+}
+
+func (s *storeSubscriber) Unsubscribe() error {
+	q := s.queue
+	b := q.broker
+
+	b.Lock()
+	defer b.Unlock()
+
+	q.Lock()
+	for i, sub := range q.subscribers {
+		if sub == s {
+			q.subscribers = append(q.subscribers[:i], q.subscribers[i+1:]...)
+			q.ring.Remove(s.id)
+			break
+		}
+	}
+	empty := len(q.subscribers) == 0
+	q.Unlock()
+
+	// stop the queue once it has no subscribers, unless the broker already has
+	if empty && b.queues[q.topic][q.name] == q {
+		delete(b.queues[q.topic], q.name)
+		close(q.exit)
+	}
+
+	return nil
+}
+
+func NewBroker(opts ...broker.Option) broker.Broker {
+	options := broker.Options{
+		Context: context.Background(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	b := &storeBroker{
+		opts:       options,
+		ackTimeout: DefaultAckTimeout,
+		retention:  DefaultRetention,
+		sequences:  make(map[string]uint64),
+		queues:     make(map[string]map[string]*queue),
+	}
+	b.configure()
+
+	if b.store == nil {
+		b.store = memory.NewStore()
+	}
+
+	return b
+}
@@ -0,0 +1,390 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+func newMessage(i int) *broker.Message {
+	return &broker.Message{
+		Header: map[string]string{"id": fmt.Sprintf("%d", i)},
+		Body:   []byte(`hello world`),
+	}
+}
+
+func receive(t *testing.T, ch chan string, expected ...string) {
+	for _, id := range expected {
+		select {
+		case got := <-ch:
+			if got != id {
+				t.Fatalf("Expected message %v, got %v", id, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for message %v", id)
+		}
+	}
+
+	select {
+	case got := <-ch:
+		t.Fatalf("Unexpected message %v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStoreBroker(t *testing.T) {
+	b := NewBroker(AckTimeout(100 * time.Millisecond))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	// messages are kept until a queue subscribes
+	if err := b.Publish("test", newMessage(1)); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+
+	ch := make(chan string, 10)
+	sub, err := b.Subscribe("test", func(e broker.Event) error {
+		ch <- e.Message().Header["id"]
+		return nil
+	}, broker.Queue("queue"))
+	if err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+	receive(t, ch, "1")
+
+	// subscribers without a queue only receive new messages
+	latest := make(chan string, 10)
+	if _, err := b.Subscribe("test", func(e broker.Event) error {
+		latest <- e.Message().Header["id"]
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	if err := b.Publish("test", newMessage(2)); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+	receive(t, ch, "2")
+	receive(t, latest, "2")
+
+	// the queue continues from its offset once resubscribed
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatalf("Unexpected error unsubscribing %v", err)
+	}
+	if err := b.Publish("test", newMessage(3)); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+	if _, err := b.Subscribe("test", func(e broker.Event) error {
+		ch <- e.Message().Header["id"]
+		return nil
+	}, broker.Queue("queue")); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+	receive(t, ch, "3")
+	receive(t, latest, "3")
+}
+
+func TestStoreBrokerRedelivery(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Options []broker.SubscribeOption
+		Handler func(e broker.Event, attempt int) error
+	}{
+		{
+			Name: "HandlerError",
+			Handler: func(e broker.Event, attempt int) error {
+				if attempt == 1 {
+					return errors.New("failed")
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "NotAcked",
+			Options: []broker.SubscribeOption{broker.DisableAutoAck()},
+			Handler: func(e broker.Event, attempt int) error {
+				if attempt == 1 {
+					return nil
+				}
+				return e.Ack()
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := NewBroker(AckTimeout(100 * time.Millisecond))
+			if err := b.Connect(); err != nil {
+				t.Fatalf("Unexpected connect error %v", err)
+			}
+			defer b.Disconnect()
+
+			ch := make(chan string, 10)
+			var attempts int
+			opts := append(tc.Options, broker.Queue("queue"))
+			if _, err := b.Subscribe("test", func(e broker.Event) error {
+				attempts++
+				ch <- e.Message().Header["id"]
+				return tc.Handler(e, attempts)
+			}, opts...); err != nil {
+				t.Fatalf("Unexpected error subscribing %v", err)
+			}
+
+			if err := b.Publish("test", newMessage(1)); err != nil {
+				t.Fatalf("Unexpected error publishing %v", err)
+			}
+
+			// delivered, then redelivered once the ack timeout passes
+			receive(t, ch, "1")
+			time.Sleep(100 * time.Millisecond)
+			receive(t, ch, "1")
+
+			// not redelivered once acked
+			time.Sleep(200 * time.Millisecond)
+			receive(t, ch)
+		})
+	}
+}
+
+func TestStoreBrokerRestart(t *testing.T) {
+	s := memory.NewStore()
+
+	b := NewBroker(Store(s), AckTimeout(100*time.Millisecond))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	ch := make(chan string, 10)
+	if _, err := b.Subscribe("test", func(e broker.Event) error {
+		ch <- e.Message().Header["id"]
+		if e.Message().Header["id"] == "1" {
+			return e.Ack()
+		}
+		return nil
+	}, broker.Queue("queue"), broker.DisableAutoAck()); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := b.Publish("test", newMessage(i)); err != nil {
+			t.Fatalf("Unexpected error publishing %v", err)
+		}
+	}
+	receive(t, ch, "1", "2")
+	b.Disconnect()
+
+	// a new broker with the same store redelivers the unacked event and continues
+	// from the queue's offset
+	b = NewBroker(Store(s), AckTimeout(100*time.Millisecond))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	if err := b.Publish("test", newMessage(3)); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+
+	// wait for the unacked event to time out
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := b.Subscribe("test", func(e broker.Event) error {
+		ch <- e.Message().Header["id"]
+		return nil
+	}, broker.Queue("queue")); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+	receive(t, ch, "2", "3")
+}
+
+func TestStoreBrokerDeadLetter(t *testing.T) {
+	tt := []struct {
+		Name    string
+		Options []broker.SubscribeOption
+		Handler func(e broker.Event) error
+		Error   string
+	}{
+		{
+			Name: "HandlerError",
+			Handler: func(e broker.Event) error {
+				return errors.New("failed")
+			},
+			Error: "failed",
+		},
+		{
+			Name:    "NotAcked",
+			Options: []broker.SubscribeOption{broker.DisableAutoAck()},
+			Handler: func(e broker.Event) error {
+				return nil
+			},
+			Error: "not acked",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := NewBroker(AckTimeout(100 * time.Millisecond))
+			if err := b.Connect(); err != nil {
+				t.Fatalf("Unexpected connect error %v", err)
+			}
+			defer b.Disconnect()
+
+			ch := make(chan string, 10)
+			opts := append(tc.Options,
+				broker.Queue("queue"),
+				broker.MaxAttempts(2),
+				broker.DeadLetter("dead"),
+				broker.Backoff(func(int) time.Duration { return 10 * time.Millisecond }),
+			)
+			if _, err := b.Subscribe("test", func(e broker.Event) error {
+				ch <- e.Message().Header["id"]
+				return tc.Handler(e)
+			}, opts...); err != nil {
+				t.Fatalf("Unexpected error subscribing %v", err)
+			}
+
+			dead := make(chan *broker.Message, 10)
+			if _, err := b.Subscribe("dead", func(e broker.Event) error {
+				dead <- e.Message()
+				return nil
+			}, broker.Queue("queue")); err != nil {
+				t.Fatalf("Unexpected error subscribing %v", err)
+			}
+
+			if err := b.Publish("test", newMessage(1)); err != nil {
+				t.Fatalf("Unexpected error publishing %v", err)
+			}
+
+			// delivered the max attempts then moved to the dead letter topic
+			receive(t, ch, "1")
+			time.Sleep(100 * time.Millisecond)
+			receive(t, ch, "1")
+
+			select {
+			case msg := <-dead:
+				if got := msg.Header[broker.DeadLetterErrorHeader]; got != tc.Error {
+					t.Fatalf("Expected error header %v, got %v", tc.Error, got)
+				}
+				if got := msg.Header[broker.DeadLetterAttemptsHeader]; got != "2" {
+					t.Fatalf("Expected attempts header 2, got %v", got)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Timed out waiting for dead letter")
+			}
+
+			// not delivered again
+			time.Sleep(200 * time.Millisecond)
+			receive(t, ch)
+		})
+	}
+}
+
+func TestStoreBrokerPartitionKey(t *testing.T) {
+	b := NewBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	type delivery struct {
+		sub int
+		key string
+		seq string
+	}
+	ch := make(chan delivery, 100)
+	for i := 0; i < 3; i++ {
+		i := i
+		if _, err := b.Subscribe("orders", func(e broker.Event) error {
+			ch <- delivery{sub: i, key: e.Message().Header[broker.PartitionKeyHeader], seq: e.Message().Header["id"]}
+			return nil
+		}, broker.Queue("queue")); err != nil {
+			t.Fatalf("Unexpected error subscribing %v", err)
+		}
+	}
+
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("order-%d", i%5)
+		if err := b.Publish("orders", newMessage(i), broker.PartitionKey(key)); err != nil {
+			t.Fatalf("Unexpected error publishing %v", err)
+		}
+	}
+
+	subs := make(map[string]int)
+	last := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		var d delivery
+		select {
+		case d = <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for message %d", i)
+		}
+
+		// the messages with the same key go to the same subscriber, in order
+		if sub, ok := subs[d.key]; ok && sub != d.sub {
+			t.Fatalf("Expected the messages for %s delivered to subscriber %d, got %d", d.key, sub, d.sub)
+		}
+		subs[d.key] = d.sub
+
+		var seq int
+		fmt.Sscanf(d.seq, "%d", &seq)
+		if prev, ok := last[d.key]; ok && seq < prev {
+			t.Fatalf("Expected the messages for %s in order, got %d after %d", d.key, seq, prev)
+		}
+		last[d.key] = seq
+	}
+}
+
+// countingStore counts the reads of the store
+type countingStore struct {
+	store.Store
+	reads int64
+}
+
+func (c *countingStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
+	atomic.AddInt64(&c.reads, 1)
+	return c.Store.Read(key, opts...)
+}
+
+func TestStoreBrokerNestedTopics(t *testing.T) {
+	s := &countingStore{Store: memory.NewStore()}
+	b := NewBroker(Store(s), AckTimeout(time.Minute))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	ch := make(chan string, 10)
+	if _, err := b.Subscribe("orders", func(e broker.Event) error {
+		ch <- e.Message().Header["id"]
+		return nil
+	}, broker.Queue("queue")); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	// the messages of nested topics and keys which aren't messages are skipped
+	if err := s.Write(&store.Record{Key: messagesKey("orders") + "1x"}); err != nil {
+		t.Fatalf("Unexpected error writing %v", err)
+	}
+	if err := b.Publish("orders/1", newMessage(1)); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+	if err := b.Publish("orders", newMessage(2)); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+	receive(t, ch, "2")
+
+	// the queue stops reading once it's delivered the messages
+	reads := atomic.LoadInt64(&s.reads)
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt64(&s.reads) - reads; n > 0 {
+		t.Fatalf("Expected the queue to stop reading, got %d reads", n)
+	}
+}
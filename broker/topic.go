@@ -0,0 +1,48 @@
+package broker
+
+import "strings"
+
+const (
+	// TopicSeparator separates the tokens of a topic
+	TopicSeparator = "."
+	// WildcardToken matches any single token of a topic
+	WildcardToken = "*"
+	// TailToken matches one or more tokens at the end of a topic
+	TailToken = ">"
+)
+
+// IsWildcard reports whether the topic is a pattern which contains wildcard tokens
+func IsWildcard(topic string) bool {
+	for _, tok := range strings.Split(topic, TopicSeparator) {
+		if tok == WildcardToken || tok == TailToken {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchTopic reports whether the topic matches the pattern subscribed to. The "*" token
+// matches any single token and a trailing ">" matches one or more tokens, so "orders.*"
+// matches "orders.created" and "orders.>" matches "orders.eu.created".
+func MatchTopic(pattern, topic string) bool {
+	if pattern == topic {
+		return true
+	}
+
+	pats := strings.Split(pattern, TopicSeparator)
+	toks := strings.Split(topic, TopicSeparator)
+
+	for i, pat := range pats {
+		if pat == TailToken && i == len(pats)-1 {
+			return len(toks) > i
+		}
+		if i >= len(toks) {
+			return false
+		}
+		if pat != WildcardToken && pat != toks[i] {
+			return false
+		}
+	}
+
+	return len(pats) == len(toks)
+}
@@ -0,0 +1,51 @@
+package broker
+
+import "testing"
+
+func TestMatchTopic(t *testing.T) {
+	tt := []struct {
+		Pattern string
+		Topic   string
+		Match   bool
+	}{
+		{Pattern: "orders", Topic: "orders", Match: true},
+		{Pattern: "orders", Topic: "orders.created", Match: false},
+		{Pattern: "orders.*", Topic: "orders.created", Match: true},
+		{Pattern: "orders.*", Topic: "orders", Match: false},
+		{Pattern: "orders.*", Topic: "orders.eu.created", Match: false},
+		{Pattern: "orders.*.created", Topic: "orders.eu.created", Match: true},
+		{Pattern: "orders.*.created", Topic: "orders.eu.deleted", Match: false},
+		{Pattern: "*.created", Topic: "orders.created", Match: true},
+		{Pattern: "orders.>", Topic: "orders.created", Match: true},
+		{Pattern: "orders.>", Topic: "orders.eu.created", Match: true},
+		{Pattern: "orders.>", Topic: "orders", Match: false},
+		{Pattern: "orders.>", Topic: "users.created", Match: false},
+		{Pattern: ">", Topic: "orders.created", Match: true},
+		{Pattern: "orders.>.created", Topic: "orders.eu.created", Match: false},
+		{Pattern: "go.micro.*", Topic: "go.micro.events", Match: true},
+	}
+
+	for _, tc := range tt {
+		if got := MatchTopic(tc.Pattern, tc.Topic); got != tc.Match {
+			t.Errorf("Expected %s matching %s to be %t, got %t", tc.Pattern, tc.Topic, tc.Match, got)
+		}
+	}
+}
+
+func TestIsWildcard(t *testing.T) {
+	tt := map[string]bool{
+		"orders":          false,
+		"orders.created":  false,
+		"orders.*":        true,
+		"orders.>":        true,
+		"*.created":       true,
+		"orders*":         false,
+		"go.micro.events": false,
+	}
+
+	for topic, wildcard := range tt {
+		if got := IsWildcard(topic); got != wildcard {
+			t.Errorf("Expected %s wildcard to be %t, got %t", topic, wildcard, got)
+		}
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/micro/go-micro/v3/errors"
 	"github.com/micro/go-micro/v3/metadata"
 	"github.com/micro/go-micro/v3/registry"
+	"github.com/micro/go-micro/v3/selector"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -28,6 +29,8 @@ type grpcClient struct {
 	opts client.Options
 	pool *pool
 	once atomic.Value
+	// latency of the requests, used to hedge them
+	latency *client.Latency
 }
 
 func init() {
@@ -83,8 +86,8 @@ func (g *grpcClient) call(ctx context.Context, node *registry.Node, req client.R
 		header = make(map[string]string)
 	}
 
-	// set timeout in nanoseconds
-	header["timeout"] = fmt.Sprintf("%d", opts.RequestTimeout)
+	// set timeout in nanoseconds, it's the time remaining when retried or hedged
+	header["timeout"] = fmt.Sprintf("%d", client.Remaining(ctx, opts.RequestTimeout))
 	// set the content type for the request
 	header["x-content-type"] = req.ContentType()
 
@@ -158,9 +161,9 @@ func (g *grpcClient) stream(ctx context.Context, node *registry.Node, req client
 		header = make(map[string]string)
 	}
 
-	// set timeout in nanoseconds
-	if opts.StreamTimeout > time.Duration(0) {
-		header["timeout"] = fmt.Sprintf("%d", opts.StreamTimeout)
+	// set timeout in nanoseconds, propagating the deadline of the context
+	if timeout := client.Remaining(ctx, opts.StreamTimeout); timeout > time.Duration(0) {
+		header["timeout"] = fmt.Sprintf("%d", timeout)
 	}
 	// set the content type for the request
 	header["x-content-type"] = req.ContentType()
@@ -389,38 +392,37 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 		gcall = callOpts.CallWrappers[i-1](gcall)
 	}
 
-	// return errors.New("go.micro.client", "request timeout", 408)
-	call := func(i int) error {
-		// call backoff first. Someone may want an initial start delay
-		t, err := callOpts.Backoff(ctx, req, i)
-		if err != nil {
-			return errors.InternalServerError("go.micro.client", err.Error())
-		}
+	// use the router passed as a call option, or fallback to the rpc clients router
+	if callOpts.Router == nil {
+		callOpts.Router = g.opts.Router
+	}
+	// use the selector passed as a call option, or fallback to the rpc clients selector
+	if callOpts.Selector == nil {
+		callOpts.Selector = g.opts.Selector
+	}
 
-		// only sleep if greater than 0
-		if t.Seconds() > 0 {
-			time.Sleep(t)
-		}
+	// inject proxy address
+	if len(g.opts.Proxy) > 0 {
+		callOpts.Address = []string{g.opts.Proxy}
+	}
 
-		// use the router passed as a call option, or fallback to the rpc clients router
-		if callOpts.Router == nil {
-			callOpts.Router = g.opts.Router
-		}
-		// use the selector passed as a call option, or fallback to the rpc clients selector
-		if callOpts.Selector == nil {
-			callOpts.Selector = g.opts.Selector
-		}
+	// hedged requests are sent to another node where possible
+	hedge := g.latency.HedgeDelay(req, callOpts)
+	exclude := client.NewExclude()
 
-		// inject proxy address
-		if len(g.opts.Proxy) > 0 {
-			callOpts.Address = []string{g.opts.Proxy}
+	// return errors.New("go.micro.client", "request timeout", 408)
+	call := func(ctx context.Context, rsp interface{}) error {
+		opts := callOpts
+		if hedge > 0 {
+			opts.SelectOptions = append(opts.SelectOptions[:len(opts.SelectOptions):len(opts.SelectOptions)], selector.WithFilter(exclude.Filter))
 		}
 
 		// lookup the route to send the reques to
-		route, err := client.LookupRoute(req, callOpts)
+		route, err := client.LookupRoute(req, opts)
 		if err != nil {
-			return errors.InternalServerError("go.micro.client", err.Error())
+			return err
 		}
+		exclude.Add(*route)
 
 		// pass a node to enable backwards compatability as changing the
 		// call func would be a breaking change.
@@ -428,10 +430,19 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 		node := &registry.Node{Address: route.Address}
 
 		// make the call
-		err = gcall(ctx, node, req, rsp, callOpts)
+		start := time.Now()
+		err = gcall(ctx, node, req, rsp, opts)
+
+		// a call cancelled because the hedged call responded first says nothing about the node
+		if err != nil && ctx.Err() == context.Canceled {
+			return err
+		}
 
 		// record the result of the call to inform future routing decisions
-		g.opts.Selector.Record(*route, err)
+		opts.Selector.Record(*route, err)
+		if err == nil {
+			g.latency.Record(req, time.Since(start))
+		}
 
 		// try and transform the error to a go-micro error
 		if verr, ok := err.(*errors.Error); ok {
@@ -446,7 +457,24 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 
 	for i := 0; i <= callOpts.Retries; i++ {
 		go func(i int) {
-			ch <- call(i)
+			// call backoff first. Someone may want an initial start delay
+			t, err := callOpts.Backoff(ctx, req, i)
+			if err != nil {
+				ch <- errors.InternalServerError("go.micro.client", err.Error())
+				return
+			}
+
+			// only sleep if greater than 0
+			if t.Seconds() > 0 {
+				time.Sleep(t)
+			}
+
+			// only the first attempt is hedged, retries are already sent to another node
+			delay := hedge
+			if i > 0 {
+				delay = 0
+			}
+			ch <- client.Hedge(ctx, delay, rsp, call)
 		}(i)
 
 		select {
@@ -527,7 +555,7 @@ func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...cli
 		// lookup the route to send the reques to
 		route, err := client.LookupRoute(req, callOpts)
 		if err != nil {
-			return nil, errors.InternalServerError("go.micro.client", err.Error())
+			return nil, err
 		}
 
 		// pass a node to enable backwards compatability as changing the
@@ -540,14 +568,13 @@ func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...cli
 		err = g.stream(ctx, node, req, stream, callOpts)
 
 		// record the result of the call to inform future routing decisions
-		g.opts.Selector.Record(*route, err)
+		callOpts.Selector.Record(*route, err)
 
 		// try and transform the error to a go-micro error
 		if verr, ok := err.(*errors.Error); ok {
 			return nil, verr
 		}
 
-		g.opts.Selector.Record(*route, err)
 		return stream, err
 	}
 
@@ -640,7 +667,7 @@ func (g *grpcClient) Publish(ctx context.Context, p client.Message, opts ...clie
 	return g.opts.Broker.Publish(topic, &broker.Message{
 		Header: md,
 		Body:   body,
-	}, broker.PublishContext(options.Context))
+	}, broker.PublishContext(options.Context), broker.PartitionKey(options.PartitionKey))
 }
 
 func (g *grpcClient) String() string {
@@ -697,7 +724,8 @@ func newClient(opts ...client.Option) client.Client {
 	}
 
 	rc := &grpcClient{
-		opts: options,
+		opts:    options,
+		latency: client.NewLatency(),
 	}
 	rc.once.Store(false)
 
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/router"
+)
+
+var (
+	// LatencySamples is the number of request latencies kept for each endpoint
+	LatencySamples = 100
+	// MinLatencySamples is the number of latencies needed to hedge at a percentile
+	MinLatencySamples = 10
+)
+
+// Latency of the recent requests to each endpoint, used to hedge requests at a percentile
+type Latency struct {
+	sync.Mutex
+	samples map[string][]time.Duration
+	next    map[string]int
+}
+
+// NewLatency returns a new latency tracker
+func NewLatency() *Latency {
+	return &Latency{
+		samples: make(map[string][]time.Duration),
+		next:    make(map[string]int),
+	}
+}
+
+func latencyKey(req Request) string {
+	return req.Service() + "." + req.Endpoint()
+}
+
+// Record the latency of a request
+func (l *Latency) Record(req Request, d time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	key := latencyKey(req)
+	if s := l.samples[key]; len(s) < LatencySamples {
+		l.samples[key] = append(s, d)
+		return
+	}
+
+	// replace the oldest sample
+	l.samples[key][l.next[key]] = d
+	l.next[key] = (l.next[key] + 1) % LatencySamples
+}
+
+// Percentile returns the percentile, e.g. 0.95, of the latency of requests to the endpoint.
+// False is returned if there aren't enough samples.
+func (l *Latency) Percentile(req Request, p float64) (time.Duration, bool) {
+	l.Lock()
+	samples := append([]time.Duration{}, l.samples[latencyKey(req)]...)
+	l.Unlock()
+
+	if len(samples) < MinLatencySamples {
+		return 0, false
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	i := int(p * float64(len(samples)))
+	if i >= len(samples) {
+		i = len(samples) - 1
+	}
+	return samples[i], true
+}
+
+// HedgeDelay returns how long to wait for a response before hedging the request, zero if the
+// request shouldn't be hedged
+func (l *Latency) HedgeDelay(req Request, opts CallOptions) time.Duration {
+	if req.Stream() {
+		return 0
+	}
+	if opts.HedgePercentile > 0 {
+		if d, ok := l.Percentile(req, opts.HedgePercentile); ok {
+			return d
+		}
+	}
+	return opts.HedgeDelay
+}
+
+// Exclude is a selector filter which excludes the routes already used by a request, so a
+// hedged request is sent to another node where possible
+type Exclude struct {
+	sync.Mutex
+	addrs map[string]bool
+}
+
+// NewExclude returns a filter which doesn't exclude any routes
+func NewExclude() *Exclude {
+	return &Exclude{addrs: make(map[string]bool)}
+}
+
+// Add the route to those excluded
+func (e *Exclude) Add(r router.Route) {
+	e.Lock()
+	defer e.Unlock()
+	e.addrs[r.Address] = true
+}
+
+// Filter the routes, if every route has been used they're all returned
+func (e *Exclude) Filter(routes []router.Route) []router.Route {
+	e.Lock()
+	defer e.Unlock()
+
+	var filtered []router.Route
+	for _, r := range routes {
+		if !e.addrs[r.Address] {
+			filtered = append(filtered, r)
+		}
+	}
+	if len(filtered) == 0 {
+		return routes
+	}
+	return filtered
+}
+
+// Hedge makes the call and, if there's no response within the delay, makes it again. The first
+// successful response is used, or the last error is returned if both fail. Each call decodes into
+// its own response so the slower call can't overwrite the response once it's been returned, and
+// each has its own context which is cancelled once the call isn't needed.
+func Hedge(ctx context.Context, delay time.Duration, rsp interface{}, fn func(ctx context.Context, rsp interface{}) error) error {
+	rv := reflect.ValueOf(rsp)
+	if delay <= 0 || rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fn(ctx, rsp)
+	}
+
+	type result struct {
+		rsp reflect.Value
+		err error
+	}
+	ch := make(chan result, 2)
+
+	// cancel the call which is still in flight once there's a response
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	call := func() {
+		r := reflect.New(rv.Elem().Type())
+		ch <- result{r, fn(ctx, r.Interface())}
+	}
+
+	go call()
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	calls := 1
+	var err error
+
+	for calls > 0 {
+		select {
+		case res := <-ch:
+			calls--
+			if res.err == nil {
+				rv.Elem().Set(res.rsp.Elem())
+				return nil
+			}
+			err = res.err
+		case <-t.C:
+			calls++
+			go call()
+		case <-ctx.Done():
+			return errors.Timeout("go.micro.client", fmt.Sprintf("%v", ctx.Err()))
+		}
+	}
+
+	return err
+}
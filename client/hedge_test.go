@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/router"
+)
+
+func TestHedge(t *testing.T) {
+	tt := []struct {
+		Name     string
+		Delays   []time.Duration
+		Errors   []error
+		Response string
+		Calls    int
+		Error    bool
+	}{
+		{Name: "Fast", Delays: []time.Duration{0, 0}, Errors: []error{nil, nil}, Response: "0", Calls: 1},
+		{Name: "Slow", Delays: []time.Duration{100 * time.Millisecond, 0}, Errors: []error{nil, nil}, Response: "1", Calls: 2},
+		{Name: "HedgeError", Delays: []time.Duration{50 * time.Millisecond, 0}, Errors: []error{nil, errors.New("error")}, Response: "0", Calls: 2},
+		{Name: "Errors", Delays: []time.Duration{50 * time.Millisecond, 0}, Errors: []error{errors.New("error"), errors.New("error")}, Calls: 2, Error: true},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			calls := make(chan int, 2)
+			fn := func(ctx context.Context, rsp interface{}) error {
+				i := len(calls)
+				calls <- i
+				time.Sleep(tc.Delays[i])
+				*rsp.(*string) = string(rune('0' + i))
+				return tc.Errors[i]
+			}
+
+			var rsp string
+			err := Hedge(context.Background(), 20*time.Millisecond, &rsp, fn)
+			if tc.Error != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tc.Error, err)
+			}
+			if rsp != tc.Response {
+				t.Errorf("Expected response %q, got %q", tc.Response, rsp)
+			}
+			if len(calls) != tc.Calls {
+				t.Errorf("Expected %v calls, got %v", tc.Calls, len(calls))
+			}
+		})
+	}
+}
+
+func TestHedgeCancel(t *testing.T) {
+	cancelled := make(chan bool, 1)
+	calls := make(chan int, 2)
+	fn := func(ctx context.Context, rsp interface{}) error {
+		i := len(calls)
+		calls <- i
+		if i > 0 {
+			return nil
+		}
+
+		// the first call is slow, it should be cancelled once the hedged call responds
+		select {
+		case <-ctx.Done():
+			cancelled <- true
+			return ctx.Err()
+		case <-time.After(time.Second):
+			cancelled <- false
+			return nil
+		}
+	}
+
+	var rsp string
+	if err := Hedge(context.Background(), 20*time.Millisecond, &rsp, fn); err != nil {
+		t.Fatalf("Expected nil error, got %v", err)
+	}
+	if !<-cancelled {
+		t.Errorf("Expected the slower call to be cancelled")
+	}
+}
+
+func TestLatency(t *testing.T) {
+	l := NewLatency()
+	req := &testRequest{service: "foo", method: "Foo.Bar"}
+	opts := CallOptions{HedgeDelay: time.Second, HedgePercentile: 0.9}
+
+	// the delay is used until there are enough samples
+	for i := 1; i < MinLatencySamples; i++ {
+		l.Record(req, time.Duration(i)*time.Millisecond)
+	}
+	if d := l.HedgeDelay(req, opts); d != time.Second {
+		t.Errorf("Expected the hedge delay, got %v", d)
+	}
+
+	l.Record(req, 10*time.Millisecond)
+	if d := l.HedgeDelay(req, opts); d != 10*time.Millisecond {
+		t.Errorf("Expected the 90th percentile, got %v", d)
+	}
+}
+
+func TestExclude(t *testing.T) {
+	r1 := router.Route{Service: "foo", Address: "127.0.0.1:8000"}
+	r2 := router.Route{Service: "foo", Address: "127.0.0.1:8001"}
+
+	e := NewExclude()
+	if rts := e.Filter([]router.Route{r1, r2}); len(rts) != 2 {
+		t.Errorf("Expected no routes to be excluded, got %v", rts)
+	}
+
+	e.Add(r1)
+	if rts := e.Filter([]router.Route{r1, r2}); len(rts) != 1 || rts[0].Address != r2.Address {
+		t.Errorf("Expected the used route to be excluded, got %v", rts)
+	}
+
+	// all the routes are returned once they've all been used
+	e.Add(r2)
+	if rts := e.Filter([]router.Route{r1, r2}); len(rts) != 2 {
+		t.Errorf("Expected all the routes, got %v", rts)
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	if d := Remaining(context.Background(), time.Second); d != time.Second {
+		t.Errorf("Expected the timeout without a deadline, got %v", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if d := Remaining(ctx, time.Second); d > 100*time.Millisecond || d <= 0 {
+		t.Errorf("Expected the time remaining until the deadline, got %v", d)
+	}
+	if d := Remaining(ctx, 0); d > 100*time.Millisecond || d <= 0 {
+		t.Errorf("Expected the time remaining until the deadline, got %v", d)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/micro/go-micro/v3/errors"
 	"github.com/micro/go-micro/v3/metadata"
 	"github.com/micro/go-micro/v3/registry"
+	"github.com/micro/go-micro/v3/selector"
 	"github.com/micro/go-micro/v3/transport"
 	"github.com/micro/go-micro/v3/util/buf"
 	"github.com/micro/go-micro/v3/util/pool"
@@ -30,6 +31,8 @@ type rpcClient struct {
 	opts client.Options
 	pool pool.Pool
 	seq  uint64
+	// latency of the requests, used to hedge them
+	latency *client.Latency
 }
 
 func newClient(opt ...client.Option) client.Client {
@@ -42,9 +45,10 @@ func newClient(opt ...client.Option) client.Client {
 	)
 
 	rc := &rpcClient{
-		opts: opts,
-		pool: p,
-		seq:  0,
+		opts:    opts,
+		pool:    p,
+		seq:     0,
+		latency: client.NewLatency(),
 	}
 	rc.once.Store(false)
 
@@ -85,8 +89,8 @@ func (r *rpcClient) call(ctx context.Context, node *registry.Node, req client.Re
 		}
 	}
 
-	// set timeout in nanoseconds
-	msg.Header["Timeout"] = fmt.Sprintf("%d", opts.RequestTimeout)
+	// set timeout in nanoseconds, it's the time remaining when retried or hedged
+	msg.Header["Timeout"] = fmt.Sprintf("%d", client.Remaining(ctx, opts.RequestTimeout))
 	// set the content type for the request
 	msg.Header["Content-Type"] = req.ContentType()
 	// set the accept header
@@ -197,9 +201,9 @@ func (r *rpcClient) stream(ctx context.Context, node *registry.Node, req client.
 		}
 	}
 
-	// set timeout in nanoseconds
-	if opts.StreamTimeout > time.Duration(0) {
-		msg.Header["Timeout"] = fmt.Sprintf("%d", opts.StreamTimeout)
+	// set timeout in nanoseconds, propagating the deadline of the context
+	if timeout := client.Remaining(ctx, opts.StreamTimeout); timeout > time.Duration(0) {
+		msg.Header["Timeout"] = fmt.Sprintf("%d", timeout)
 	}
 	// set the content type for the request
 	msg.Header["Content-Type"] = req.ContentType()
@@ -356,38 +360,37 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 		rcall = callOpts.CallWrappers[i-1](rcall)
 	}
 
-	// return errors.New("go.micro.client", "request timeout", 408)
-	call := func(i int) error {
-		// call backoff first. Someone may want an initial start delay
-		t, err := callOpts.Backoff(ctx, request, i)
-		if err != nil {
-			return errors.InternalServerError("go.micro.client", "backoff error: %v", err.Error())
-		}
+	// use the router passed as a call option, or fallback to the rpc clients router
+	if callOpts.Router == nil {
+		callOpts.Router = r.opts.Router
+	}
+	// use the selector passed as a call option, or fallback to the rpc clients selector
+	if callOpts.Selector == nil {
+		callOpts.Selector = r.opts.Selector
+	}
 
-		// only sleep if greater than 0
-		if t.Seconds() > 0 {
-			time.Sleep(t)
-		}
+	// inject proxy address
+	if len(r.opts.Proxy) > 0 {
+		callOpts.Address = []string{r.opts.Proxy}
+	}
 
-		// use the router passed as a call option, or fallback to the rpc clients router
-		if callOpts.Router == nil {
-			callOpts.Router = r.opts.Router
-		}
-		// use the selector passed as a call option, or fallback to the rpc clients selector
-		if callOpts.Selector == nil {
-			callOpts.Selector = r.opts.Selector
-		}
+	// hedged requests are sent to another node where possible
+	hedge := r.latency.HedgeDelay(request, callOpts)
+	exclude := client.NewExclude()
 
-		// inject proxy address
-		if len(r.opts.Proxy) > 0 {
-			callOpts.Address = []string{r.opts.Proxy}
+	// return errors.New("go.micro.client", "request timeout", 408)
+	call := func(ctx context.Context, response interface{}) error {
+		opts := callOpts
+		if hedge > 0 {
+			opts.SelectOptions = append(opts.SelectOptions[:len(opts.SelectOptions):len(opts.SelectOptions)], selector.WithFilter(exclude.Filter))
 		}
 
 		// lookup the route to send the request via
-		route, err := client.LookupRoute(request, callOpts)
+		route, err := client.LookupRoute(request, opts)
 		if err != nil {
 			return err
 		}
+		exclude.Add(*route)
 
 		// pass a node to enable backwards comparability as changing the
 		// call func would be a breaking change.
@@ -395,10 +398,19 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 		node := &registry.Node{Address: route.Address, Metadata: route.Metadata}
 
 		// make the call
-		err = rcall(ctx, node, request, response, callOpts)
+		start := time.Now()
+		err = rcall(ctx, node, request, response, opts)
+
+		// a call cancelled because the hedged call responded first says nothing about the node
+		if err != nil && ctx.Err() == context.Canceled {
+			return err
+		}
 
 		// record the result of the call to inform future routing decisions
-		r.opts.Selector.Record(*route, err)
+		opts.Selector.Record(*route, err)
+		if err == nil {
+			r.latency.Record(request, time.Since(start))
+		}
 
 		return err
 	}
@@ -416,7 +428,24 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 
 	for i := 0; i <= retries; i++ {
 		go func(i int) {
-			ch <- call(i)
+			// call backoff first. Someone may want an initial start delay
+			t, err := callOpts.Backoff(ctx, request, i)
+			if err != nil {
+				ch <- errors.InternalServerError("go.micro.client", "backoff error: %v", err.Error())
+				return
+			}
+
+			// only sleep if greater than 0
+			if t.Seconds() > 0 {
+				time.Sleep(t)
+			}
+
+			// only the first attempt is hedged, retries are already sent to another node
+			delay := hedge
+			if i > 0 {
+				delay = 0
+			}
+			ch <- client.Hedge(ctx, delay, response, call)
 		}(i)
 
 		select {
@@ -499,7 +528,7 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 		stream, err := r.stream(ctx, node, request, callOpts)
 
 		// record the result of the call to inform future routing decisions
-		r.opts.Selector.Record(*route, err)
+		callOpts.Selector.Record(*route, err)
 
 		return stream, err
 	}
@@ -617,7 +646,7 @@ func (r *rpcClient) Publish(ctx context.Context, msg client.Message, opts ...cli
 	return r.opts.Broker.Publish(topic, &broker.Message{
 		Header: md,
 		Body:   body,
-	}, broker.PublishContext(options.Context))
+	}, broker.PublishContext(options.Context), broker.PartitionKey(options.PartitionKey))
 }
 
 func (r *rpcClient) NewMessage(topic string, message interface{}, opts ...client.MessageOption) client.Message {
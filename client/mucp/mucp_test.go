@@ -3,7 +3,9 @@ package mucp
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/micro/go-micro/v3/client"
 	"github.com/micro/go-micro/v3/errors"
@@ -156,3 +158,48 @@ func TestCallWrapper(t *testing.T) {
 		t.Fatal("wrapper not called")
 	}
 }
+
+func TestCallHedge(t *testing.T) {
+	var mtx sync.Mutex
+	var addrs []string
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node *registry.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			mtx.Lock()
+			addrs = append(addrs, node.Address)
+			first := len(addrs) == 1
+			mtx.Unlock()
+
+			// the first node is slow to respond
+			if first {
+				time.Sleep(100 * time.Millisecond)
+			}
+			*rsp.(*string) = node.Address
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Registry(newTestRegistry()),
+		client.WrapCall(wrap),
+	)
+
+	var rsp string
+	req := c.NewRequest("foo", "Foo.Bar", nil)
+	if err := c.Call(context.Background(), req, &rsp, client.WithHedge(10*time.Millisecond)); err != nil {
+		t.Fatalf("Call returned %v error, expected nil", err)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if len(addrs) != 2 {
+		t.Fatalf("Expected the request to be hedged, got %v calls", len(addrs))
+	}
+	if addrs[0] == addrs[1] {
+		t.Errorf("Expected the hedged request to be sent to another node, got %v", addrs)
+	}
+	if rsp != addrs[1] {
+		t.Errorf("Expected the response of the hedged request, got %v", rsp)
+	}
+}
@@ -71,6 +71,12 @@ type CallOptions struct {
 	StreamTimeout time.Duration
 	// Use the auth token as the authorization header
 	AuthToken bool
+	// HedgeDelay is how long to wait for a response before sending the
+	// request to another node, zero disables hedging
+	HedgeDelay time.Duration
+	// HedgePercentile hedges requests which take longer than the percentile
+	// of the latency of previous requests to the endpoint, e.g. 0.95
+	HedgePercentile float64
 	// Network to lookup the route within
 	Network string
 
@@ -85,6 +91,9 @@ type CallOptions struct {
 type PublishOptions struct {
 	// Exchange is the routing exchange for the message
 	Exchange string
+	// PartitionKey of the message, the messages with the same key are handled
+	// in order by queue subscribers
+	PartitionKey string
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -269,6 +278,13 @@ func WithExchange(e string) PublishOption {
 	}
 }
 
+// WithPartitionKey sets the partition key of the message
+func WithPartitionKey(key string) PublishOption {
+	return func(o *PublishOptions) {
+		o.PartitionKey = key
+	}
+}
+
 // PublishContext sets the context in publish options
 func PublishContext(ctx context.Context) PublishOption {
 	return func(o *PublishOptions) {
@@ -345,6 +361,23 @@ func WithAuthToken() CallOption {
 	}
 }
 
+// WithHedge is a CallOption which sends the request to another node if
+// there's no response within the delay, using the first response
+func WithHedge(d time.Duration) CallOption {
+	return func(o *CallOptions) {
+		o.HedgeDelay = d
+	}
+}
+
+// WithHedgePercentile is a CallOption which hedges requests taking longer
+// than the percentile of the latency of previous requests to the endpoint.
+// The hedge delay is used until enough requests have been made.
+func WithHedgePercentile(p float64) CallOption {
+	return func(o *CallOptions) {
+		o.HedgePercentile = p
+	}
+}
+
 // WithCache is a CallOption which sets the duration the response
 // shoull be cached for
 func WithCache(c time.Duration) CallOption {
@@ -1,7 +1,9 @@
 package client
 
 import (
+	"context"
 	"math/rand"
+	"time"
 
 	"github.com/micro/go-micro/v3/errors"
 	"github.com/micro/go-micro/v3/router"
@@ -38,9 +40,28 @@ func LookupRoute(req Request, opts CallOptions) (*router.Route, error) {
 	// select the route to use for the request
 	if route, err := opts.Selector.Select(routes, opts.SelectOptions...); err == selector.ErrNoneAvailable {
 		return nil, errors.InternalServerError("go.micro.client", "service %s: %s", req.Service(), err.Error())
+	} else if err == selector.ErrOpen {
+		return nil, errors.ServiceUnavailable("go.micro.client", "service %s: %s", req.Service(), err.Error())
 	} else if err != nil {
 		return nil, errors.InternalServerError("go.micro.client", "error getting next %s node: %s", req.Service(), err.Error())
 	} else {
 		return route, nil
 	}
 }
+
+// Remaining returns the timeout to propagate with a request, which is the time remaining
+// until the context's deadline if it's sooner
+func Remaining(ctx context.Context, timeout time.Duration) time.Duration {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return timeout
+	}
+	remaining := time.Until(d)
+	if remaining < 0 {
+		return 0
+	}
+	if timeout <= 0 || remaining < timeout {
+		return remaining
+	}
+	return timeout
+}
@@ -43,6 +43,7 @@ require (
 	github.com/kr/pretty v0.2.0
 	github.com/lib/pq v1.3.0
 	github.com/lucas-clemente/quic-go v0.14.1
+	github.com/mattn/go-sqlite3 v1.14.0
 	github.com/micro/cli/v2 v2.1.2
 	github.com/micro/services v0.10.0 // indirect
 	github.com/miekg/dns v1.1.27
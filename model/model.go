@@ -2,11 +2,20 @@
 package model
 
 import (
+	"errors"
+
 	"github.com/micro/go-micro/v3/codec"
 	"github.com/micro/go-micro/v3/store"
 	"github.com/micro/go-micro/v3/sync"
 )
 
+var (
+	// ErrMissingName is returned when a read or delete does not specify the entity name
+	ErrMissingName = errors.New("missing entity name")
+	// ErrMissingFilter is returned when a delete specifies neither an id, a query nor all
+	ErrMissingFilter = errors.New("missing delete filter")
+)
+
 // Model provides an interface for data modelling
 type Model interface {
 	// Initialise options
@@ -47,14 +56,55 @@ type Options struct {
 	Sync sync.Sync
 	// for storage
 	Store store.Store
+	// Indexes to maintain
+	Indexes []Index
 }
 
 type Option func(o *Options)
 
-type ReadOptions struct{}
+// Index is a secondary index on one or more fields of an entity.
+// Index entries are kept in the store alongside the entity records.
+type Index struct {
+	// Name of the entity which is indexed
+	Entity string
+	// Fields which make up the index, in order
+	Fields []string
+}
+
+type ReadOptions struct {
+	// Name of the entity to read
+	Name string
+	// Id of the entity to read
+	Id string
+	// Query is a set of fields which must be equal
+	Query map[string]interface{}
+	// Order the results
+	Order Order
+	// Limit the number of results
+	Limit uint
+	// Offset when combined with Limit supports pagination
+	Offset uint
+}
 
 type ReadOption func(o *ReadOptions)
 
-type DeleteOptions struct{}
+// Order specifies the field and direction to sort by
+type Order struct {
+	// Field to order by
+	Field string
+	// Desc sorts in descending order
+	Desc bool
+}
+
+type DeleteOptions struct {
+	// Name of the entity to delete
+	Name string
+	// Id of the entity to delete
+	Id string
+	// Query is a set of fields which must be equal
+	Query map[string]interface{}
+	// All deletes every entity with the name when no id or query is given
+	All bool
+}
 
 type DeleteOption func(o *DeleteOptions)
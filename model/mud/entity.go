@@ -4,6 +4,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/micro/go-micro/v3/codec"
 	"github.com/micro/go-micro/v3/model"
+	"github.com/micro/go-micro/v3/store"
 )
 
 type mudEntity struct {
@@ -12,6 +13,8 @@ type mudEntity struct {
 	value      interface{}
 	codec      codec.Marshaler
 	attributes map[string]interface{}
+	// version of the record the entity was read from
+	version uint64
 }
 
 func (m *mudEntity) Attributes() map[string]interface{} {
@@ -36,9 +39,13 @@ func (m *mudEntity) Read(v interface{}) error {
 		b := m.value.([]byte)
 		return m.codec.Unmarshal(b, v)
 	default:
-		v = m.value
+		// round trip the value through the codec
+		b, err := m.codec.Marshal(m.value)
+		if err != nil {
+			return err
+		}
+		return m.codec.Unmarshal(b, v)
 	}
-	return nil
 }
 
 func newEntity(name string, value interface{}, codec codec.Marshaler) model.Entity {
@@ -50,3 +57,20 @@ func newEntity(name string, value interface{}, codec codec.Marshaler) model.Enti
 		attributes: make(map[string]interface{}),
 	}
 }
+
+// recordToEntity creates an entity from a record read out of the store
+func recordToEntity(name string, r *store.Record, codec codec.Marshaler) model.Entity {
+	attributes := make(map[string]interface{})
+	for k, v := range r.Metadata {
+		attributes[k] = v
+	}
+
+	return &mudEntity{
+		id:         r.Key,
+		name:       name,
+		value:      r.Value,
+		codec:      codec,
+		attributes: attributes,
+		version:    r.Version,
+	}
+}
@@ -0,0 +1,171 @@
+package mud
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/micro/go-micro/v3/model"
+	"github.com/micro/go-micro/v3/store"
+)
+
+// indexPrefix is the key prefix of index entries. They're kept in the same
+// table as the entity records so both can be written in one transaction.
+const indexPrefix = "index:"
+
+// outboxPrefix is the key prefix of the events of broker/outbox, which are kept in
+// the table of the records they're written in the same transaction as.
+const outboxPrefix = "outbox/"
+
+// failedPrefix is the key prefix broker/outbox moves the events it can't decode to
+const failedPrefix = "outbox-failed/"
+
+// reserved returns true if the key isn't that of an entity record
+func reserved(key string) bool {
+	return strings.HasPrefix(key, indexPrefix) || strings.HasPrefix(key, outboxPrefix) ||
+		strings.HasPrefix(key, failedPrefix)
+}
+
+// indexName is the key prefix used for all entries of an index
+func indexName(idx model.Index) string {
+	return indexPrefix + strings.Join(idx.Fields, ",")
+}
+
+// indexes returns the indexes declared for the named entity
+func (m *mudModel) indexes(name string) []model.Index {
+	var indexes []model.Index
+	for _, idx := range m.options.Indexes {
+		if idx.Entity == name && len(idx.Fields) > 0 {
+			indexes = append(indexes, idx)
+		}
+	}
+	return indexes
+}
+
+// encode a field value so it can be safely used as part of a key
+func (m *mudModel) encode(v interface{}) (string, error) {
+	b, err := m.options.Codec.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// valuePrefix returns the key prefix for the values of the first n fields of the index
+func (m *mudModel) valuePrefix(idx model.Index, fields map[string]interface{}, n int) (string, error) {
+	parts := []string{indexName(idx)}
+	for _, f := range idx.Fields[:n] {
+		v, err := m.encode(fields[f])
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, ":") + ":", nil
+}
+
+// indexKey returns the key of the index entry for the entity
+func (m *mudModel) indexKey(idx model.Index, fields map[string]interface{}, id string) (string, error) {
+	prefix, err := m.valuePrefix(idx, fields, len(idx.Fields))
+	if err != nil {
+		return "", err
+	}
+	return prefix + id, nil
+}
+
+// indexWrites returns the operations which write the index entries for the entity
+func (m *mudModel) indexWrites(name, id string, fields map[string]interface{}) ([]store.Operation, error) {
+	var ops []store.Operation
+	for _, idx := range m.indexes(name) {
+		key, err := m.indexKey(idx, fields, id)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, store.WriteOp(&store.Record{
+			Key:   key,
+			Value: []byte(id),
+		}))
+	}
+	return ops, nil
+}
+
+// indexDeletes returns the operations which remove the index entries for the entity
+func (m *mudModel) indexDeletes(name, id string, fields map[string]interface{}) ([]store.Operation, error) {
+	var ops []store.Operation
+	for _, idx := range m.indexes(name) {
+		key, err := m.indexKey(idx, fields, id)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, store.DeleteOp(key))
+	}
+	return ops, nil
+}
+
+// lookup finds the ids of entities matching the query using the index which
+// covers the most leading fields of the query. It returns false if no index
+// can be used to satisfy the query.
+func (m *mudModel) lookup(name string, query map[string]interface{}) ([]string, bool, error) {
+	var best model.Index
+	var covered int
+
+	for _, idx := range m.indexes(name) {
+		var n int
+		for _, f := range idx.Fields {
+			if _, ok := query[f]; !ok {
+				break
+			}
+			n++
+		}
+		if n > covered {
+			best = idx
+			covered = n
+		}
+	}
+
+	if covered == 0 {
+		return nil, false, nil
+	}
+
+	prefix, err := m.valuePrefix(best, query, covered)
+	if err != nil {
+		return nil, false, err
+	}
+
+	recs, err := m.options.Store.Read(prefix, store.ReadFrom(m.options.Database, name), store.ReadPrefix())
+	if err != nil && err != store.ErrNotFound {
+		return nil, false, err
+	}
+
+	ids := make([]string, 0, len(recs))
+	for _, r := range recs {
+		ids = append(ids, string(r.Value))
+	}
+
+	return ids, true, nil
+}
+
+// apply the operations to the table of the named entity. They're applied in a single
+// transaction if the store supports it, otherwise one at a time under the entity lock.
+func (m *mudModel) apply(name string, ops []store.Operation) error {
+	if t, ok := m.options.Store.(store.Transactional); ok {
+		return t.Transact(ops, store.TransactIn(m.options.Database, name))
+	}
+
+	for _, op := range ops {
+		if op.Record == nil {
+			if err := m.options.Store.Delete(op.Key, store.DeleteFrom(m.options.Database, name)); err != nil {
+				return err
+			}
+			continue
+		}
+		wopts := []store.WriteOption{store.WriteTo(m.options.Database, name)}
+		if op.Conditional {
+			wopts = append(wopts, store.WriteIfVersion(op.Version))
+		}
+		if err := m.options.Store.Write(op.Record, wopts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,8 @@
 package mud
 
 import (
+	"sort"
+
 	"github.com/micro/go-micro/v3/codec/json"
 	"github.com/micro/go-micro/v3/model"
 	"github.com/micro/go-micro/v3/store"
@@ -26,24 +28,29 @@ func (m *mudModel) NewEntity(name string, value interface{}) model.Entity {
 }
 
 func (m *mudModel) Create(e model.Entity) error {
-	// lock on the name of entity
-	if err := m.options.Sync.Lock(e.Name()); err != nil {
+	unlock, err := m.lock(e.Name())
+	if err != nil {
 		return err
 	}
-	// TODO: deal with the error
-	defer m.options.Sync.Unlock(e.Name())
+	defer unlock()
 
-	// TODO: potentially add encode to entity?
-	v, err := m.options.Codec.Marshal(e.Value())
+	ops, err := m.writes(e)
 	if err != nil {
 		return err
 	}
 
-	// TODO: include metadata and set database
-	return m.options.Store.Write(&store.Record{
-		Key:   e.Id(),
-		Value: v,
-	}, store.WriteTo(m.options.Database, e.Name()))
+	// the entity must not already exist
+	ops[0] = ops[0].IfVersion(0)
+
+	if err := m.apply(e.Name(), ops); err != nil {
+		return err
+	}
+
+	if me, ok := e.(*mudEntity); ok {
+		me.version = 1
+	}
+
+	return nil
 }
 
 func (m *mudModel) Read(opts ...model.ReadOption) ([]model.Entity, error) {
@@ -51,31 +58,103 @@ func (m *mudModel) Read(opts ...model.ReadOption) ([]model.Entity, error) {
 	for _, o := range opts {
 		o(&options)
 	}
-	// TODO: implement the options that allow querying
-	return nil, nil
+
+	if len(options.Name) == 0 {
+		return nil, model.ErrMissingName
+	}
+
+	results, err := m.query(options.Name, options.Id, options.Query, len(options.Order.Field) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// order by the field or fall back to the id so pagination is stable
+	if len(options.Order.Field) > 0 {
+		field := options.Order.Field
+		sort.SliceStable(results, func(i, j int) bool {
+			c := compare(results[i].fields[field], results[j].fields[field])
+			if options.Order.Desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	} else {
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].entity.Id() < results[j].entity.Id()
+		})
+	}
+
+	// apply the offset and limit
+	if options.Offset >= uint(len(results)) {
+		return []model.Entity{}, nil
+	}
+	results = results[options.Offset:]
+	if options.Limit > 0 && options.Limit < uint(len(results)) {
+		results = results[:options.Limit]
+	}
+
+	entities := make([]model.Entity, 0, len(results))
+	for _, r := range results {
+		entities = append(entities, r.entity)
+	}
+
+	return entities, nil
 }
 
 func (m *mudModel) Update(e model.Entity) error {
-	// TODO: read out the record first, update the fields and store
+	unlock, err := m.lock(e.Name())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	indexed := len(m.indexes(e.Name())) > 0
 
-	// lock on the name of entity
-	if err := m.options.Sync.Lock(e.Name()); err != nil {
+	// read the current record to get its version and index entries
+	results, err := m.query(e.Name(), e.Id(), nil, indexed)
+	if err != nil {
 		return err
 	}
-	// TODO: deal with the error
-	defer m.options.Sync.Unlock(e.Name())
 
-	// TODO: potentially add encode to entity?
-	v, err := m.options.Codec.Marshal(e.Value())
+	var ops []store.Operation
+	var version uint64
+
+	for _, r := range results {
+		version = r.version
+		if !indexed {
+			continue
+		}
+		// remove the index entries of the current value
+		deletes, err := m.indexDeletes(e.Name(), e.Id(), r.fields)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, deletes...)
+	}
+
+	// entities read from the model must not have been changed since
+	me, ok := e.(*mudEntity)
+	if ok && me.version > 0 && me.version != version {
+		return store.ErrVersionConflict
+	}
+
+	writes, err := m.writes(e)
 	if err != nil {
 		return err
 	}
 
-	// TODO: include metadata and set database
-	return m.options.Store.Write(&store.Record{
-		Key:   e.Id(),
-		Value: v,
-	}, store.WriteTo(m.options.Database, e.Name()))
+	// the record must not change between reading and writing it
+	writes[0] = writes[0].IfVersion(version)
+
+	if err := m.apply(e.Name(), append(ops, writes...)); err != nil {
+		return err
+	}
+
+	if ok {
+		me.version = version + 1
+	}
+
+	return nil
 }
 
 func (m *mudModel) Delete(opts ...model.DeleteOption) error {
@@ -83,8 +162,98 @@ func (m *mudModel) Delete(opts ...model.DeleteOption) error {
 	for _, o := range opts {
 		o(&options)
 	}
-	// TODO: implement the options that allow deleting
-	return nil
+
+	if len(options.Name) == 0 {
+		return model.ErrMissingName
+	}
+	// deleting every entity must be asked for explicitly
+	if len(options.Id) == 0 && len(options.Query) == 0 && !options.All {
+		return model.ErrMissingFilter
+	}
+
+	unlock, err := m.lock(options.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	indexed := len(m.indexes(options.Name)) > 0
+
+	results, err := m.query(options.Name, options.Id, options.Query, indexed)
+	if err != nil {
+		return err
+	}
+
+	var ops []store.Operation
+
+	for _, r := range results {
+		// the record must not change between reading and deleting it, or its index
+		// entries would be left behind
+		ops = append(ops, store.DeleteOp(r.entity.Id()).IfVersion(r.version))
+		if !indexed {
+			continue
+		}
+		deletes, err := m.indexDeletes(options.Name, r.entity.Id(), r.fields)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, deletes...)
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	return m.apply(options.Name, ops)
+}
+
+// writes returns the operations which write the entity and its index entries
+func (m *mudModel) writes(e model.Entity) ([]store.Operation, error) {
+	// TODO: potentially add encode to entity?
+	v, err := m.options.Codec.Marshal(e.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []store.Operation{store.WriteOp(&store.Record{
+		Key:      e.Id(),
+		Value:    v,
+		Metadata: e.Attributes(),
+	})}
+
+	if len(m.indexes(e.Name())) == 0 {
+		return ops, nil
+	}
+
+	fields, err := m.decode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	writes, err := m.indexWrites(e.Name(), e.Id(), fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ops, writes...), nil
+}
+
+// lock the named entity if the store can't apply conditional transactions.
+// Otherwise concurrent changes are detected by the record versions, every
+// write and delete of an existing record is conditional on the version read.
+func (m *mudModel) lock(name string) (func(), error) {
+	if _, ok := m.options.Store.(store.Transactional); ok {
+		return func() {}, nil
+	}
+
+	if err := m.options.Sync.Lock(name); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		// TODO: deal with the error
+		m.options.Sync.Unlock(name)
+	}, nil
 }
 
 func (m *mudModel) String() string {
@@ -98,6 +267,10 @@ func NewModel(opts ...model.Option) model.Model {
 		Store: memory.NewStore(),
 	}
 
+	for _, o := range opts {
+		o(&options)
+	}
+
 	return &mudModel{
 		options: options,
 	}
@@ -0,0 +1,371 @@
+package mud
+
+import (
+	"testing"
+
+	"github.com/micro/go-micro/v3/model"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+type user struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Age   int    `json:"age"`
+}
+
+func testModel(t *testing.T) model.Model {
+	m := NewModel(model.Store(memory.NewStore()))
+
+	users := []user{
+		{Name: "alice", Email: "alice@example.com", Age: 30},
+		{Name: "bob", Email: "bob@example.com", Age: 25},
+		{Name: "carol", Email: "carol@example.com", Age: 35},
+		{Name: "dave", Email: "dave@example.com", Age: 25},
+	}
+
+	for _, u := range users {
+		if err := m.Create(m.NewEntity("users", u)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return m
+}
+
+func TestReadMissingName(t *testing.T) {
+	m := NewModel()
+	if _, err := m.Read(); err != model.ErrMissingName {
+		t.Fatalf("Expected %v got %v", model.ErrMissingName, err)
+	}
+	if err := m.Delete(); err != model.ErrMissingName {
+		t.Fatalf("Expected %v got %v", model.ErrMissingName, err)
+	}
+}
+
+func TestDeleteMissingFilter(t *testing.T) {
+	m := NewModel()
+	for _, name := range []string{"alice", "bob"} {
+		if err := m.Create(m.NewEntity("users", user{Name: name})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.Delete(model.DeleteFrom("users")); err != model.ErrMissingFilter {
+		t.Fatalf("Expected %v got %v", model.ErrMissingFilter, err)
+	}
+	if entities, err := m.Read(model.ReadFrom("users")); err != nil || len(entities) != 2 {
+		t.Fatalf("Expected 2 entities got %d and %v", len(entities), err)
+	}
+
+	if err := m.Delete(model.DeleteFrom("users"), model.DeleteAll()); err != nil {
+		t.Fatal(err)
+	}
+	if entities, err := m.Read(model.ReadFrom("users")); err != nil || len(entities) != 0 {
+		t.Fatalf("Expected 0 entities got %d and %v", len(entities), err)
+	}
+}
+
+func TestReadById(t *testing.T) {
+	m := NewModel()
+
+	e := m.NewEntity("users", user{Name: "alice", Age: 30})
+	e.Attributes()["source"] = "test"
+	if err := m.Create(e); err != nil {
+		t.Fatal(err)
+	}
+
+	entities, err := m.Read(model.ReadFrom("users"), model.ReadId(e.Id()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 1 {
+		t.Fatalf("Expected 1 entity got %d", len(entities))
+	}
+	if entities[0].Id() != e.Id() {
+		t.Fatalf("Expected id %s got %s", e.Id(), entities[0].Id())
+	}
+	if entities[0].Attributes()["source"] != "test" {
+		t.Fatalf("Expected attributes to be read back, got %v", entities[0].Attributes())
+	}
+
+	var u user
+	if err := entities[0].Read(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "alice" || u.Age != 30 {
+		t.Fatalf("Unexpected value %+v", u)
+	}
+
+	entities, err = m.Read(model.ReadFrom("users"), model.ReadId("missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 0 {
+		t.Fatalf("Expected no entities got %d", len(entities))
+	}
+}
+
+func TestReadQuery(t *testing.T) {
+	m := testModel(t)
+
+	testData := []struct {
+		name   string
+		opts   []model.ReadOption
+		expect []string
+	}{
+		{
+			name:   "All",
+			opts:   []model.ReadOption{model.ReadOrder("name")},
+			expect: []string{"alice", "bob", "carol", "dave"},
+		},
+		{
+			name:   "Equal",
+			opts:   []model.ReadOption{model.ReadWhere("email", "bob@example.com")},
+			expect: []string{"bob"},
+		},
+		{
+			name:   "EqualNumber",
+			opts:   []model.ReadOption{model.ReadWhere("age", 25), model.ReadOrder("name")},
+			expect: []string{"bob", "dave"},
+		},
+		{
+			name:   "EqualMany",
+			opts:   []model.ReadOption{model.ReadWhere("age", 25), model.ReadWhere("name", "dave")},
+			expect: []string{"dave"},
+		},
+		{
+			name:   "NoMatch",
+			opts:   []model.ReadOption{model.ReadWhere("name", "eve")},
+			expect: []string{},
+		},
+		{
+			name:   "OrderDesc",
+			opts:   []model.ReadOption{model.ReadOrderDesc("age"), model.ReadWhere("age", 30)},
+			expect: []string{"alice"},
+		},
+		{
+			name:   "OrderDescLimit",
+			opts:   []model.ReadOption{model.ReadOrderDesc("name"), model.ReadLimit(2)},
+			expect: []string{"dave", "carol"},
+		},
+		{
+			name:   "Offset",
+			opts:   []model.ReadOption{model.ReadOrder("name"), model.ReadLimit(2), model.ReadOffset(1)},
+			expect: []string{"bob", "carol"},
+		},
+		{
+			name:   "OffsetPastEnd",
+			opts:   []model.ReadOption{model.ReadOrder("name"), model.ReadOffset(10)},
+			expect: []string{},
+		},
+	}
+
+	for _, d := range testData {
+		t.Run(d.name, func(t *testing.T) {
+			opts := append([]model.ReadOption{model.ReadFrom("users")}, d.opts...)
+			entities, err := m.Read(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entities) != len(d.expect) {
+				t.Fatalf("Expected %d entities got %d", len(d.expect), len(entities))
+			}
+			for i, e := range entities {
+				var u user
+				if err := e.Read(&u); err != nil {
+					t.Fatal(err)
+				}
+				if u.Name != d.expect[i] {
+					t.Errorf("Expected %s at %d got %s", d.expect[i], i, u.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	m := testModel(t)
+
+	if err := m.Delete(model.DeleteFrom("users"), model.DeleteWhere("age", 25)); err != nil {
+		t.Fatal(err)
+	}
+
+	entities, err := m.Read(model.ReadFrom("users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 2 {
+		t.Fatalf("Expected 2 entities got %d", len(entities))
+	}
+
+	if err := m.Delete(model.DeleteFrom("users"), model.DeleteId(entities[0].Id())); err != nil {
+		t.Fatal(err)
+	}
+
+	entities, err = m.Read(model.ReadFrom("users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 1 {
+		t.Fatalf("Expected 1 entity got %d", len(entities))
+	}
+}
+
+func TestOutboxEvents(t *testing.T) {
+	s := memory.NewStore()
+	m := NewModel(model.Store(s))
+	if err := m.Create(m.NewEntity("users", user{Name: "alice", Age: 30})); err != nil {
+		t.Fatal(err)
+	}
+
+	// an event written to the table with the records by the outbox
+	event := &store.Record{Key: outboxPrefix + "1", Value: []byte(`{"topic":"users"}`)}
+	if err := s.Write(event, store.WriteTo("", "users")); err != nil {
+		t.Fatal(err)
+	}
+
+	entities, err := m.Read(model.ReadFrom("users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 1 {
+		t.Fatalf("Expected 1 entity got %d", len(entities))
+	}
+	if entities, err := m.Read(model.ReadFrom("users"), model.ReadId(event.Key)); err != nil || len(entities) != 0 {
+		t.Fatalf("Expected the event not to be read by id, got %d entities and %v", len(entities), err)
+	}
+
+	if err := m.Delete(model.DeleteFrom("users"), model.DeleteAll()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(event.Key, store.ReadFrom("", "users")); err != nil {
+		t.Fatalf("Expected the event not to be deleted, got %v", err)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	s := memory.NewStore()
+	m := NewModel(
+		model.Store(s),
+		model.Indexes(
+			model.NewIndex("users", "email"),
+			model.NewIndex("users", "age", "name"),
+		),
+	)
+
+	alice := m.NewEntity("users", user{Name: "alice", Email: "alice@example.com", Age: 30})
+	bob := m.NewEntity("users", user{Name: "bob", Email: "bob@example.com", Age: 25})
+
+	for _, e := range []model.Entity{alice, bob} {
+		if err := m.Create(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries := func() int {
+		keys, err := s.List(store.ListFrom("", "users"), store.ListPrefix("index:"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(keys)
+	}
+
+	if n := entries(); n != 4 {
+		t.Fatalf("Expected 4 index entries got %d", n)
+	}
+
+	read := func(opts ...model.ReadOption) []model.Entity {
+		entities, err := m.Read(append([]model.ReadOption{model.ReadFrom("users")}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entities
+	}
+
+	if e := read(model.ReadWhere("email", "bob@example.com")); len(e) != 1 || e[0].Id() != bob.Id() {
+		t.Fatalf("Expected bob to be read by email, got %v", e)
+	}
+	if e := read(model.ReadWhere("age", 30)); len(e) != 1 || e[0].Id() != alice.Id() {
+		t.Fatalf("Expected alice to be read by the leading field of the index, got %v", e)
+	}
+	if e := read(model.ReadWhere("age", 30), model.ReadWhere("name", "bob")); len(e) != 0 {
+		t.Fatalf("Expected no entities, got %v", e)
+	}
+
+	// update bob's email and check the index follows
+	updated := &mudEntity{
+		id:         bob.Id(),
+		name:       "users",
+		value:      user{Name: "bob", Email: "robert@example.com", Age: 25},
+		codec:      m.(*mudModel).options.Codec,
+		attributes: map[string]interface{}{},
+	}
+	if err := m.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	if n := entries(); n != 4 {
+		t.Fatalf("Expected 4 index entries after update got %d", n)
+	}
+	if e := read(model.ReadWhere("email", "bob@example.com")); len(e) != 0 {
+		t.Fatalf("Expected the old email to be unindexed, got %v", e)
+	}
+	if e := read(model.ReadWhere("email", "robert@example.com")); len(e) != 1 || e[0].Id() != bob.Id() {
+		t.Fatalf("Expected bob to be read by the new email, got %v", e)
+	}
+
+	// delete alice and check her entries are removed
+	if err := m.Delete(model.DeleteFrom("users"), model.DeleteWhere("email", "alice@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if n := entries(); n != 2 {
+		t.Fatalf("Expected 2 index entries after delete got %d", n)
+	}
+	if e := read(); len(e) != 1 || e[0].Id() != bob.Id() {
+		t.Fatalf("Expected only bob to remain, got %v", e)
+	}
+}
+
+func TestUpdateConflict(t *testing.T) {
+	m := NewModel()
+
+	if err := m.Create(m.NewEntity("users", user{Name: "alice", Age: 30})); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() model.Entity {
+		entities, err := m.Read(model.ReadFrom("users"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entities) != 1 {
+			t.Fatalf("Expected 1 entity got %d", len(entities))
+		}
+		return entities[0]
+	}
+
+	first, second := read(), read()
+
+	first.(*mudEntity).value = user{Name: "alice", Age: 31}
+	if err := m.Update(first); err != nil {
+		t.Fatal(err)
+	}
+
+	second.(*mudEntity).value = user{Name: "alice", Age: 32}
+	if err := m.Update(second); err != store.ErrVersionConflict {
+		t.Fatalf("Expected %v got %v", store.ErrVersionConflict, err)
+	}
+
+	// the first entity is at the latest version so can be updated again
+	if err := m.Update(first); err != nil {
+		t.Fatal(err)
+	}
+
+	var u user
+	if err := read().Read(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Age != 31 {
+		t.Fatalf("Expected age 31 got %d", u.Age)
+	}
+}
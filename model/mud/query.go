@@ -0,0 +1,200 @@
+package mud
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/micro/go-micro/v3/model"
+	"github.com/micro/go-micro/v3/store"
+)
+
+// result is an entity along with its decoded fields
+type result struct {
+	entity  model.Entity
+	fields  map[string]interface{}
+	version uint64
+}
+
+// query reads the entities with the given name from the store, by id if one
+// is provided, and returns those whose fields are equal to the query
+func (m *mudModel) query(name, id string, query map[string]interface{}, decode bool) ([]*result, error) {
+	// round trip the query through the codec so values
+	// compare equal to the fields we decode from the store
+	match, err := m.normalise(query)
+	if err != nil {
+		return nil, err
+	}
+
+	recs, err := m.records(name, id, match)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*result, 0, len(recs))
+
+	for _, rec := range recs {
+		r := &result{
+			entity:  recordToEntity(name, rec, m.options.Codec),
+			version: rec.Version,
+		}
+
+		if len(match) > 0 || decode {
+			r.fields, err = m.decode(rec.Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !matches(r.fields, match) {
+			continue
+		}
+
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// records reads the candidate records for a query, by id if one is provided,
+// via an index if one covers the query, or otherwise the whole table
+func (m *mudModel) records(name, id string, query map[string]interface{}) ([]*store.Record, error) {
+	if len(id) > 0 {
+		return m.read(name, id)
+	}
+
+	ids, ok, err := m.lookup(name, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		recs, err := m.options.Store.Read("", store.ReadFrom(m.options.Database, name), store.ReadPrefix())
+		if err != nil && err != store.ErrNotFound {
+			return nil, err
+		}
+
+		// skip the index entries and outbox events kept alongside the records
+		var entities []*store.Record
+		for _, r := range recs {
+			if reserved(r.Key) {
+				continue
+			}
+			entities = append(entities, r)
+		}
+
+		return entities, nil
+	}
+
+	var recs []*store.Record
+	for _, id := range ids {
+		r, err := m.read(name, id)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, r...)
+	}
+
+	return recs, nil
+}
+
+// read a single record by id, returning no records if it does not exist
+func (m *mudModel) read(name, id string) ([]*store.Record, error) {
+	if reserved(id) {
+		return nil, nil
+	}
+
+	recs, err := m.options.Store.Read(id, store.ReadFrom(m.options.Database, name))
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	return recs, err
+}
+
+// decode the fields of a value. Values which are not objects have no fields.
+func (m *mudModel) decode(b []byte) (map[string]interface{}, error) {
+	var v interface{}
+	if err := m.options.Codec.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	fields, _ := v.(map[string]interface{})
+	return fields, nil
+}
+
+func (m *mudModel) normalise(query map[string]interface{}) (map[string]interface{}, error) {
+	if len(query) == 0 {
+		return nil, nil
+	}
+
+	b, err := m.options.Codec.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.decode(b)
+}
+
+// matches returns true if every field in the query is equal
+func matches(fields, query map[string]interface{}) bool {
+	for k, v := range query {
+		f, ok := fields[k]
+		if !ok || !reflect.DeepEqual(f, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// compare two decoded field values returning -1, 0 or 1
+func compare(a, b interface{}) int {
+	switch av := a.(type) {
+	case nil:
+		if b == nil {
+			return 0
+		}
+		return -1
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0
+			case !av:
+				return -1
+			}
+			return 1
+		}
+	}
+
+	if b == nil {
+		return 1
+	}
+
+	// fall back to comparing the formatted values
+	as, bs := fmt.Sprint(a), fmt.Sprint(b)
+	switch {
+	case as < bs:
+		return -1
+	case as > bs:
+		return 1
+	}
+	return 0
+}
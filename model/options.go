@@ -0,0 +1,134 @@
+package model
+
+import (
+	"github.com/micro/go-micro/v3/codec"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/micro/go-micro/v3/sync"
+)
+
+// Database sets the database to write to
+func Database(db string) Option {
+	return func(o *Options) {
+		o.Database = db
+	}
+}
+
+// Codec sets the codec used to serialise values
+func Codec(c codec.Marshaler) Option {
+	return func(o *Options) {
+		o.Codec = c
+	}
+}
+
+// Sync sets the sync used for locking
+func Sync(s sync.Sync) Option {
+	return func(o *Options) {
+		o.Sync = s
+	}
+}
+
+// Store sets the store used for storage
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Indexes sets the secondary indexes to maintain
+func Indexes(idx ...Index) Option {
+	return func(o *Options) {
+		o.Indexes = append(o.Indexes, idx...)
+	}
+}
+
+// NewIndex returns an index for the named entity on the given fields
+func NewIndex(entity string, fields ...string) Index {
+	return Index{
+		Entity: entity,
+		Fields: fields,
+	}
+}
+
+// ReadFrom reads entities with the given name
+func ReadFrom(name string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Name = name
+	}
+}
+
+// ReadId reads the entity with the given id
+func ReadId(id string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Id = id
+	}
+}
+
+// ReadWhere reads entities where the field is equal to the value
+func ReadWhere(field string, value interface{}) ReadOption {
+	return func(o *ReadOptions) {
+		if o.Query == nil {
+			o.Query = make(map[string]interface{})
+		}
+		o.Query[field] = value
+	}
+}
+
+// ReadOrder orders the results by field in ascending order
+func ReadOrder(field string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Order = Order{Field: field}
+	}
+}
+
+// ReadOrderDesc orders the results by field in descending order
+func ReadOrderDesc(field string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Order = Order{Field: field, Desc: true}
+	}
+}
+
+// ReadLimit limits the number of results to l
+func ReadLimit(l uint) ReadOption {
+	return func(o *ReadOptions) {
+		o.Limit = l
+	}
+}
+
+// ReadOffset starts returning results from o. Use in conjunction with Limit for pagination
+func ReadOffset(off uint) ReadOption {
+	return func(o *ReadOptions) {
+		o.Offset = off
+	}
+}
+
+// DeleteFrom deletes entities with the given name
+func DeleteFrom(name string) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.Name = name
+	}
+}
+
+// DeleteId deletes the entity with the given id
+func DeleteId(id string) DeleteOption {
+	return func(o *DeleteOptions) {
+		o.Id = id
+	}
+}
+
+// DeleteAll deletes every entity with the given name. Without it a delete must
+// specify an id or a query
+func DeleteAll() DeleteOption {
+	return func(o *DeleteOptions) {
+		o.All = true
+	}
+}
+
+// DeleteWhere deletes entities where the field is equal to the value
+func DeleteWhere(field string, value interface{}) DeleteOption {
+	return func(o *DeleteOptions) {
+		if o.Query == nil {
+			o.Query = make(map[string]interface{})
+		}
+		o.Query[field] = value
+	}
+}
@@ -0,0 +1,234 @@
+// Package breaker is a selector which trips circuit breakers for failing nodes and services
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/router"
+	"github.com/micro/go-micro/v3/selector"
+)
+
+// NewSelector returns a selector which keeps a circuit breaker for each service and node. A
+// breaker opens after consecutive errors are recorded, and the routes with open breakers are
+// skipped when selecting. Once the cooldown has passed the breaker is half open and a single
+// trial request is allowed, which closes the breaker if it succeeds or opens it again if not.
+// A success closes the breaker of the node, but the service's breaker is only closed by a
+// successful trial. Errors are forgotten once none have been recorded for the cooldown.
+func NewSelector(opts ...selector.Option) selector.Selector {
+	b := &breaker{
+		nodes:    make(map[string]*circuit),
+		services: make(map[string]*circuit),
+	}
+	b.Init(opts...)
+	return b
+}
+
+type breaker struct {
+	options selector.Options
+	opts    options
+
+	sync.Mutex
+	// circuits of the nodes and services with errors recorded
+	nodes    map[string]*circuit
+	services map[string]*circuit
+	// evicted is when the stale circuits were last removed
+	evicted time.Time
+}
+
+// circuit is the breaker of a single node or service
+type circuit struct {
+	// failures is the number of consecutive errors
+	failures int
+	// last is when the last error was recorded
+	last time.Time
+	// opened is when the breaker was last opened
+	opened time.Time
+	// trial is when the trial request of a half open breaker was selected
+	trial time.Time
+	// node the trial request was sent to
+	node string
+}
+
+// available returns true if a request can be made, i.e. the breaker is closed, or it's half
+// open and no trial request is in progress
+func (c *circuit) available(threshold int, cooldown time.Duration, now time.Time) bool {
+	if c == nil || c.failures < threshold {
+		return true
+	}
+	if now.Sub(c.opened) < cooldown {
+		return false
+	}
+	// a trial which didn't record a result, e.g. it was never made, is abandoned after the cooldown
+	return now.Sub(c.trial) >= cooldown
+}
+
+// selected marks the trial request to the node as in progress if the breaker is half open
+func (c *circuit) selected(threshold int, now time.Time, node string) {
+	if c.open(threshold) {
+		c.trial = now
+		c.node = node
+	}
+}
+
+// trialled returns true if the breaker is half open and its trial request was sent to the node
+func (c *circuit) trialled(threshold int, node string) bool {
+	return c.open(threshold) && !c.trial.IsZero() && c.node == node
+}
+
+// open returns true if the breaker is open or half open
+func (c *circuit) open(threshold int) bool {
+	return c != nil && c.failures >= threshold
+}
+
+// failed records an error, opening the breaker when the threshold is reached or the trial failed.
+// The errors of a closed breaker are forgotten if none were recorded for the cooldown.
+func (c *circuit) failed(threshold int, cooldown time.Duration, now time.Time) {
+	if !c.open(threshold) && now.Sub(c.last) >= cooldown {
+		c.failures = 0
+	}
+	c.last = now
+	c.failures++
+	if c.failures >= threshold {
+		c.opened = now
+		c.trial = time.Time{}
+		c.node = ""
+	}
+}
+
+// stale returns true if the circuit can be removed. Closed breakers are stale once their
+// errors are forgotten, and open breakers once no request has been made for twice the
+// cooldown, e.g. because the node was removed.
+func (c *circuit) stale(threshold int, cooldown time.Duration, now time.Time) bool {
+	if !c.open(threshold) {
+		return now.Sub(c.last) >= cooldown
+	}
+	return now.Sub(c.last) >= 2*cooldown && now.Sub(c.trial) >= 2*cooldown
+}
+
+func nodeKey(r router.Route) string {
+	return r.Service + "/" + r.Address
+}
+
+// failure returns true if the error indicates the node or service is unhealthy. Errors such as
+// bad requests are the fault of the caller so don't count.
+func failure(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := errors.FromError(err).Code
+	return code == 0 || code == 408 || code >= 500
+}
+
+func (b *breaker) Init(opts ...selector.Option) error {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, o := range opts {
+		o(&b.options)
+	}
+	b.opts = newOptions(b.options)
+	return nil
+}
+
+func (b *breaker) Options() selector.Options {
+	b.Lock()
+	defer b.Unlock()
+	return b.options
+}
+
+func (b *breaker) Select(routes []router.Route, opts ...selector.SelectOption) (*router.Route, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	now := time.Now()
+
+	// skip the routes with open breakers
+	available := make([]router.Route, 0, len(routes))
+	for _, r := range routes {
+		if !b.services[r.Service].available(b.opts.serviceThreshold, b.opts.cooldown, now) {
+			continue
+		}
+		if !b.nodes[nodeKey(r)].available(b.opts.nodeThreshold, b.opts.cooldown, now) {
+			continue
+		}
+		available = append(available, r)
+	}
+	if len(routes) > 0 && len(available) == 0 {
+		return nil, selector.ErrOpen
+	}
+
+	route, err := b.opts.selector.Select(available, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	b.services[route.Service].selected(b.opts.serviceThreshold, now, nodeKey(*route))
+	b.nodes[nodeKey(*route)].selected(b.opts.nodeThreshold, now, nodeKey(*route))
+
+	return route, nil
+}
+
+func (b *breaker) Record(route router.Route, err error) error {
+	b.Lock()
+	defer b.Unlock()
+
+	key := nodeKey(route)
+
+	if !failure(err) {
+		// a success closes the node's breaker, and the service's if it was the trial. The
+		// successes of requests made before the service's breaker opened don't close it.
+		delete(b.nodes, key)
+		if b.services[route.Service].trialled(b.opts.serviceThreshold, key) {
+			delete(b.services, route.Service)
+		}
+		return b.opts.selector.Record(route, err)
+	}
+
+	now := time.Now()
+	b.evict(now)
+
+	node, ok := b.nodes[key]
+	if !ok {
+		node = new(circuit)
+		b.nodes[key] = node
+	}
+	node.failed(b.opts.nodeThreshold, b.opts.cooldown, now)
+
+	service, ok := b.services[route.Service]
+	if !ok {
+		service = new(circuit)
+		b.services[route.Service] = service
+	}
+	service.failed(b.opts.serviceThreshold, b.opts.cooldown, now)
+
+	return b.opts.selector.Record(route, err)
+}
+
+// evict removes the stale circuits, at most once per cooldown. The lock must be held.
+func (b *breaker) evict(now time.Time) {
+	if now.Sub(b.evicted) < b.opts.cooldown {
+		return
+	}
+	b.evicted = now
+
+	for key, c := range b.nodes {
+		if c.stale(b.opts.nodeThreshold, b.opts.cooldown, now) {
+			delete(b.nodes, key)
+		}
+	}
+	for key, c := range b.services {
+		if c.stale(b.opts.serviceThreshold, b.opts.cooldown, now) {
+			delete(b.services, key)
+		}
+	}
+}
+
+func (b *breaker) Close() error {
+	return b.opts.selector.Close()
+}
+
+func (b *breaker) String() string {
+	return "breaker"
+}
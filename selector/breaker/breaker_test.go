@@ -0,0 +1,139 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/router"
+	"github.com/micro/go-micro/v3/selector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	selector.Tests(t, NewSelector())
+
+	r1 := router.Route{Service: "go.micro.service.foo", Address: "127.0.0.1:8000"}
+	r2 := router.Route{Service: "go.micro.service.foo", Address: "127.0.0.1:8001"}
+	fail := errors.InternalServerError("go.micro.service.foo", "failed")
+
+	s := NewSelector(WithNodeThreshold(2), WithServiceThreshold(10), WithCooldown(50*time.Millisecond))
+
+	// client errors don't open the breaker
+	for i := 0; i < 3; i++ {
+		s.Record(r1, errors.BadRequest("go.micro.service.foo", "bad request"))
+	}
+	_, err := s.Select([]router.Route{r1})
+	assert.Nil(t, err, "Expected the breaker to be closed")
+
+	// consecutive errors open the breaker and the node is skipped
+	s.Record(r1, fail)
+	s.Record(r1, fail)
+	for i := 0; i < 10; i++ {
+		srv, err := s.Select([]router.Route{r1, r2})
+		assert.Nil(t, err, "Error should be nil")
+		assert.Equal(t, r2, *srv, "Expected the open node to be skipped")
+	}
+	_, err = s.Select([]router.Route{r1})
+	assert.Equal(t, selector.ErrOpen, err, "Expected the breaker to be open")
+
+	// after the cooldown a single trial request is allowed
+	time.Sleep(60 * time.Millisecond)
+	srv, err := s.Select([]router.Route{r1})
+	assert.Nil(t, err, "Expected the breaker to be half open")
+	assert.Equal(t, r1, *srv, "Expected the trial request")
+	_, err = s.Select([]router.Route{r1})
+	assert.Equal(t, selector.ErrOpen, err, "Expected a single trial request")
+
+	// a failed trial opens the breaker again
+	s.Record(r1, fail)
+	_, err = s.Select([]router.Route{r1})
+	assert.Equal(t, selector.ErrOpen, err, "Expected the breaker to be open")
+
+	// a successful trial closes the breaker
+	time.Sleep(60 * time.Millisecond)
+	_, err = s.Select([]router.Route{r1})
+	assert.Nil(t, err, "Expected the breaker to be half open")
+	s.Record(r1, nil)
+	for i := 0; i < 2; i++ {
+		_, err = s.Select([]router.Route{r1})
+		assert.Nil(t, err, "Expected the breaker to be closed")
+	}
+}
+
+func TestServiceBreaker(t *testing.T) {
+	r1 := router.Route{Service: "go.micro.service.foo", Address: "127.0.0.1:8000"}
+	r2 := router.Route{Service: "go.micro.service.foo", Address: "127.0.0.1:8001"}
+	r3 := router.Route{Service: "go.micro.service.bar", Address: "127.0.0.1:8002"}
+
+	s := NewSelector(WithNodeThreshold(10), WithServiceThreshold(2))
+
+	// errors across the nodes open the service's breaker
+	s.Record(r1, errors.Timeout("go.micro.client", "timeout"))
+	s.Record(r2, errors.Timeout("go.micro.client", "timeout"))
+
+	_, err := s.Select([]router.Route{r1, r2})
+	assert.Equal(t, selector.ErrOpen, err, "Expected the service's breaker to be open")
+
+	_, err = s.Select([]router.Route{r3})
+	assert.Nil(t, err, "Expected other services to be unaffected")
+}
+
+func TestServiceBreakerSuccess(t *testing.T) {
+	r1 := router.Route{Service: "go.micro.service.foo", Address: "127.0.0.1:8000"}
+	r2 := router.Route{Service: "go.micro.service.foo", Address: "127.0.0.1:8001"}
+	fail := errors.InternalServerError("go.micro.service.foo", "failed")
+
+	s := NewSelector(WithNodeThreshold(2), WithServiceThreshold(3), WithCooldown(50*time.Millisecond))
+
+	// a success only closes the breaker of the node
+	s.Record(r1, fail)
+	s.Record(r1, fail)
+	s.Record(r2, nil)
+	_, err := s.Select([]router.Route{r1})
+	assert.Equal(t, selector.ErrOpen, err, "Expected the node's breaker to be open")
+
+	// so the errors of the service are still counted
+	s.Record(r2, fail)
+	_, err = s.Select([]router.Route{r2})
+	assert.Equal(t, selector.ErrOpen, err, "Expected the service's breaker to be open")
+
+	// the success of a request made before it opened doesn't close it
+	s.Record(r2, nil)
+	_, err = s.Select([]router.Route{r2})
+	assert.Equal(t, selector.ErrOpen, err, "Expected the service's breaker to be open")
+
+	// nor does a success from another node whilst the trial is in progress
+	time.Sleep(60 * time.Millisecond)
+	srv, err := s.Select([]router.Route{r2})
+	assert.Nil(t, err, "Expected the service's breaker to be half open")
+	assert.Equal(t, r2, *srv, "Expected the trial request")
+	s.Record(r1, nil)
+	_, err = s.Select([]router.Route{r1, r2})
+	assert.Equal(t, selector.ErrOpen, err, "Expected the service's breaker to be open")
+
+	// the successful trial closes it
+	s.Record(r2, nil)
+	_, err = s.Select([]router.Route{r1, r2})
+	assert.Nil(t, err, "Expected the service's breaker to be closed")
+}
+
+func TestBreakerEvict(t *testing.T) {
+	r1 := router.Route{Service: "go.micro.service.foo", Address: "127.0.0.1:8000"}
+	r2 := router.Route{Service: "go.micro.service.foo", Address: "127.0.0.1:8001"}
+	fail := errors.InternalServerError("go.micro.service.foo", "failed")
+
+	s := NewSelector(WithNodeThreshold(2), WithCooldown(20*time.Millisecond))
+	b := s.(*breaker)
+
+	// the node is removed while its breaker is open
+	s.Record(r1, fail)
+	s.Record(r1, fail)
+	time.Sleep(50 * time.Millisecond)
+
+	s.Record(r2, fail)
+	b.Lock()
+	_, ok := b.nodes[nodeKey(r1)]
+	b.Unlock()
+	assert.False(t, ok, "Expected the stale node to be evicted")
+}
@@ -0,0 +1,89 @@
+package breaker
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v3/selector"
+)
+
+var (
+	// DefaultNodeThreshold is the number of consecutive errors which open a node's breaker
+	DefaultNodeThreshold = 5
+	// DefaultServiceThreshold is the number of consecutive errors which open a service's breaker
+	DefaultServiceThreshold = 20
+	// DefaultCooldown is how long a breaker stays open before a trial request is allowed
+	DefaultCooldown = time.Second * 30
+)
+
+type selectorKey struct{}
+type nodeThresholdKey struct{}
+type serviceThresholdKey struct{}
+type cooldownKey struct{}
+
+func setOption(k, v interface{}) selector.Option {
+	return func(o *selector.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// WithSelector sets the selector used to select from the routes with closed or half open
+// breakers. The random selector is used by default.
+func WithSelector(s selector.Selector) selector.Option {
+	return setOption(selectorKey{}, s)
+}
+
+// WithNodeThreshold sets the number of consecutive errors which open a node's breaker
+func WithNodeThreshold(n int) selector.Option {
+	return setOption(nodeThresholdKey{}, n)
+}
+
+// WithServiceThreshold sets the number of consecutive errors, across all the nodes of a
+// service, which open the service's breaker
+func WithServiceThreshold(n int) selector.Option {
+	return setOption(serviceThresholdKey{}, n)
+}
+
+// WithCooldown sets how long a breaker stays open before it's half open and a trial
+// request is allowed
+func WithCooldown(d time.Duration) selector.Option {
+	return setOption(cooldownKey{}, d)
+}
+
+type options struct {
+	selector         selector.Selector
+	nodeThreshold    int
+	serviceThreshold int
+	cooldown         time.Duration
+}
+
+func newOptions(o selector.Options) options {
+	opts := options{
+		nodeThreshold:    DefaultNodeThreshold,
+		serviceThreshold: DefaultServiceThreshold,
+		cooldown:         DefaultCooldown,
+	}
+	if o.Context == nil {
+		opts.selector = selector.NewSelector()
+		return opts
+	}
+
+	if s, ok := o.Context.Value(selectorKey{}).(selector.Selector); ok {
+		opts.selector = s
+	} else {
+		opts.selector = selector.NewSelector()
+	}
+	if n, ok := o.Context.Value(nodeThresholdKey{}).(int); ok && n > 0 {
+		opts.nodeThreshold = n
+	}
+	if n, ok := o.Context.Value(serviceThresholdKey{}).(int); ok && n > 0 {
+		opts.serviceThreshold = n
+	}
+	if d, ok := o.Context.Value(cooldownKey{}).(time.Duration); ok && d > 0 {
+		opts.cooldown = d
+	}
+	return opts
+}
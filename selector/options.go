@@ -1,9 +1,17 @@
 package selector
 
-import "github.com/micro/go-micro/v3/router"
+import (
+	"context"
+
+	"github.com/micro/go-micro/v3/router"
+)
 
 // Options used to configure a selector
-type Options struct{}
+type Options struct {
+	// Other options for implementations of the interface
+	// can be stored in a context
+	Context context.Context
+}
 
 // Option updates the options
 type Option func(*Options)
@@ -13,6 +13,9 @@ var (
 
 	// ErrNoneAvailable is returned by select when no routes were provided to select from
 	ErrNoneAvailable = errors.New("none available")
+
+	// ErrOpen is returned by select when the circuit breakers of all the routes are open
+	ErrOpen = errors.New("circuit breaker open")
 )
 
 // Selector selects a route from a pool
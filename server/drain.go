@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/errors"
+)
+
+// Drain tracks the requests and messages being handled by a server so they can finish
+// before it's stopped. Once the server is draining new streams are rejected, requests
+// are still handled since clients may not have noticed the server deregistering.
+type Drain struct {
+	sync.Mutex
+	stopping bool
+	draining bool
+	inflight int
+	done     chan struct{}
+	// cancelled once the server is stopping
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewDrain returns a Drain which isn't draining
+func NewDrain() *Drain {
+	return &Drain{}
+}
+
+func (d *Drain) add(stream bool) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.draining && stream {
+		return false
+	}
+	d.inflight++
+	return true
+}
+
+func (d *Drain) release() {
+	d.Lock()
+	defer d.Unlock()
+
+	d.inflight--
+	if d.inflight == 0 && d.done != nil {
+		close(d.done)
+		d.done = nil
+	}
+}
+
+// HandlerWrapper tracks the requests being handled, rejecting new streams when draining
+func (d *Drain) HandlerWrapper(h HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req Request, rsp interface{}) error {
+		if !d.add(req.Stream()) {
+			return errors.ServiceUnavailable(req.Service(), "server is shutting down")
+		}
+		defer d.release()
+		return h(ctx, req, rsp)
+	}
+}
+
+// SubscriberWrapper tracks the messages being handled
+func (d *Drain) SubscriberWrapper(fn SubscriberFunc) SubscriberFunc {
+	return func(ctx context.Context, msg Message) error {
+		d.add(false)
+		defer d.release()
+		return fn(ctx, msg)
+	}
+}
+
+// Track a message being handled outside of the subscriber wrappers, e.g. whilst it's being
+// retried, so the server waits for it when draining. The returned func is called once the
+// message has been handled.
+func (d *Drain) Track() func() {
+	d.add(false)
+	return d.release
+}
+
+// Context returns a context which is cancelled once the server is stopping, e.g. so the
+// messages being retried are left to the broker to redeliver
+func (d *Drain) Context() context.Context {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.ctx == nil {
+		d.ctx, d.cancel = context.WithCancel(context.Background())
+		if d.stopping {
+			d.cancel()
+		}
+	}
+	return d.ctx
+}
+
+// Stop marks the server as stopping so its health check reports it isn't serving, and
+// starts draining whether or not Wait is called. New streams are rejected from then on,
+// requests are still handled.
+func (d *Drain) Stop() {
+	d.Lock()
+	defer d.Unlock()
+	d.stopping = true
+	d.draining = true
+	if d.cancel != nil {
+		d.cancel()
+	}
+}
+
+// Check is a HealthCheck which fails once the server is stopping
+func (d *Drain) Check(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+	if d.stopping {
+		return fmt.Errorf("server is stopping")
+	}
+	return nil
+}
+
+// Wait starts draining, if not already stopped, and waits for the requests and messages being handled, and the
+// wait group if not nil, to finish. False is returned if they didn't within the timeout.
+func (d *Drain) Wait(timeout time.Duration, wg *sync.WaitGroup) bool {
+	d.Lock()
+	d.draining = true
+	done := make(chan struct{})
+	if d.inflight == 0 {
+		close(done)
+	} else {
+		d.done = done
+	}
+	d.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		<-done
+		if wg != nil {
+			wg.Wait()
+		}
+		close(finished)
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case <-finished:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// Reset stops draining so the server can be started again
+func (d *Drain) Reset() {
+	d.Lock()
+	defer d.Unlock()
+
+	d.stopping = false
+	d.draining = false
+	d.done = nil
+	d.ctx, d.cancel = nil, nil
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/errors"
+)
+
+type testRequest struct {
+	Request
+	stream bool
+}
+
+func (r *testRequest) Service() string  { return "foo" }
+func (r *testRequest) Endpoint() string { return "Foo.Bar" }
+func (r *testRequest) Stream() bool     { return r.stream }
+
+func TestDrain(t *testing.T) {
+	d := NewDrain()
+
+	release := make(chan struct{})
+	h := d.HandlerWrapper(func(ctx context.Context, req Request, rsp interface{}) error {
+		<-release
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- h(context.TODO(), &testRequest{}, nil) }()
+	time.Sleep(10 * time.Millisecond)
+
+	// the request is in-flight so draining times out
+	if d.Wait(10*time.Millisecond, nil) {
+		t.Fatal("Expected the drain to time out")
+	}
+
+	// new streams are rejected whilst draining, requests are still handled
+	err := h(context.TODO(), &testRequest{stream: true}, nil)
+	if verr := errors.FromError(err); verr.Code != 503 {
+		t.Errorf("Expected the stream to be rejected with a 503, got %v", err)
+	}
+	noop := d.HandlerWrapper(func(ctx context.Context, req Request, rsp interface{}) error { return nil })
+	if err := noop(context.TODO(), &testRequest{}, nil); err != nil {
+		t.Errorf("Expected the request to be handled, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !d.Wait(time.Second, nil) {
+		t.Error("Expected the drain to finish")
+	}
+
+	// streams are handled again once reset
+	d.Reset()
+	if err := noop(context.TODO(), &testRequest{stream: true}, nil); err != nil {
+		t.Errorf("Expected the stream to be handled, got %v", err)
+	}
+}
+
+func TestDrainCheck(t *testing.T) {
+	d := new(Drain)
+	if err := d.Check(context.TODO()); err != nil {
+		t.Fatalf("Expected the check to pass, got %v", err)
+	}
+
+	ctx := d.Context()
+	d.Stop()
+	if err := d.Check(context.TODO()); err == nil {
+		t.Fatal("Expected the check to fail once stopping")
+	}
+	if ctx.Err() == nil || d.Context().Err() == nil {
+		t.Fatal("Expected the context to be cancelled once stopping")
+	}
+
+	// streams are rejected once stopping, without waiting for the drain
+	h := d.HandlerWrapper(func(ctx context.Context, req Request, rsp interface{}) error { return nil })
+	if err := h(context.TODO(), &testRequest{stream: true}, nil); err == nil {
+		t.Fatal("Expected the stream to be rejected once stopping")
+	}
+
+	d.Reset()
+	if err := d.Check(context.TODO()); err != nil {
+		t.Fatalf("Expected the check to pass once reset, got %v", err)
+	}
+	if err := d.Context().Err(); err != nil {
+		t.Fatalf("Expected a new context once reset, got %v", err)
+	}
+}
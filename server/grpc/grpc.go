@@ -22,9 +22,11 @@ import (
 	meta "github.com/micro/go-micro/v3/metadata"
 	"github.com/micro/go-micro/v3/registry"
 	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/server/health"
 	"github.com/micro/go-micro/v3/util/addr"
 	"github.com/micro/go-micro/v3/util/backoff"
 	mgrpc "github.com/micro/go-micro/v3/util/grpc"
+	"github.com/micro/go-micro/v3/util/limit"
 	mnet "github.com/micro/go-micro/v3/util/net"
 	"golang.org/x/net/netutil"
 
@@ -64,6 +66,10 @@ type grpcServer struct {
 
 	// registry service instance
 	rsvc *registry.Service
+	// enforces the rate and concurrency limits
+	limiter *limit.Limiter
+	// tracks the requests and messages being handled
+	drain *server.Drain
 }
 
 func init() {
@@ -85,14 +91,64 @@ func newGRPCServer(opts ...server.Option) server.Server {
 		subscribers: make(map[*subscriber][]broker.Subscriber),
 		exit:        make(chan chan error),
 		wg:          wait(options.Context),
+		limiter:     limit.NewLimiter(options),
+		drain:       server.NewDrain(),
 	}
 
 	// configure the grpc server
 	srv.configure()
 
+	// register the health service, the broker is only connected if there are subscribers
+	options.Health.AddCheck("broker", func(ctx context.Context) error {
+		srv.RLock()
+		subscribed := len(srv.subscribers) > 0
+		srv.RUnlock()
+		if !subscribed {
+			return nil
+		}
+		return health.Ping(srv.Options().Broker)
+	})
+	options.Health.AddCheck("drain", srv.drain.Check)
+	if err := health.Register(srv); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Server health service error: %v", err)
+		}
+	}
+
 	return srv
 }
 
+// hdlrWrappers returns the handler wrappers, the requests are tracked so the server can be
+// drained, then expired requests are rejected and the limits enforced first
+func (g *grpcServer) hdlrWrappers(opts server.Options) []server.HandlerWrapper {
+	return append([]server.HandlerWrapper{g.drain.HandlerWrapper, server.DeadlineWrapper, g.limiter.HandlerWrapper}, opts.HdlrWrappers...)
+}
+
+// subscriberHandler returns the broker handler of the subscriber's messages, failed messages
+// are retried by the server so the policy holds whatever the broker. The retries are tracked
+// so the server waits for them when draining, and stop once it's stopping.
+func (g *grpcServer) subscriberHandler(sb *subscriber, b broker.Broker) broker.Handler {
+	handler := g.createSubHandler(sb, g.opts)
+
+	return func(e broker.Event) error {
+		defer g.drain.Track()()
+
+		return broker.RetryHandler(b, handler, broker.SubscribeOptions{
+			AutoAck:     sb.Options().AutoAck,
+			MaxAttempts: sb.Options().MaxAttempts,
+			Backoff:     sb.Options().Backoff,
+			DeadLetter:  sb.Options().DeadLetter,
+			Context:     g.drain.Context(),
+		})(e)
+	}
+}
+
+// subWrappers returns the subscriber wrappers, the messages are tracked so the server can be
+// drained and the limits of the subscriber enforced first
+func (g *grpcServer) subWrappers(sb server.Subscriber, opts server.Options) []server.SubscriberWrapper {
+	return append([]server.SubscriberWrapper{g.drain.SubscriberWrapper, g.limiter.SubscriberWrapper(sb)}, opts.SubWrappers...)
+}
+
 type grpcRouter struct {
 	h func(context.Context, server.Request, interface{}) error
 	m func(context.Context, server.Message) error
@@ -120,6 +176,7 @@ func (g *grpcServer) configure(opts ...server.Option) {
 	}
 
 	g.wg = wait(g.opts.Context)
+	g.limiter.Init(g.opts)
 
 	maxMsgSize := g.getMaxMsgSize()
 
@@ -212,6 +269,11 @@ func (g *grpcServer) handler(srv interface{}, stream grpc.ServerStream) (err err
 		return status.New(codes.InvalidArgument, err.Error()).Err()
 	}
 
+	// the gRPC health checking protocol is served by the health handler
+	if strings.HasPrefix(fullMethod, "/"+health.Service+"/") {
+		serviceName = health.Name
+	}
+
 	// get grpc metadata
 	gmd, ok := metadata.FromIncomingContext(stream.Context())
 	if !ok {
@@ -292,8 +354,9 @@ func (g *grpcServer) handler(srv interface{}, stream grpc.ServerStream) (err err
 		}
 
 		// execute the wrapper for it
-		for i := len(g.opts.HdlrWrappers); i > 0; i-- {
-			handler = g.opts.HdlrWrappers[i-1](handler)
+		wrappers := g.hdlrWrappers(g.opts)
+		for i := len(wrappers); i > 0; i-- {
+			handler = wrappers[i-1](handler)
 		}
 
 		r := grpcRouter{h: handler}
@@ -391,8 +454,9 @@ func (g *grpcServer) processRequest(stream grpc.ServerStream, service *service,
 		}
 
 		// wrap the handler func
-		for i := len(g.opts.HdlrWrappers); i > 0; i-- {
-			fn = g.opts.HdlrWrappers[i-1](fn)
+		wrappers := g.hdlrWrappers(g.opts)
+		for i := len(wrappers); i > 0; i-- {
+			fn = wrappers[i-1](fn)
 		}
 
 		statusCode := codes.OK
@@ -472,8 +536,9 @@ func (g *grpcServer) processStream(stream grpc.ServerStream, service *service, m
 		return nil
 	}
 
-	for i := len(opts.HdlrWrappers); i > 0; i-- {
-		fn = opts.HdlrWrappers[i-1](fn)
+	wrappers := g.hdlrWrappers(opts)
+	for i := len(wrappers); i > 0; i-- {
+		fn = wrappers[i-1](fn)
 	}
 
 	statusCode := codes.OK
@@ -556,6 +621,10 @@ func (g *grpcServer) Handle(h server.Handler) error {
 		return err
 	}
 
+	for name, l := range h.Options().Limits {
+		g.limiter.Set(name, l)
+	}
+
 	g.handlers[h.Name()] = h
 	return nil
 }
@@ -743,7 +812,7 @@ func (g *grpcServer) Register() error {
 	defer g.Unlock()
 
 	for sb := range g.subscribers {
-		handler := g.createSubHandler(sb, g.opts)
+		handler := g.subscriberHandler(sb, config.Broker)
 		var opts []broker.SubscribeOption
 		if queue := sb.Options().Queue; len(queue) > 0 {
 			opts = append(opts, broker.Queue(queue))
@@ -776,6 +845,15 @@ func (g *grpcServer) Register() error {
 }
 
 func (g *grpcServer) Deregister() error {
+	if err := g.deregister(); err != nil {
+		return err
+	}
+	g.unsubscribe()
+	return nil
+}
+
+// deregister the node without unsubscribing, so messages are still handled whilst draining
+func (g *grpcServer) deregister() error {
 	var err error
 	var advt, host, port string
 
@@ -834,13 +912,16 @@ func (g *grpcServer) Deregister() error {
 
 	g.Lock()
 	g.rsvc = nil
+	g.registered = false
+	g.Unlock()
 
-	if !g.registered {
-		g.Unlock()
-		return nil
-	}
+	return nil
+}
 
-	g.registered = false
+// unsubscribe closes the broker subscriptions
+func (g *grpcServer) unsubscribe() {
+	g.Lock()
+	defer g.Unlock()
 
 	wg := sync.WaitGroup{}
 	for sb, subs := range g.subscribers {
@@ -857,9 +938,6 @@ func (g *grpcServer) Deregister() error {
 		g.subscribers[sb] = nil
 	}
 	wg.Wait()
-
-	g.Unlock()
-	return nil
 }
 
 func (g *grpcServer) Start() error {
@@ -964,18 +1042,39 @@ func (g *grpcServer) Start() error {
 			}
 		}
 
-		// deregister self
-		if err := g.Deregister(); err != nil {
+		// start draining and report the server isn't serving so it's taken out of rotation
+		g.drain.Stop()
+
+		g.RLock()
+		registered := g.registered
+		g.RUnlock()
+
+		// deregister self, clients stop sending requests once they notice
+		if err := g.deregister(); err != nil {
 			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
 				logger.Error("Server deregister error: ", err)
 			}
 		}
 
-		// wait for waitgroup
-		if g.wg != nil {
+		// keep serving the clients with cached routes
+		if registered {
+			time.Sleep(config.DrainGrace)
+		}
+
+		// wait for requests and messages to finish
+		if config.DrainTimeout > 0 {
+			if !g.drain.Wait(config.DrainTimeout, g.wg) {
+				if logger.V(logger.WarnLevel, logger.DefaultLogger) {
+					logger.Warnf("Server drain timed out after %v", config.DrainTimeout)
+				}
+			}
+		} else if g.wg != nil {
 			g.wg.Wait()
 		}
 
+		// close the broker subscriptions
+		g.unsubscribe()
+
 		// stop the grpc server
 		exit := make(chan bool)
 
@@ -1004,7 +1103,8 @@ func (g *grpcServer) Start() error {
 		}
 	}()
 
-	// mark the server as started
+	// mark the server as started, handling new streams if previously drained
+	g.drain.Reset()
 	g.Lock()
 	g.started = true
 	g.Unlock()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	bmemory "github.com/micro/go-micro/v3/broker/memory"
 	"github.com/micro/go-micro/v3/client"
@@ -18,6 +19,8 @@ import (
 	pb "github.com/micro/go-micro/v3/server/grpc/proto"
 	tgrpc "github.com/micro/go-micro/v3/transport/grpc"
 	"google.golang.org/grpc"
+	hpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
@@ -65,6 +68,10 @@ func (s *testServer) Call(ctx context.Context, req *pb.Request, rsp *pb.Response
 		panic("handler panic")
 	}
 
+	if req.Name == "Slow" {
+		time.Sleep(300 * time.Millisecond)
+	}
+
 	rsp.Msg = "Hello " + req.Name
 	return nil
 }
@@ -301,3 +308,145 @@ func TestGRPCServerWithPanicHandler(t *testing.T) {
 		t.Fatal("this must return error, as handler should be panic")
 	}
 }
+
+func TestGRPCServerDrain(t *testing.T) {
+	r := rmemory.NewRegistry()
+	b := bmemory.NewBroker()
+	tr := tgrpc.NewTransport()
+
+	s := gsrv.NewServer(
+		server.Broker(b),
+		server.Name("foo"),
+		server.Registry(r),
+		server.Transport(tr),
+		server.DrainGrace(200*time.Millisecond),
+		server.DrainTimeout(time.Second),
+	)
+
+	h := &testServer{}
+	pb.RegisterTestHandler(s, h)
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	cc, err := grpc.Dial(s.Options().Address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	defer cc.Close()
+
+	call := func(name string) error {
+		return cc.Invoke(context.Background(), "/test.Test/Call", &pb.Request{Name: name}, &pb.Response{})
+	}
+
+	slow := make(chan error, 1)
+	go func() { slow <- call("Slow") }()
+	time.Sleep(20 * time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop() }()
+	time.Sleep(50 * time.Millisecond)
+
+	// the server should be deregistered but still serving
+	if services, err := r.GetService("foo"); err == nil && len(services) > 0 {
+		t.Errorf("Expected the server to be deregistered, got %v", services)
+	}
+	if err := call("John"); err != nil {
+		t.Errorf("Expected requests to be served during the grace period, got %v", err)
+	}
+
+	if err := <-slow; err != nil {
+		t.Errorf("Expected the in-flight request to finish, got %v", err)
+	}
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("failed to stop: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the server to stop")
+	}
+}
+
+// Health is a handler of the service which has the same name as the health service
+type Health struct{}
+
+func (h *Health) Check(ctx context.Context, req *pb.Request, rsp *pb.Response) error {
+	return nil
+}
+
+func TestGRPCServerHealth(t *testing.T) {
+	r := rmemory.NewRegistry()
+	b := bmemory.NewBroker()
+	tr := tgrpc.NewTransport()
+
+	s := gsrv.NewServer(
+		server.Broker(b),
+		server.Name("foo"),
+		server.Registry(r),
+		server.Transport(tr),
+		server.DrainGrace(200*time.Millisecond),
+	)
+
+	// the health service doesn't collide with the service's own handlers
+	if err := s.Handle(s.NewHandler(&Health{})); err != nil {
+		t.Fatalf("failed to handle: %v", err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	stopped := false
+	defer func() {
+		if !stopped {
+			s.Stop()
+		}
+	}()
+
+	cc, err := grpc.Dial(s.Options().Address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	defer cc.Close()
+
+	hc := hpb.NewHealthClient(cc)
+
+	rsp, err := hc.Check(context.TODO(), &hpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("error checking health: %v", err)
+	}
+	if rsp.Status != hpb.HealthCheckResponse_SERVING {
+		t.Errorf("Expected the server to be serving, got %v", rsp.Status)
+	}
+
+	s.Options().Health.SetServing("foo", false)
+
+	rsp, err = hc.Check(context.TODO(), &hpb.HealthCheckRequest{Service: "foo"})
+	if err != nil {
+		t.Fatalf("error checking health: %v", err)
+	}
+	if rsp.Status != hpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected the service not to be serving, got %v", rsp.Status)
+	}
+
+	if _, err := hc.Check(context.TODO(), &hpb.HealthCheckRequest{Service: "bar"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected an unknown service to be not found, got %v", err)
+	}
+
+	// the server isn't serving as soon as it starts draining
+	stopped = true
+	done := make(chan error, 1)
+	go func() { done <- s.Stop() }()
+	time.Sleep(50 * time.Millisecond)
+
+	rsp, err = hc.Check(context.TODO(), &hpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("error checking health: %v", err)
+	}
+	if rsp.Status != hpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected the server not to be serving whilst draining, got %v", rsp.Status)
+	}
+	<-done
+}
@@ -81,5 +81,9 @@ func newOptions(opt ...server.Option) server.Options {
 		o(&opts)
 	}
 
+	if opts.Health == nil {
+		opts.Health = server.NewHealth()
+	}
+
 	return opts
 }
@@ -242,8 +242,9 @@ func (g *grpcServer) createSubHandler(sb *subscriber, opts server.Options) broke
 				return nil
 			}
 
-			for i := len(opts.SubWrappers); i > 0; i-- {
-				fn = opts.SubWrappers[i-1](fn)
+			wrappers := g.subWrappers(sb, opts)
+			for i := len(wrappers); i > 0; i-- {
+				fn = wrappers[i-1](fn)
 			}
 
 			if g.wg != nil {
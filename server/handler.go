@@ -1,12 +1,17 @@
 package server
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type HandlerOption func(*HandlerOptions)
 
 type HandlerOptions struct {
 	Internal bool
 	Metadata map[string]map[string]string
+	// Limits of the individual endpoints
+	Limits map[string]Limit
 }
 
 type SubscriberOption func(*SubscriberOptions)
@@ -17,7 +22,17 @@ type SubscriberOptions struct {
 	AutoAck  bool
 	Queue    string
 	Internal bool
-	Context  context.Context
+	// Limit of the messages handled by the subscriber
+	Limit Limit
+	// MaxAttempts is the number of times a message is handled before it's
+	// moved to the dead letter topic, failed messages aren't retried if zero
+	MaxAttempts int
+	// Backoff between the attempts, util/backoff is used if nil
+	Backoff func(attempt int) time.Duration
+	// DeadLetter is the topic messages are published to once they've failed
+	// MaxAttempts times
+	DeadLetter string
+	Context    context.Context
 }
 
 // EndpointMetadata is a Handler option that allows metadata to be added to
@@ -28,6 +43,17 @@ func EndpointMetadata(name string, md map[string]string) HandlerOption {
 	}
 }
 
+// EndpointLimit is a Handler option that limits the requests to
+// individual endpoints.
+func EndpointLimit(name string, l Limit) HandlerOption {
+	return func(o *HandlerOptions) {
+		if o.Limits == nil {
+			o.Limits = make(map[string]Limit)
+		}
+		o.Limits[name] = l
+	}
+}
+
 // Internal Handler options specifies that a handler is not advertised
 // to the discovery system. In the future this may also limit request
 // to the internal network or authorised user.
@@ -78,3 +104,33 @@ func SubscriberContext(ctx context.Context) SubscriberOption {
 		o.Context = ctx
 	}
 }
+
+// SubscriberLimit limits the messages handled by the subscriber
+func SubscriberLimit(l Limit) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.Limit = l
+	}
+}
+
+// SubscriberMaxAttempts sets the number of times a message is handled before
+// it's moved to the dead letter topic or dropped
+func SubscriberMaxAttempts(n int) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.MaxAttempts = n
+	}
+}
+
+// SubscriberBackoff sets how long to wait before retrying a failed message
+func SubscriberBackoff(fn func(attempt int) time.Duration) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.Backoff = fn
+	}
+}
+
+// SubscriberDeadLetter sets the topic messages are published to once they've
+// failed the max attempts
+func SubscriberDeadLetter(topic string) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.DeadLetter = topic
+	}
+}
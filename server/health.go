@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// HealthCheck returns an error if the server isn't healthy
+type HealthCheck func(context.Context) error
+
+// Health of a server, reported by the health service the servers register. A server is
+// serving if all its checks pass. The status of the services it handles can also be set,
+// e.g. to stop serving traffic whilst warming up.
+type Health struct {
+	sync.RWMutex
+	checks  map[string]HealthCheck
+	serving map[string]bool
+}
+
+// NewHealth returns a Health which is serving with no checks
+func NewHealth() *Health {
+	return &Health{
+		checks:  make(map[string]HealthCheck),
+		serving: make(map[string]bool),
+	}
+}
+
+// AddCheck adds or replaces the check with the name
+func (h *Health) AddCheck(name string, fn HealthCheck) {
+	h.Lock()
+	defer h.Unlock()
+	h.checks[name] = fn
+}
+
+// SetServing sets whether the service is serving, a blank service is the whole server
+func (h *Health) SetServing(service string, serving bool) {
+	h.Lock()
+	defer h.Unlock()
+	h.serving[service] = serving
+}
+
+// Serving returns whether the service is serving and false for ok if its status was never set
+func (h *Health) Serving(service string) (serving bool, ok bool) {
+	h.RLock()
+	defer h.RUnlock()
+	serving, ok = h.serving[service]
+	return serving, ok
+}
+
+// Check runs the checks, returning the first error in the order of their names
+func (h *Health) Check(ctx context.Context) error {
+	h.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	checks := make(map[string]HealthCheck, len(h.checks))
+	for name, fn := range h.checks {
+		checks[name] = fn
+	}
+	h.RUnlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checks[name](ctx); err != nil {
+			return fmt.Errorf("%s check failed: %v", name, err)
+		}
+	}
+	return nil
+}
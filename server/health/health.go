@@ -0,0 +1,93 @@
+// Package health is the health service registered by the servers. It's compatible with the
+// gRPC health checking protocol, so it can be called at /grpc.health.v1.Health/Check as well
+// as MicroHealth.Check. The handler has a reserved name so it can't collide with a service's
+// own handlers.
+package health
+
+import (
+	"context"
+
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/server"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// Name of the health handler, its endpoints are Name.Check etc
+	Name = "MicroHealth"
+	// Service of the gRPC health checking protocol, which is routed to the health handler
+	Service = "grpc.health.v1.Health"
+)
+
+// MicroHealth is the health service of a server
+type MicroHealth struct {
+	server server.Server
+}
+
+// NewHealth returns the health service of the server
+func NewHealth(s server.Server) *MicroHealth {
+	return &MicroHealth{server: s}
+}
+
+// Register the health service with the server, its status includes the server's RegisterCheck
+func Register(s server.Server) error {
+	if h := s.Options().Health; h != nil {
+		h.AddCheck("register", func(ctx context.Context) error {
+			if fn := s.Options().RegisterCheck; fn != nil {
+				return fn(ctx)
+			}
+			return nil
+		})
+	}
+
+	return s.Handle(s.NewHandler(NewHealth(s), server.InternalHandler(true)))
+}
+
+// Ping the broker if it can check it's connected
+func Ping(b broker.Broker) error {
+	if p, ok := b.(broker.Pinger); ok {
+		return p.Ping()
+	}
+	return nil
+}
+
+// Check the health of the server, or a service it handles. The server's name and a blank
+// service are the whole server.
+func (h *MicroHealth) Check(ctx context.Context, req *pb.HealthCheckRequest, rsp *pb.HealthCheckResponse) error {
+	opts := h.server.Options()
+	if opts.Health == nil {
+		opts.Health = server.NewHealth()
+	}
+
+	serving, ok := opts.Health.Serving(req.Service)
+	if !ok {
+		if req.Service != "" && req.Service != opts.Name {
+			return errors.NotFound(opts.Name, "unknown service %v", req.Service)
+		}
+		serving = true
+	}
+
+	// the services aren't serving if the server isn't
+	if s, ok := opts.Health.Serving(""); ok && !s {
+		serving = false
+	}
+
+	if serving {
+		if err := opts.Health.Check(ctx); err != nil {
+			if logger.V(logger.WarnLevel, logger.DefaultLogger) {
+				logger.Warnf("Server %s-%s not serving: %v", opts.Name, opts.Id, err)
+			}
+			serving = false
+		}
+	}
+
+	if serving {
+		rsp.Status = pb.HealthCheckResponse_SERVING
+	} else {
+		rsp.Status = pb.HealthCheckResponse_NOT_SERVING
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	merrors "github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/server/mock"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestCheck(t *testing.T) {
+	failing := func(ctx context.Context) error { return errors.New("failing") }
+
+	tt := []struct {
+		Name    string
+		Service string
+		Options []server.Option
+		Serving map[string]bool
+		Status  pb.HealthCheckResponse_ServingStatus
+		Code    int32
+	}{
+		{
+			Name:   "Server",
+			Status: pb.HealthCheckResponse_SERVING,
+		},
+		{
+			Name:    "ServerName",
+			Service: "foo",
+			Status:  pb.HealthCheckResponse_SERVING,
+		},
+		{
+			Name:    "UnknownService",
+			Service: "bar",
+			Code:    404,
+		},
+		{
+			Name:    "FailingCheck",
+			Options: []server.Option{server.WithHealthCheck("db", failing)},
+			Status:  pb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			Name:    "FailingRegisterCheck",
+			Options: []server.Option{server.RegisterCheck(failing)},
+			Status:  pb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			Name:    "NotServing",
+			Service: "bar",
+			Serving: map[string]bool{"bar": false},
+			Status:  pb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			Name:    "Serving",
+			Service: "bar",
+			Serving: map[string]bool{"bar": true},
+			Status:  pb.HealthCheckResponse_SERVING,
+		},
+		{
+			Name:    "ServerNotServing",
+			Service: "bar",
+			Serving: map[string]bool{"": false, "bar": true},
+			Status:  pb.HealthCheckResponse_NOT_SERVING,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := append([]server.Option{server.Name("foo"), server.WithHealthCheck("ok", func(ctx context.Context) error { return nil })}, tc.Options...)
+			srv := mock.NewServer(opts...)
+			if err := Register(srv); err != nil {
+				t.Fatalf("Register returned %v error, expected nil", err)
+			}
+			for service, serving := range tc.Serving {
+				srv.Options().Health.SetServing(service, serving)
+			}
+
+			var rsp pb.HealthCheckResponse
+			err := NewHealth(srv).Check(context.TODO(), &pb.HealthCheckRequest{Service: tc.Service}, &rsp)
+			if err != nil || tc.Code != 0 {
+				if code := merrors.FromError(err).Code; err == nil || code != tc.Code {
+					t.Fatalf("Expected error code %v, got %v", tc.Code, err)
+				}
+			}
+			if rsp.Status != tc.Status {
+				t.Errorf("Expected status %v, got %v", tc.Status, rsp.Status)
+			}
+		})
+	}
+}
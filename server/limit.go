@@ -0,0 +1,16 @@
+package server
+
+// Limit of the requests or messages handled. A zero value means unlimited.
+type Limit struct {
+	// Rate is the number of requests allowed per second on average
+	Rate float64
+	// Burst is the number of requests allowed at once, defaults to the rate
+	Burst int
+	// Concurrency is the number of requests which can be in flight at once
+	Concurrency int
+}
+
+// Unlimited returns true if the limit doesn't restrict requests
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 && l.Concurrency <= 0
+}
@@ -36,6 +36,10 @@ func newOptions(opt ...server.Option) server.Options {
 		opts.RegisterCheck = server.DefaultRegisterCheck
 	}
 
+	if opts.Health == nil {
+		opts.Health = server.NewHealth()
+	}
+
 	if len(opts.Address) == 0 {
 		opts.Address = server.DefaultAddress
 	}
@@ -18,6 +18,7 @@ import (
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/micro/go-micro/v3/broker"
 	"github.com/micro/go-micro/v3/codec"
 	merrors "github.com/micro/go-micro/v3/errors"
 	"github.com/micro/go-micro/v3/server"
@@ -72,8 +73,8 @@ type router struct {
 
 	// handler wrappers
 	hdlrWrappers []server.HandlerWrapper
-	// subscriber wrappers
-	subWrappers []server.SubscriberWrapper
+	// subscriber wrappers of each subscriber
+	subWrappers func(server.Subscriber) []server.SubscriberWrapper
 
 	su          sync.RWMutex
 	subscribers map[string][]*subscriber
@@ -509,7 +510,34 @@ func (router *router) String() string {
 	return "mucp"
 }
 
-func (router *router) ProcessMessage(ctx context.Context, msg server.Message) (err error) {
+func (router *router) ProcessMessage(ctx context.Context, msg server.Message) error {
+	router.su.RLock()
+	// get the subscribers by topic, including those to wildcard topics matching it
+	var subs []*subscriber
+	for topic, s := range router.subscribers {
+		if broker.MatchTopic(topic, msg.Topic()) {
+			subs = append(subs, s...)
+		}
+	}
+	// unlock since we only need to get the subs
+	router.su.RUnlock()
+	if len(subs) == 0 {
+		return nil
+	}
+
+	return router.processMessage(ctx, msg, subs)
+}
+
+// processSubscriber processes the message with the handlers of the subscriber only
+func (router *router) processSubscriber(ctx context.Context, msg server.Message, sb server.Subscriber) error {
+	sub, ok := sb.(*subscriber)
+	if !ok {
+		return fmt.Errorf("invalid subscriber: expected *subscriber")
+	}
+	return router.processMessage(ctx, msg, []*subscriber{sub})
+}
+
+func (router *router) processMessage(ctx context.Context, msg server.Message, subs []*subscriber) (err error) {
 	defer func() {
 		// recover any panics
 		if r := recover(); r != nil {
@@ -519,15 +547,6 @@ func (router *router) ProcessMessage(ctx context.Context, msg server.Message) (e
 		}
 	}()
 
-	router.su.RLock()
-	// get the subscribers by topic
-	subs, ok := router.subscribers[msg.Topic()]
-	// unlock since we only need to get the subs
-	router.su.RUnlock()
-	if !ok {
-		return nil
-	}
-
 	var errResults []string
 
 	// we may have multiple subscribers for the topic
@@ -587,8 +606,11 @@ func (router *router) ProcessMessage(ctx context.Context, msg server.Message) (e
 			}
 
 			// wrap with subscriber wrappers
-			for i := len(router.subWrappers); i > 0; i-- {
-				fn = router.subWrappers[i-1](fn)
+			if router.subWrappers != nil {
+				wrappers := router.subWrappers(sub)
+				for i := len(wrappers); i > 0; i-- {
+					fn = wrappers[i-1](fn)
+				}
 			}
 
 			// create new rpc message
@@ -19,9 +19,11 @@ import (
 	"github.com/micro/go-micro/v3/metadata"
 	"github.com/micro/go-micro/v3/registry"
 	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/server/health"
 	"github.com/micro/go-micro/v3/transport"
 	"github.com/micro/go-micro/v3/util/addr"
 	"github.com/micro/go-micro/v3/util/backoff"
+	"github.com/micro/go-micro/v3/util/limit"
 	mnet "github.com/micro/go-micro/v3/util/net"
 	"github.com/micro/go-micro/v3/util/socket"
 )
@@ -42,6 +44,10 @@ type rpcServer struct {
 	subscriber broker.Subscriber
 	// graceful exit
 	wg *sync.WaitGroup
+	// enforces the rate and concurrency limits
+	limiter *limit.Limiter
+	// tracks the requests and messages being handled
+	drain *server.Drain
 
 	rsvc *registry.Service
 }
@@ -63,23 +69,83 @@ func wait(ctx context.Context) *sync.WaitGroup {
 
 func newServer(opts ...server.Option) server.Server {
 	options := newOptions(opts...)
-	router := newRpcRouter()
-	router.hdlrWrappers = options.HdlrWrappers
-	router.subWrappers = options.SubWrappers
 
-	return &rpcServer{
+	s := &rpcServer{
 		opts:        options,
-		router:      router,
 		handlers:    make(map[string]server.Handler),
 		subscribers: make(map[server.Subscriber][]broker.Subscriber),
 		exit:        make(chan chan error),
 		wg:          wait(options.Context),
+		limiter:     limit.NewLimiter(options),
+		drain:       server.NewDrain(),
 	}
+
+	s.router = newRpcRouter()
+	s.router.hdlrWrappers = s.hdlrWrappers()
+	s.router.subWrappers = s.subWrappers
+
+	// register the health service, the server isn't serving if the broker is disconnected
+	// or once it's stopping
+	s.opts.Health.AddCheck("broker", func(ctx context.Context) error {
+		return health.Ping(s.Options().Broker)
+	})
+	s.opts.Health.AddCheck("drain", s.drain.Check)
+	if err := health.Register(s); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			log.Errorf("Server %s-%s health service error: %v", options.Name, options.Id, err)
+		}
+	}
+
+	return s
+}
+
+// hdlrWrappers returns the handler wrappers, the requests are tracked so the server can be
+// drained, then expired requests are rejected and the limits enforced first
+func (s *rpcServer) hdlrWrappers() []server.HandlerWrapper {
+	return append([]server.HandlerWrapper{s.drain.HandlerWrapper, server.DeadlineWrapper, s.limiter.HandlerWrapper}, s.opts.HdlrWrappers...)
+}
+
+// subWrappers returns the subscriber wrappers, the messages are tracked so the server can be
+// drained and the limits of the subscriber enforced first. Without a subscriber, e.g. when
+// the messages are processed by a custom router, no limits are enforced.
+func (s *rpcServer) subWrappers(sb server.Subscriber) []server.SubscriberWrapper {
+	wrappers := []server.SubscriberWrapper{s.drain.SubscriberWrapper}
+	if sb != nil {
+		wrappers = append(wrappers, s.limiter.SubscriberWrapper(sb))
+	}
+	return append(wrappers, s.opts.SubWrappers...)
 }
 
 // HandleEvent handles inbound messages to the service directly
 // TODO: handle requests from an event. We won't send a response.
 func (s *rpcServer) HandleEvent(e broker.Event) error {
+	return s.handleEvent(e, nil)
+}
+
+// subscriberHandler returns the broker handler of the subscriber's messages, failed messages
+// are retried by the server so the policy holds whatever the broker. The retries are tracked
+// so the server waits for them when draining, and stop once it's stopping.
+func (s *rpcServer) subscriberHandler(sb server.Subscriber, b broker.Broker) broker.Handler {
+	handler := func(e broker.Event) error {
+		return s.handleEvent(e, sb)
+	}
+
+	return func(e broker.Event) error {
+		defer s.drain.Track()()
+
+		return broker.RetryHandler(b, handler, broker.SubscribeOptions{
+			AutoAck:     sb.Options().AutoAck,
+			MaxAttempts: sb.Options().MaxAttempts,
+			Backoff:     sb.Options().Backoff,
+			DeadLetter:  sb.Options().DeadLetter,
+			Context:     s.drain.Context(),
+		})(e)
+	}
+}
+
+// handleEvent handles the message with the subscriber's handlers, or those of all the
+// subscribers to the topic if there isn't one or the server has a custom router
+func (s *rpcServer) handleEvent(e broker.Event, sb server.Subscriber) error {
 	// formatting horrible cruft
 	msg := e.Message()
 
@@ -134,12 +200,15 @@ func (s *rpcServer) HandleEvent(e broker.Event) error {
 		handler := s.opts.Router.ProcessMessage
 
 		// execute the wrapper for it
-		for i := len(s.opts.SubWrappers); i > 0; i-- {
-			handler = s.opts.SubWrappers[i-1](handler)
+		wrappers := s.subWrappers(nil)
+		for i := len(wrappers); i > 0; i-- {
+			handler = wrappers[i-1](handler)
 		}
 
 		// set the router
 		r = rpcRouter{m: handler}
+	} else if sb != nil {
+		return s.router.processSubscriber(ctx, rpcMsg, sb)
 	}
 
 	return r.ProcessMessage(ctx, rpcMsg)
@@ -359,8 +428,9 @@ func (s *rpcServer) ServeConn(sock transport.Socket) {
 			}
 
 			// execute the wrapper for it
-			for i := len(s.opts.HdlrWrappers); i > 0; i-- {
-				handler = s.opts.HdlrWrappers[i-1](handler)
+			wrappers := s.hdlrWrappers()
+			for i := len(wrappers); i > 0; i-- {
+				handler = wrappers[i-1](handler)
 			}
 
 			// set the router
@@ -469,12 +539,14 @@ func (s *rpcServer) Init(opts ...server.Option) error {
 	for _, opt := range opts {
 		opt(&s.opts)
 	}
+	s.limiter.Init(s.opts)
+
 	// update router if its the default
 	if s.opts.Router == nil {
 		r := newRpcRouter()
-		r.hdlrWrappers = s.opts.HdlrWrappers
+		r.hdlrWrappers = s.hdlrWrappers()
 		r.serviceMap = s.router.serviceMap
-		r.subWrappers = s.opts.SubWrappers
+		r.subWrappers = s.subWrappers
 		s.router = r
 	}
 
@@ -495,6 +567,10 @@ func (s *rpcServer) Handle(h server.Handler) error {
 		return err
 	}
 
+	for name, l := range h.Options().Limits {
+		s.limiter.Set(name, l)
+	}
+
 	s.handlers[h.Name()] = h
 
 	return nil
@@ -710,7 +786,7 @@ func (s *rpcServer) Register() error {
 			opts = append(opts, broker.DisableAutoAck())
 		}
 
-		sub, err := config.Broker.Subscribe(sb.Topic(), s.HandleEvent, opts...)
+		sub, err := config.Broker.Subscribe(sb.Topic(), s.subscriberHandler(sb, config.Broker), opts...)
 		if err != nil {
 			return err
 		}
@@ -728,6 +804,15 @@ func (s *rpcServer) Register() error {
 }
 
 func (s *rpcServer) Deregister() error {
+	if err := s.deregister(); err != nil {
+		return err
+	}
+	s.unsubscribe()
+	return nil
+}
+
+// deregister the node without unsubscribing, so messages are still handled whilst draining
+func (s *rpcServer) deregister() error {
 	var err error
 	var advt, host, port string
 
@@ -789,13 +874,16 @@ func (s *rpcServer) Deregister() error {
 
 	s.Lock()
 	s.rsvc = nil
+	s.registered = false
+	s.Unlock()
 
-	if !s.registered {
-		s.Unlock()
-		return nil
-	}
+	return nil
+}
 
-	s.registered = false
+// unsubscribe closes the broker subscriptions
+func (s *rpcServer) unsubscribe() {
+	s.Lock()
+	defer s.Unlock()
 
 	// close the subscriber
 	if s.subscriber != nil {
@@ -806,15 +894,12 @@ func (s *rpcServer) Deregister() error {
 	for sb, subs := range s.subscribers {
 		for _, sub := range subs {
 			if logger.V(logger.InfoLevel, logger.DefaultLogger) {
-				log.Infof("Unsubscribing %s from topic: %s", node.Id, sub.Topic())
+				log.Infof("Unsubscribing %s-%s from topic: %s", s.opts.Name, s.opts.Id, sub.Topic())
 			}
 			sub.Unsubscribe()
 		}
 		s.subscribers[sb] = nil
 	}
-
-	s.Unlock()
-	return nil
 }
 
 func (s *rpcServer) Start() error {
@@ -951,27 +1036,42 @@ func (s *rpcServer) Start() error {
 			}
 		}
 
+		// start draining and report the server isn't serving so it's taken out of rotation
+		s.drain.Stop()
+
 		s.RLock()
 		registered := s.registered
 		s.RUnlock()
 		if registered {
-			// deregister self
-			if err := s.Deregister(); err != nil {
+			// deregister self, clients stop sending requests once they notice
+			if err := s.deregister(); err != nil {
 				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
 					log.Errorf("Server %s-%s deregister error: %s", config.Name, config.Id, err)
 				}
 			}
+
+			// keep serving the clients with cached routes
+			time.Sleep(config.DrainGrace)
 		}
 
 		s.Lock()
 		swg := s.wg
 		s.Unlock()
 
-		// wait for requests to finish
-		if swg != nil {
+		// wait for requests and messages to finish
+		if config.DrainTimeout > 0 {
+			if !s.drain.Wait(config.DrainTimeout, swg) {
+				if logger.V(logger.WarnLevel, logger.DefaultLogger) {
+					log.Warnf("Server %s-%s drain timed out after %v", config.Name, config.Id, config.DrainTimeout)
+				}
+			}
+		} else if swg != nil {
 			swg.Wait()
 		}
 
+		// close the broker subscriptions
+		s.unsubscribe()
+
 		// close transport listener
 		ch <- ts.Close()
 
@@ -991,7 +1091,8 @@ func (s *rpcServer) Start() error {
 		s.Unlock()
 	}()
 
-	// mark the server as started
+	// mark the server as started, handling new streams if previously drained
+	s.drain.Reset()
 	s.Lock()
 	s.started = true
 	s.Unlock()
@@ -0,0 +1,176 @@
+package mucp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/broker"
+	bmemory "github.com/micro/go-micro/v3/broker/memory"
+	rmemory "github.com/micro/go-micro/v3/registry/memory"
+	"github.com/micro/go-micro/v3/server"
+	tmemory "github.com/micro/go-micro/v3/transport/memory"
+)
+
+type TestMessage struct {
+	Name string
+}
+
+func testServer(t *testing.T, b broker.Broker) server.Server {
+	s := NewServer(
+		server.Name("foo"),
+		server.Broker(b),
+		server.Registry(rmemory.NewRegistry()),
+		server.Transport(tmemory.NewTransport()),
+	)
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	return s
+}
+
+func testPublish(t *testing.T, b broker.Broker, topic string) {
+	msg := &broker.Message{
+		Header: map[string]string{
+			"Content-Type": "application/json",
+			"Micro-Topic":  topic,
+		},
+		Body: []byte(`{"name":"john"}`),
+	}
+	if err := b.Publish(topic, msg); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+}
+
+func TestSubscriberRetry(t *testing.T) {
+	b := bmemory.NewBroker()
+	s := testServer(t, b)
+
+	// the failing subscriber is retried on its own
+	failed := make(chan bool, 10)
+	if err := s.Subscribe(s.NewSubscriber("test", func(ctx context.Context, msg *TestMessage) error {
+		failed <- true
+		return errors.New("failed")
+	}, server.SubscriberMaxAttempts(3), server.SubscriberDeadLetter("dead"), server.SubscriberBackoff(func(int) time.Duration {
+		return time.Millisecond
+	}))); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	handled := make(chan bool, 10)
+	if err := s.Subscribe(s.NewSubscriber("test", func(ctx context.Context, msg *TestMessage) error {
+		handled <- true
+		return nil
+	})); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	dead := make(chan *broker.Message, 1)
+	if _, err := b.Subscribe("dead", func(e broker.Event) error {
+		dead <- e.Message()
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Unexpected error starting %v", err)
+	}
+	defer s.Stop()
+
+	testPublish(t, b, "test")
+
+	select {
+	case m := <-dead:
+		if got := m.Header[broker.DeadLetterTopicHeader]; got != "test" {
+			t.Fatalf("Expected the dead letter of test, got %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a dead letter")
+	}
+
+	if len(failed) != 3 {
+		t.Errorf("Expected the failing subscriber to be called 3 times, got %d", len(failed))
+	}
+	if len(handled) != 1 {
+		t.Errorf("Expected the other subscriber to be called once, got %d", len(handled))
+	}
+}
+
+func TestSubscriberOverlapping(t *testing.T) {
+	b := bmemory.NewBroker()
+	s := testServer(t, b)
+
+	exact := make(chan bool, 10)
+	if err := s.Subscribe(s.NewSubscriber("orders.created", func(ctx context.Context, msg *TestMessage) error {
+		exact <- true
+		return nil
+	})); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	wildcard := make(chan bool, 10)
+	if err := s.Subscribe(s.NewSubscriber("orders.*", func(ctx context.Context, msg *TestMessage) error {
+		wildcard <- true
+		return nil
+	})); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Unexpected error starting %v", err)
+	}
+	defer s.Stop()
+
+	// the message is delivered to both broker subscriptions, each handles it once
+	testPublish(t, b, "orders.created")
+
+	if len(exact) != 1 {
+		t.Errorf("Expected the exact subscriber to be called once, got %d", len(exact))
+	}
+	if len(wildcard) != 1 {
+		t.Errorf("Expected the wildcard subscriber to be called once, got %d", len(wildcard))
+	}
+}
+
+func TestSubscriberRetryStop(t *testing.T) {
+	b := bmemory.NewBroker()
+	s := testServer(t, b)
+
+	attempts := make(chan bool, 10)
+	if err := s.Subscribe(s.NewSubscriber("test", func(ctx context.Context, msg *TestMessage) error {
+		attempts <- true
+		return errors.New("failed")
+	}, server.SubscriberMaxAttempts(3), server.SubscriberBackoff(func(int) time.Duration {
+		return time.Hour
+	}))); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Unexpected error starting %v", err)
+	}
+
+	// the publish returns the error once the retries stop
+	go b.Publish("test", &broker.Message{
+		Header: map[string]string{"Content-Type": "application/json", "Micro-Topic": "test"},
+		Body:   []byte(`{"name":"john"}`),
+	})
+	<-attempts
+
+	// the retries stop once the server is stopping, so it isn't held up by them
+	done := make(chan error, 1)
+	go func() { done <- s.Stop() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error stopping %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to stop")
+	}
+	if len(attempts) != 0 {
+		t.Fatalf("Expected no more attempts, got %d", len(attempts))
+	}
+}
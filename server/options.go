@@ -13,6 +13,7 @@ import (
 	"github.com/micro/go-micro/v3/debug/trace"
 	"github.com/micro/go-micro/v3/registry"
 	"github.com/micro/go-micro/v3/registry/mdns"
+	"github.com/micro/go-micro/v3/store"
 	"github.com/micro/go-micro/v3/transport"
 	thttp "github.com/micro/go-micro/v3/transport/http"
 )
@@ -41,9 +42,26 @@ type Options struct {
 	// The interval on which to register
 	RegisterInterval time.Duration
 
+	// DrainGrace is how long to keep serving requests after deregistering on stop,
+	// whilst clients with cached routes still send them
+	DrainGrace time.Duration
+	// DrainTimeout is how long to wait for requests and messages to be handled on stop,
+	// they aren't waited for if zero
+	DrainTimeout time.Duration
+
 	// The router for requests
 	Router Router
 
+	// Health of the server, reported by the health service
+	Health *Health
+
+	// Limit of all the requests and messages handled by the service
+	Limit Limit
+	// Limits of individual endpoints and subscriber topics
+	Limits map[string]Limit
+	// LimitStore enforces the limits across all the instances of the service
+	LimitStore store.Store
+
 	// TLSConfig specifies tls.Config for secure serving
 	TLSConfig *tls.Config
 
@@ -80,6 +98,10 @@ func newOptions(opt ...Option) Options {
 		opts.RegisterCheck = DefaultRegisterCheck
 	}
 
+	if opts.Health == nil {
+		opts.Health = NewHealth()
+	}
+
 	if len(opts.Address) == 0 {
 		opts.Address = DefaultAddress
 	}
@@ -220,6 +242,20 @@ func RegisterInterval(t time.Duration) Option {
 	}
 }
 
+// DrainGrace is how long to keep serving requests after deregistering on stop
+func DrainGrace(t time.Duration) Option {
+	return func(o *Options) {
+		o.DrainGrace = t
+	}
+}
+
+// DrainTimeout is how long to wait for requests and messages to be handled on stop
+func DrainTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.DrainTimeout = t
+	}
+}
+
 // TLSConfig specifies a *tls.Config
 func TLSConfig(t *tls.Config) Option {
 	return func(o *Options) {
@@ -247,6 +283,16 @@ func WithRouter(r Router) Option {
 	}
 }
 
+// WithHealthCheck adds a check to the health of the server, it's not serving if the check fails
+func WithHealthCheck(name string, fn HealthCheck) Option {
+	return func(o *Options) {
+		if o.Health == nil {
+			o.Health = NewHealth()
+		}
+		o.Health.AddCheck(name, fn)
+	}
+}
+
 // Wait tells the server to wait for requests to finish before exiting
 // If `wg` is nil, server only wait for completion of rpc handler.
 // For user need finer grained control, pass a concrete `wg` here, server will
@@ -263,6 +309,32 @@ func Wait(wg *sync.WaitGroup) Option {
 	}
 }
 
+// ServiceLimit limits all the requests and messages handled by the service
+func ServiceLimit(l Limit) Option {
+	return func(o *Options) {
+		o.Limit = l
+	}
+}
+
+// Limits the requests to an endpoint, e.g. Greeter.Hello, or the messages handled
+// by the subscribers to a topic, using the topic they subscribed to e.g. foo.*
+func Limits(name string, l Limit) Option {
+	return func(o *Options) {
+		if o.Limits == nil {
+			o.Limits = make(map[string]Limit)
+		}
+		o.Limits[name] = l
+	}
+}
+
+// LimitStore enforces the limits across all the instances of the service
+// by keeping the counts in the store rather than in memory
+func LimitStore(s store.Store) Option {
+	return func(o *Options) {
+		o.LimitStore = s
+	}
+}
+
 // Adds a handler Wrapper to a list of options passed into the server
 func WrapHandler(w HandlerWrapper) Option {
 	return func(o *Options) {
@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+
+	"github.com/micro/go-micro/v3/errors"
 )
 
 // HandlerFunc represents a single method of a handler. It's used primarily
@@ -25,3 +27,15 @@ type SubscriberWrapper func(SubscriberFunc) SubscriberFunc
 // is a convenient way to wrap a Stream as its in use for trace, monitoring,
 // metrics, etc.
 type StreamWrapper func(Stream) Stream
+
+// DeadlineWrapper is a HandlerWrapper which doesn't call the handler if the deadline
+// propagated with the request has passed, e.g. whilst it was queued or in transit,
+// since the client is no longer waiting for the response
+func DeadlineWrapper(h HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req Request, rsp interface{}) error {
+		if err := ctx.Err(); err != nil {
+			return errors.Timeout(req.Service(), "deadline exceeded before handling %v: %v", req.Endpoint(), err)
+		}
+		return h(ctx, req, rsp)
+	}
+}
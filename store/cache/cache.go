@@ -1,27 +1,252 @@
 package cache
 
 import (
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/logger"
 	"github.com/micro/go-micro/v3/store"
 	"github.com/micro/go-micro/v3/store/memory"
+	"github.com/micro/go-micro/v3/util/backoff"
 )
 
+// versionKey is the metadata key the version of the record in the backing
+// store is cached under, since the memory store versions records itself
+const versionKey = "Micro-Cache-Version"
+
+// DefaultExpiry is the longest a record is cached for, so a change the cache
+// misses, e.g. when a watch event is dropped, isn't served for longer
+var DefaultExpiry = time.Minute
+
 // cache store is a store with caching to reduce IO where applicable.
 // A memory store is used to cache reads from the given backing store.
-// Reads are read through, writes are write-through
+// Reads are read through, writes are write-through. If the backing store
+// can be watched cached records are invalidated when they change, records
+// expire after the DefaultExpiry either way.
+// The cache is Transactional and Watchable if the backing store is.
 type cache struct {
-	m       store.Store // the memory store
 	b       store.Store // the backing store, could be file, cockroach etc
 	options store.Options
+	// options of the memory store, used again when it's flushed
+	mopts []store.Option
+	exit  chan bool
+
+	mtx sync.RWMutex
+	m   store.Store // the memory store
+	w   store.Watcher
+	// incremented on every invalidation, the records read from the backing
+	// store before an invalidation may be stale so aren't cached
+	seq uint64
+	// nothing is cached whilst the backing store can't be watched
+	unwatched bool
 }
 
 // NewStore returns a new cache store
-func NewStore(store store.Store, opts ...store.Option) store.Store {
+func NewStore(s store.Store, opts ...store.Option) store.Store {
 	cf := &cache{
-		m: memory.NewStore(opts...),
-		b: store,
+		m:     memory.NewStore(opts...),
+		b:     s,
+		mopts: opts,
+		exit:  make(chan bool),
+	}
+	cf.watch()
+
+	// only expose the transactions and watches the backing store supports
+	_, tx := s.(store.Transactional)
+	_, wa := s.(store.Watchable)
+	switch {
+	case tx && wa:
+		return cf
+	case tx:
+		return struct {
+			store.Store
+			store.Transactional
+		}{cf, cf}
+	case wa:
+		return struct {
+			store.Store
+			store.Watchable
+		}{cf, cf}
+	default:
+		return struct{ store.Store }{cf}
+	}
+}
+
+// watch the backing store and invalidate any cached record which changes
+func (c *cache) watch() {
+	ws, ok := c.b.(store.Watchable)
+	if !ok {
+		return
 	}
-	return cf
 
+	w, err := ws.Watch()
+	if err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error watching %s store: %v", c.b.String(), err)
+		}
+		c.setWatcher(nil)
+	} else {
+		c.setWatcher(w)
+	}
+
+	go c.run(ws, w)
+}
+
+// run invalidates the cached records as they change. If the watcher fails the cache is
+// flushed, since changes may be missed, and the backing store is watched again.
+func (c *cache) run(ws store.Watchable, w store.Watcher) {
+	for attempts := 0; ; attempts++ {
+		if w != nil {
+			attempts = 0
+
+			for {
+				ev, err := w.Next()
+				if err != nil {
+					break
+				}
+				c.invalidate(ev.Database, ev.Table, ev.Key)
+			}
+
+			select {
+			case <-c.exit:
+				return
+			default:
+			}
+
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error watching %s store, flushing the cache", c.b.String())
+			}
+			c.setWatcher(nil)
+		}
+
+		select {
+		case <-c.exit:
+			return
+		case <-time.After(backoff.Do(attempts)):
+		}
+
+		var err error
+		if w, err = ws.Watch(); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error watching %s store: %v", c.b.String(), err)
+			}
+			continue
+		}
+		c.setWatcher(w)
+	}
+}
+
+// setWatcher sets the watcher of the backing store. Without one the cache is flushed and
+// nothing is cached until the backing store is watched again.
+func (c *cache) setWatcher(w store.Watcher) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.w = w
+	c.unwatched = w == nil
+	if c.unwatched {
+		c.flush()
+	}
+}
+
+// flush removes every cached record, the lock must be held
+func (c *cache) flush() {
+	c.seq++
+	c.m.Close()
+	c.m = memory.NewStore(c.mopts...)
+}
+
+// mem returns the memory store
+func (c *cache) mem() store.Store {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.m
+}
+
+// sequence returns the number of invalidations, which is checked before caching records
+func (c *cache) sequence() uint64 {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.seq
+}
+
+// invalidate removes the cached record, the records being read aren't cached since they
+// may have been read before it changed
+func (c *cache) invalidate(database, table, key string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.seq++
+	return c.m.Delete(key, store.DeleteFrom(database, table))
+}
+
+// names returns the database and table, falling back to those of the backing
+// store so cached records are kept in the same table the backing store reports
+func (c *cache) names(database, table string) (string, string) {
+	if len(database) == 0 {
+		database = c.b.Options().Database
+	}
+	if len(table) == 0 {
+		table = c.b.Options().Table
+	}
+	return database, table
+}
+
+// set caches the records read from the backing store, keeping their version. The records
+// were read once the cache was at the sequence, if anything has been invalidated since
+// they may be stale so they're removed from the cache instead. A cached record is only
+// replaced by a later version.
+func (c *cache) set(seq uint64, database, table string, recs ...*store.Record) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, r := range recs {
+		if seq != c.seq || c.unwatched {
+			if err := c.m.Delete(r.Key, store.DeleteFrom(database, table)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		cached, err := c.m.Read(r.Key, store.ReadFrom(database, table))
+		if err == nil && len(cached) > 0 {
+			if v, ok := cached[0].Metadata[versionKey].(uint64); ok && v > r.Version {
+				continue
+			}
+		}
+
+		rec := &store.Record{
+			Key:      r.Key,
+			Value:    r.Value,
+			Metadata: map[string]interface{}{versionKey: r.Version},
+			Expiry:   r.Expiry,
+		}
+		if DefaultExpiry > 0 && (rec.Expiry == 0 || rec.Expiry > DefaultExpiry) {
+			rec.Expiry = DefaultExpiry
+		}
+		for k, v := range r.Metadata {
+			rec.Metadata[k] = v
+		}
+		if err := c.m.Write(rec, store.WriteTo(database, table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// get returns the cached records with the version of the backing store
+func (c *cache) get(key, database, table string, opts ...store.ReadOption) ([]*store.Record, error) {
+	recs, err := c.mem().Read(key, append(opts, store.ReadFrom(database, table))...)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range recs {
+		if v, ok := r.Metadata[versionKey].(uint64); ok {
+			r.Version = v
+		}
+		delete(r.Metadata, versionKey)
+	}
+	return recs, nil
 }
 
 func (c *cache) init(opts ...store.Option) error {
@@ -36,9 +261,15 @@ func (c *cache) Init(opts ...store.Option) error {
 	if err := c.init(opts...); err != nil {
 		return err
 	}
-	if err := c.m.Init(opts...); err != nil {
+
+	c.mtx.Lock()
+	c.mopts = append(c.mopts, opts...)
+	err := c.m.Init(opts...)
+	c.mtx.Unlock()
+	if err != nil {
 		return err
 	}
+
 	return c.b.Init(opts...)
 }
 
@@ -49,74 +280,152 @@ func (c *cache) Options() store.Options {
 
 // Read takes a single key name and optional ReadOptions. It returns matching []*Record or an error.
 func (c *cache) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
-	recs, err := c.m.Read(key, opts...)
+	var options store.ReadOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	database, table := c.names(options.Database, options.Table)
+
+	// the cache may not hold the whole range so pages are read from the backing store
+	if len(options.Start) > 0 || len(options.End) > 0 || len(options.Cursor) > 0 || options.Next != nil {
+		return c.b.Read(key, opts...)
+	}
+
+	recs, err := c.get(key, database, table, opts...)
 	if err != nil && err != store.ErrNotFound {
 		return nil, err
 	}
 	if len(recs) > 0 {
 		return recs, nil
 	}
+	seq := c.sequence()
 	recs, err = c.b.Read(key, opts...)
 	if err == nil {
-		for _, rec := range recs {
-			if err := c.m.Write(rec); err != nil {
-				return nil, err
-			}
+		if err := c.set(seq, database, table, recs...); err != nil {
+			return nil, err
 		}
 	}
 	return recs, err
 }
 
 // Write() writes a record to the store, and returns an error if the record was not written.
-// If the write succeeds in writing to memory but fails to write through to file, you'll receive an error
-// but the value may still reside in memory so appropriate action should be taken.
+// The record is written to the backing store and then read back so the cached record has
+// the version the backing store gave it. If it can't be read back it is removed from the cache.
 func (c *cache) Write(r *store.Record, opts ...store.WriteOption) error {
-	if err := c.m.Write(r, opts...); err != nil {
+	var options store.WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	database, table := c.names(options.Database, options.Table)
+
+	if err := c.b.Write(r, opts...); err != nil {
 		return err
 	}
-	return c.b.Write(r, opts...)
+
+	seq := c.sequence()
+	recs, err := c.b.Read(r.Key, store.ReadFrom(options.Database, options.Table))
+	if err == nil {
+		err = c.set(seq, database, table, recs...)
+	}
+	if err != nil {
+		return c.invalidate(database, table, r.Key)
+	}
+	return nil
 }
 
-// Delete removes the record with the corresponding key from the store.
-// If the delete succeeds in writing to memory but fails to write through to file, you'll receive an error
-// but the value may still reside in memory so appropriate action should be taken.
+// Delete removes the record with the corresponding key from the backing store and then
+// the cache, so it isn't cached again by a concurrent read.
 func (c *cache) Delete(key string, opts ...store.DeleteOption) error {
-	if err := c.m.Delete(key, opts...); err != nil {
+	var options store.DeleteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	database, table := c.names(options.Database, options.Table)
+
+	if err := c.b.Delete(key, opts...); err != nil {
 		return err
 	}
-	return c.b.Delete(key, opts...)
+	return c.invalidate(database, table, key)
 }
 
 // List returns any keys that match, or an empty list with no error if none matched.
 func (c *cache) List(opts ...store.ListOption) ([]string, error) {
-	keys, err := c.m.List(opts...)
+	var options store.ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	database, table := c.names(options.Database, options.Table)
+
+	// the cache may not hold the whole range so pages are listed from the backing store
+	if len(options.Start) > 0 || len(options.End) > 0 || len(options.Cursor) > 0 || options.Next != nil {
+		return c.b.List(opts...)
+	}
+
+	keys, err := c.mem().List(append(opts, store.ListFrom(database, table))...)
 	if err != nil && err != store.ErrNotFound {
 		return nil, err
 	}
 	if len(keys) > 0 {
 		return keys, nil
 	}
+	seq := c.sequence()
 	keys, err = c.b.List(opts...)
 	if err == nil {
 		for _, key := range keys {
-			recs, err := c.b.Read(key)
+			recs, err := c.b.Read(key, store.ReadFrom(options.Database, options.Table))
 			if err != nil {
 				return nil, err
 			}
-			for _, r := range recs {
-				if err := c.m.Write(r); err != nil {
-					return nil, err
-				}
+			if err := c.set(seq, database, table, recs...); err != nil {
+				return nil, err
 			}
-
 		}
 	}
 	return keys, err
 }
 
+// Transact applies the operations to the backing store and removes the keys from the cache
+func (c *cache) Transact(ops []store.Operation, opts ...store.TransactOption) error {
+	var options store.TransactOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	database, table := c.names(options.Database, options.Table)
+
+	if err := c.b.(store.Transactional).Transact(ops, opts...); err != nil {
+		return err
+	}
+	for _, op := range ops {
+		key := op.Key
+		if op.Record != nil {
+			key = op.Record.Key
+		}
+		if err := c.invalidate(database, table, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watch returns a watcher for changes to the backing store
+func (c *cache) Watch(opts ...store.WatchOption) (store.Watcher, error) {
+	return c.b.(store.Watchable).Watch(opts...)
+}
+
 // Close the store and the underlying store
 func (c *cache) Close() error {
-	if err := c.m.Close(); err != nil {
+	c.mtx.Lock()
+	select {
+	case <-c.exit:
+	default:
+		close(c.exit)
+	}
+	if c.w != nil {
+		c.w.Stop()
+	}
+	err := c.m.Close()
+	c.mtx.Unlock()
+	if err != nil {
 		return err
 	}
 	return c.b.Close()
@@ -3,7 +3,9 @@ package cache
 import (
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/micro/go-micro/v3/store"
 	"github.com/micro/go-micro/v3/store/file"
@@ -100,3 +102,198 @@ func TestList(t *testing.T) {
 	assert.Len(t, keys, 2)
 
 }
+
+func TestInvalidate(t *testing.T) {
+	cf := NewStore(file.NewStore())
+	cf.Init()
+	cfInt := cf.(*cache)
+	defer cleanup(file.DefaultDatabase, cf)
+
+	cf.Write(&store.Record{
+		Key:   "key1",
+		Value: []byte("foo"),
+	})
+	recs, err := cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(recs[0].Value))
+
+	// write directly to the backing store, the cached record should be invalidated
+	cfInt.b.Write(&store.Record{
+		Key:   "key1",
+		Value: []byte("bar"),
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	recs, err = cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(recs[0].Value), "Expected the cached record to be invalidated")
+}
+
+func TestVersion(t *testing.T) {
+	cf := NewStore(file.NewStore())
+	cf.Init()
+	cfInt := cf.(*cache)
+	defer cleanup(file.DefaultDatabase, cf)
+
+	// write the record directly so the backing version is ahead of the cache
+	cfInt.b.Write(&store.Record{Key: "key1", Value: []byte("foo")})
+	cfInt.b.Write(&store.Record{Key: "key1", Value: []byte("foo")})
+	recs, err := cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), recs[0].Version)
+
+	// read it again from the cache
+	recs, err = cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), recs[0].Version)
+	assert.NotContains(t, recs[0].Metadata, versionKey)
+
+	// conditional writes use the version of the backing store
+	assert.NoError(t, cf.Write(&store.Record{Key: "key1", Value: []byte("bar")}, store.WriteIfVersion(2)))
+	recs, err = cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), recs[0].Version)
+	assert.Equal(t, "bar", string(recs[0].Value))
+}
+
+func TestTransact(t *testing.T) {
+	cf := NewStore(file.NewStore())
+	cf.Init()
+	defer cleanup(file.DefaultDatabase, cf)
+
+	cf.Write(&store.Record{Key: "key1", Value: []byte("foo")})
+	cf.Read("key1")
+
+	tx, ok := cf.(store.Transactional)
+	if !assert.True(t, ok, "Expected the cache to be transactional") {
+		return
+	}
+	assert.NoError(t, tx.Transact([]store.Operation{
+		store.WriteOp(&store.Record{Key: "key1", Value: []byte("bar")}).IfVersion(1),
+		store.WriteOp(&store.Record{Key: "key2", Value: []byte("baz")}),
+	}))
+
+	recs, err := cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(recs[0].Value), "Expected the cached record to be replaced")
+
+	// stores without transactions or watches don't get them from the cache
+	cm := NewStore(struct{ store.Store }{file.NewStore()})
+	defer cleanup(file.DefaultDatabase, cm)
+	_, ok = cm.(store.Transactional)
+	assert.False(t, ok)
+	_, ok = cm.(store.Watchable)
+	assert.False(t, ok)
+}
+
+func TestStaleRead(t *testing.T) {
+	cf := NewStore(file.NewStore())
+	cf.Init()
+	cfInt := cf.(*cache)
+	defer cleanup(file.DefaultDatabase, cf)
+
+	database, table := cfInt.names("", "")
+	cfInt.b.Write(&store.Record{Key: "key1", Value: []byte("foo")})
+
+	// the record is read whilst it's being written, the write invalidates it before
+	// the record read is cached
+	seq := cfInt.sequence()
+	recs, err := cfInt.b.Read("key1")
+	assert.NoError(t, err)
+	cfInt.b.Write(&store.Record{Key: "key1", Value: []byte("bar")})
+	assert.NoError(t, cfInt.invalidate(database, table, "key1"))
+	assert.NoError(t, cfInt.set(seq, database, table, recs...))
+
+	recs, err = cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(recs[0].Value), "Expected the stale record not to be cached")
+
+	// nor is a cached record replaced by an earlier version
+	assert.NoError(t, cfInt.set(cfInt.sequence(), database, table, &store.Record{Key: "key1", Value: []byte("foo"), Version: 1}))
+	recs, err = cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(recs[0].Value), "Expected the earlier version not to be cached")
+}
+
+func TestExpiry(t *testing.T) {
+	expiry := DefaultExpiry
+	DefaultExpiry = 50 * time.Millisecond
+	defer func() { DefaultExpiry = expiry }()
+
+	// the backing store can't be watched so changes are only read once the record expires
+	b := file.NewStore()
+	cf := NewStore(struct{ store.Store }{b})
+	cf.Init()
+	defer cleanup(file.DefaultDatabase, cf)
+
+	cf.Write(&store.Record{Key: "key1", Value: []byte("foo")})
+	b.Write(&store.Record{Key: "key1", Value: []byte("bar")})
+
+	recs, err := cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(recs[0].Value))
+
+	time.Sleep(2 * DefaultExpiry)
+	recs, err = cf.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(recs[0].Value), "Expected the cached record to expire")
+}
+
+type failingWatcher struct {
+	exit chan bool
+	once sync.Once
+}
+
+func (w *failingWatcher) Next() (*store.Event, error) {
+	<-w.exit
+	return nil, store.ErrWatcherStopped
+}
+
+func (w *failingWatcher) Stop() {
+	w.once.Do(func() { close(w.exit) })
+}
+
+// watchableStore returns the watchers it's asked for so they can be failed
+type watchableStore struct {
+	store.Store
+	watchers chan *failingWatcher
+}
+
+func (s *watchableStore) Transact(ops []store.Operation, opts ...store.TransactOption) error {
+	return s.Store.(store.Transactional).Transact(ops, opts...)
+}
+
+func (s *watchableStore) Watch(opts ...store.WatchOption) (store.Watcher, error) {
+	w := &failingWatcher{exit: make(chan bool)}
+	s.watchers <- w
+	return w, nil
+}
+
+func TestWatchFailure(t *testing.T) {
+	ws := &watchableStore{Store: file.NewStore(), watchers: make(chan *failingWatcher, 2)}
+	cf := NewStore(ws)
+	cf.Init()
+	cfInt := cf.(*cache)
+	defer cleanup(file.DefaultDatabase, cf)
+
+	cf.Write(&store.Record{Key: "key1", Value: []byte("foo")})
+	_, err := cfInt.mem().Read("key1")
+	assert.NoError(t, err, "Expected the record to be cached")
+
+	// the cache is flushed once the watcher fails, changes may be missed
+	(<-ws.watchers).Stop()
+	time.Sleep(10 * time.Millisecond)
+	_, err = cfInt.mem().Read("key1")
+	assert.Equal(t, store.ErrNotFound, err, "Expected the cache to be flushed")
+
+	// and records are cached again once the store is watched again
+	select {
+	case <-ws.watchers:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the store to be watched again")
+	}
+	time.Sleep(10 * time.Millisecond)
+	cf.Read("key1")
+	_, err = cfInt.mem().Read("key1")
+	assert.NoError(t, err, "Expected the record to be cached")
+}
@@ -4,6 +4,7 @@ package cockroach
 import (
 	"database/sql"
 	"fmt"
+	"math"
 	"net/url"
 	"regexp"
 	"strings"
@@ -27,12 +28,14 @@ var (
 	re = regexp.MustCompile("[^a-zA-Z0-9]+")
 
 	statements = map[string]string{
-		"list":       "SELECT key, value, metadata, expiry FROM %s.%s;",
-		"read":       "SELECT key, value, metadata, expiry FROM %s.%s WHERE key = $1;",
-		"readMany":   "SELECT key, value, metadata, expiry FROM %s.%s WHERE key LIKE $1;",
-		"readOffset": "SELECT key, value, metadata, expiry FROM %s.%s WHERE key LIKE $1 ORDER BY key DESC LIMIT $2 OFFSET $3;",
-		"write":      "INSERT INTO %s.%s(key, value, metadata, expiry) VALUES ($1, $2::bytea, $3, $4) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, metadata = EXCLUDED.metadata, expiry = EXCLUDED.expiry;",
-		"delete":     "DELETE FROM %s.%s WHERE key = $1;",
+		"list":          "SELECT key FROM %s.%s WHERE key LIKE $1 AND key LIKE $2 AND key >= $3 AND ($4 = '' OR key < $4) AND ($5 = '' OR key > $5) AND (expiry IS NULL OR expiry > now()) ORDER BY key ASC LIMIT $6 OFFSET $7;",
+		"read":          "SELECT key, value, metadata, expiry, version FROM %s.%s WHERE key = $1;",
+		"readMany":      "SELECT key, value, metadata, expiry, version FROM %s.%s WHERE key LIKE $1 AND key LIKE $2 AND key >= $3 AND ($4 = '' OR key < $4) AND ($5 = '' OR key > $5) AND (expiry IS NULL OR expiry > now()) ORDER BY key ASC LIMIT $6 OFFSET $7;",
+		"write":         "INSERT INTO %s.%s AS t (key, value, metadata, expiry, version) VALUES ($1, $2::bytea, $3, $4, 1) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, metadata = EXCLUDED.metadata, expiry = EXCLUDED.expiry, version = CASE WHEN t.expiry < now() THEN 1 ELSE t.version + 1 END;",
+		"writeNew":      "INSERT INTO %s.%s AS t (key, value, metadata, expiry, version) VALUES ($1, $2::bytea, $3, $4, 1) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, metadata = EXCLUDED.metadata, expiry = EXCLUDED.expiry, version = 1 WHERE t.expiry < now();",
+		"writeVersion":  "UPDATE %s.%s SET value = $2::bytea, metadata = $3, expiry = $4, version = version + 1 WHERE key = $1 AND version = $5 AND (expiry IS NULL OR expiry > now());",
+		"delete":        "DELETE FROM %s.%s WHERE key = $1;",
+		"deleteVersion": "DELETE FROM %s.%s WHERE key = $1 AND version = $2 AND (expiry IS NULL OR expiry > now());",
 	}
 )
 
@@ -110,12 +113,19 @@ func (s *sqlStore) initDB(database, table string) error {
 		value bytea,
 		metadata JSONB,
 		expiry timestamp with time zone,
+		version INT NOT NULL DEFAULT 1,
 		CONSTRAINT %s_pkey PRIMARY KEY (key)
 	);`, table, table))
 	if err != nil {
 		return errors.Wrap(err, "Couldn't create table")
 	}
 
+	// Add the version to tables created before records were versioned
+	_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`, table))
+	if err != nil {
+		return errors.Wrap(err, "Couldn't add version column")
+	}
+
 	// Create Index
 	_, err = s.db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s" ON %s.%s USING btree ("key");`, "key_index_"+table, database, table))
 	if err != nil {
@@ -214,13 +224,18 @@ func (s *sqlStore) List(opts ...store.ListOption) ([]string, error) {
 		return nil, err
 	}
 
+	args, err := rangeArgs(options)
+	if err != nil {
+		return nil, err
+	}
+
 	st, err := s.prepare(options.Database, options.Table, "list")
 	if err != nil {
 		return nil, err
 	}
 	defer st.Close()
 
-	rows, err := st.Query()
+	rows, err := st.Query(args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -230,31 +245,13 @@ func (s *sqlStore) List(opts ...store.ListOption) ([]string, error) {
 	defer rows.Close()
 
 	var keys []string
-	var timehelper pq.NullTime
 
 	for rows.Next() {
-		record := &store.Record{}
-		metadata := make(Metadata)
-
-		if err := rows.Scan(&record.Key, &record.Value, &metadata, &timehelper); err != nil {
+		var key string
+		if err := rows.Scan(&key); err != nil {
 			return keys, err
 		}
-
-		// set the metadata
-		record.Metadata = toMetadata(&metadata)
-
-		if timehelper.Valid {
-			if timehelper.Time.Before(time.Now()) {
-				// record has expired
-				go s.Delete(record.Key)
-			} else {
-				record.Expiry = time.Until(timehelper.Time)
-				keys = append(keys, record.Key)
-			}
-		} else {
-			keys = append(keys, record.Key)
-		}
-
+		keys = append(keys, key)
 	}
 	rowErr := rows.Close()
 	if rowErr != nil {
@@ -264,9 +261,57 @@ func (s *sqlStore) List(opts ...store.ListOption) ([]string, error) {
 	if err := rows.Err(); err != nil {
 		return keys, err
 	}
+
+	if options.Next != nil {
+		*options.Next = ""
+	}
+
+	// the extra key means there's another page
+	if more(options.Limit, len(keys)) {
+		keys = keys[:options.Limit]
+		if options.Next != nil {
+			*options.Next = store.NewCursor(keys[len(keys)-1])
+		}
+	}
+
 	return keys, nil
 }
 
+// escape the LIKE wildcards in the key
+var escaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// rangeArgs returns the arguments of a list query for the options. One more
+// than the limit is queried so we know whether there's a next page.
+func rangeArgs(options store.ListOptions) ([]interface{}, error) {
+	var after string
+	if len(options.Cursor) > 0 {
+		var err error
+		if after, err = store.ParseCursor(options.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	limit := uint64(math.MaxInt64)
+	if options.Limit != 0 {
+		limit = uint64(options.Limit) + 1
+	}
+
+	return []interface{}{
+		escaper.Replace(options.Prefix) + "%",
+		"%" + escaper.Replace(options.Suffix),
+		options.Start,
+		options.End,
+		after,
+		limit,
+		options.Offset,
+	}, nil
+}
+
+// more returns true if the query returned more than the limit
+func more(limit uint, n int) bool {
+	return limit != 0 && n > int(limit)
+}
+
 // Read a single key
 func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
 	var options store.ReadOptions
@@ -279,7 +324,7 @@ func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 		return nil, err
 	}
 
-	if options.Prefix || options.Suffix {
+	if options.Many() {
 		return s.read(key, options)
 	}
 
@@ -296,7 +341,7 @@ func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 	record := &store.Record{}
 	metadata := make(Metadata)
 
-	if err := row.Scan(&record.Key, &record.Value, &metadata, &timehelper); err != nil {
+	if err := row.Scan(&record.Key, &record.Value, &metadata, &timehelper, &record.Version); err != nil {
 		if err == sql.ErrNoRows {
 			return records, store.ErrNotFound
 		}
@@ -323,35 +368,33 @@ func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 
 // Read Many records
 func (s *sqlStore) read(key string, options store.ReadOptions) ([]*store.Record, error) {
-	pattern := "%"
+	listOptions := store.ListOptions{
+		Limit:  options.Limit,
+		Offset: options.Offset,
+		Start:  options.Start,
+		End:    options.End,
+		Cursor: options.Cursor,
+		Next:   options.Next,
+	}
 	if options.Prefix {
-		pattern = key + pattern
+		listOptions.Prefix = key
 	}
 	if options.Suffix {
-		pattern = pattern + key
+		listOptions.Suffix = key
 	}
 
-	var rows *sql.Rows
-	var st *sql.Stmt
-	var err error
-
-	if options.Limit != 0 {
-		st, err = s.prepare(options.Database, options.Table, "readOffset")
-		if err != nil {
-			return nil, err
-		}
-		defer st.Close()
-
-		rows, err = st.Query(pattern, options.Limit, options.Offset)
-	} else {
-		st, err = s.prepare(options.Database, options.Table, "readMany")
-		if err != nil {
-			return nil, err
-		}
-		defer st.Close()
+	args, err := rangeArgs(listOptions)
+	if err != nil {
+		return nil, err
+	}
 
-		rows, err = st.Query(pattern)
+	st, err := s.prepare(options.Database, options.Table, "readMany")
+	if err != nil {
+		return nil, err
 	}
+	defer st.Close()
+
+	rows, err := st.Query(args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return []*store.Record{}, nil
@@ -368,7 +411,7 @@ func (s *sqlStore) read(key string, options store.ReadOptions) ([]*store.Record,
 		record := &store.Record{}
 		metadata := make(Metadata)
 
-		if err := rows.Scan(&record.Key, &record.Value, &metadata, &timehelper); err != nil {
+		if err := rows.Scan(&record.Key, &record.Value, &metadata, &timehelper, &record.Version); err != nil {
 			return records, err
 		}
 
@@ -396,6 +439,18 @@ func (s *sqlStore) read(key string, options store.ReadOptions) ([]*store.Record,
 		return records, err
 	}
 
+	if options.Next != nil {
+		*options.Next = ""
+	}
+
+	// the extra record means there's another page
+	if more(options.Limit, len(records)) {
+		records = records[:options.Limit]
+		if options.Next != nil {
+			*options.Next = store.NewCursor(records[len(records)-1].Key)
+		}
+	}
+
 	return records, nil
 }
 
@@ -411,28 +466,89 @@ func (s *sqlStore) Write(r *store.Record, opts ...store.WriteOption) error {
 		return err
 	}
 
-	st, err := s.prepare(options.Database, options.Table, "write")
-	if err != nil {
-		return err
+	var expiry *time.Time
+	// expiry from options takes precedence, with the ttl over the expiry time
+	if options.TTL != 0 {
+		t := time.Now().Add(options.TTL)
+		expiry = &t
+	} else if !options.Expiry.IsZero() {
+		expiry = &options.Expiry
+	} else if r.Expiry != 0 {
+		t := time.Now().Add(r.Expiry)
+		expiry = &t
+	}
+
+	exec := func(query string, args ...interface{}) (sql.Result, error) {
+		st, err := s.prepare(options.Database, options.Table, query)
+		if err != nil {
+			return nil, err
+		}
+		defer st.Close()
+		return st.Exec(args...)
 	}
-	defer st.Close()
 
+	return s.put(exec, r, expiry, options.Conditional, options.Version)
+}
+
+// put writes the record with exec, checking the stored version if the write is conditional
+func (s *sqlStore) put(exec func(string, ...interface{}) (sql.Result, error), r *store.Record, expiry *time.Time, conditional bool, version uint64) error {
 	metadata := make(Metadata)
 	for k, v := range r.Metadata {
 		metadata[k] = v
 	}
 
-	var expiry time.Time
-	// expiry from options takes precedence
-	if !options.Expiry.IsZero() {
-		expiry = options.Expiry
-	} else if r.Expiry != 0 {
-		expiry = time.Now().Add(r.Expiry)
+	var result sql.Result
+	var err error
+
+	switch {
+	case !conditional:
+		result, err = exec("write", r.Key, r.Value, metadata, expiry)
+	case version == 0:
+		result, err = exec("writeNew", r.Key, r.Value, metadata, expiry)
+	default:
+		result, err = exec("writeVersion", r.Key, r.Value, metadata, expiry, version)
 	}
-	if _, err := st.Exec(r.Key, r.Value, metadata, expiry); err != nil {
+	if err != nil {
 		return errors.Wrap(err, "Couldn't insert record "+r.Key)
 	}
 
+	if !conditional {
+		return nil
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrVersionConflict
+	}
+
+	return nil
+}
+
+// remove deletes the record, if conditional only when it's at the version
+func (s *sqlStore) remove(exec func(string, ...interface{}) (sql.Result, error), key string, conditional bool, version uint64) error {
+	if !conditional {
+		if _, err := exec("delete", key); err != nil {
+			return errors.Wrap(err, "Couldn't delete record "+key)
+		}
+		return nil
+	}
+
+	result, err := exec("deleteVersion", key, version)
+	if err != nil {
+		return errors.Wrap(err, "Couldn't delete record "+key)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrVersionConflict
+	}
+
 	return nil
 }
 
@@ -467,6 +583,56 @@ func (s *sqlStore) Delete(key string, opts ...store.DeleteOption) error {
 	return nil
 }
 
+// Transact applies the operations within a single sql transaction
+func (s *sqlStore) Transact(ops []store.Operation, opts ...store.TransactOption) error {
+	var options store.TransactOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// create the db if not exists
+	if err := s.createDB(options.Database, options.Table); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	exec := func(query string, args ...interface{}) (sql.Result, error) {
+		st, err := s.prepare(options.Database, options.Table, query)
+		if err != nil {
+			return nil, err
+		}
+		defer st.Close()
+		return tx.Stmt(st).Exec(args...)
+	}
+
+	for _, op := range ops {
+		if op.Record == nil {
+			if err := s.remove(exec, op.Key, op.Conditional, op.Version); err != nil {
+				tx.Rollback()
+				return err
+			}
+			continue
+		}
+
+		var expiry *time.Time
+		if op.Record.Expiry != 0 {
+			t := time.Now().Add(op.Record.Expiry)
+			expiry = &t
+		}
+
+		if err := s.put(exec, op.Record, expiry, op.Conditional, op.Version); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (s *sqlStore) Options() store.Options {
 	return s.options
 }
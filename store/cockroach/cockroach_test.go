@@ -128,3 +128,27 @@ func TestSQL(t *testing.T) {
 		t.Fatal("Results should have returned 0 records")
 	}
 }
+
+func TestConformance(t *testing.T) {
+	if len(os.Getenv("IN_TRAVIS_CI")) != 0 {
+		t.Skip()
+	}
+
+	connection := "host=localhost port=26257 user=root sslmode=disable dbname=test"
+	db, err := sql.Open("postgres", connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Skip("store/cockroach: can't connect to db")
+	}
+	db.Close()
+
+	s := NewStore(
+		store.Database("conformance"),
+		store.Nodes(connection),
+	)
+	defer s.Close()
+
+	store.Tests(t, s)
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	// ErrInvalidCursor is returned when a read or list is continued from a cursor the store didn't return
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// NewCursor returns the cursor which continues a read or list after key.
+// It's for use by store implementations, callers should treat cursors as opaque.
+func NewCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// ParseCursor returns the key a cursor continues after
+func ParseCursor(c string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	return string(key), nil
+}
@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/micro/go-micro/v3/store"
@@ -28,7 +28,9 @@ var (
 
 // NewStore returns a file store
 func NewStore(opts ...store.Option) store.Store {
-	s := &fileStore{}
+	s := &fileStore{
+		watchers: make(map[string]*watcher),
+	}
 	s.init(opts...)
 	return s
 }
@@ -36,6 +38,9 @@ func NewStore(opts ...store.Option) store.Store {
 type fileStore struct {
 	options store.Options
 	dir     string
+
+	sync.RWMutex
+	watchers map[string]*watcher
 }
 
 type fileHandle struct {
@@ -49,20 +54,27 @@ type record struct {
 	Value     []byte
 	Metadata  map[string]interface{}
 	ExpiresAt time.Time
+	Version   uint64
 }
 
 func key(database, table string) string {
 	return database + ":" + table
 }
 
-func (m *fileStore) delete(db *bolt.DB, key string) error {
-	return db.Update(func(tx *bolt.Tx) error {
+// delete the key returning true if it existed
+func (m *fileStore) delete(db *bolt.DB, key string) (bool, error) {
+	var exists bool
+
+	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(dataBucket))
 		if b == nil {
 			return nil
 		}
+		exists = m.version(b, key) > 0
 		return b.Delete([]byte(key))
 	})
+
+	return exists, err
 }
 
 func (m *fileStore) init(opts ...store.Option) error {
@@ -89,13 +101,19 @@ func (m *fileStore) init(opts ...store.Option) error {
 	return nil
 }
 
-func (f *fileStore) getDB(database, table string) (*bolt.DB, error) {
+// names returns the database and table, falling back to the defaults
+func (f *fileStore) names(database, table string) (string, string) {
 	if len(database) == 0 {
 		database = f.options.Database
 	}
 	if len(table) == 0 {
 		table = f.options.Table
 	}
+	return database, table
+}
+
+func (f *fileStore) getDB(database, table string) (*bolt.DB, error) {
+	database, table = f.names(database, table)
 
 	// create a directory /tmp/micro
 	dir := filepath.Join(DefaultDir, database)
@@ -111,18 +129,54 @@ func (f *fileStore) getDB(database, table string) (*bolt.DB, error) {
 	return bolt.Open(dbPath, 0700, &bolt.Options{Timeout: 5 * time.Second})
 }
 
-func (m *fileStore) list(db *bolt.DB, limit, offset uint) []string {
-	var allItems []string
+// list returns the keys matching the options, bolt keeps them sorted
+func (m *fileStore) list(db *bolt.DB, o store.ListOptions) ([]string, error) {
+	var after string
+	if len(o.Cursor) > 0 {
+		var err error
+		if after, err = store.ParseCursor(o.Cursor); err != nil {
+			return nil, err
+		}
+	}
 
-	db.View(func(tx *bolt.Tx) error {
+	if o.Next != nil {
+		*o.Next = ""
+	}
+
+	// seek to the first key which can match
+	from := o.Prefix
+	if o.Start > from {
+		from = o.Start
+	}
+
+	offset := o.Offset
+	allKeys := []string{}
+
+	err := db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(dataBucket))
 		// nothing to read
 		if b == nil {
 			return nil
 		}
 
-		// @todo very inefficient
-		if err := b.ForEach(func(k, v []byte) error {
+		c := b.Cursor()
+
+		k, v := c.Seek([]byte(from))
+		if len(o.Cursor) > 0 && after >= from {
+			if k, v = c.Seek([]byte(after)); k != nil && string(k) == after {
+				k, v = c.Next()
+			}
+		}
+
+		for ; k != nil; k, v = c.Next() {
+			key := string(k)
+			if !strings.HasPrefix(key, o.Prefix) || (len(o.End) > 0 && key >= o.End) {
+				break
+			}
+			if !strings.HasSuffix(key, o.Suffix) {
+				continue
+			}
+
 			storedRecord := &record{}
 
 			if err := json.Unmarshal(v, storedRecord); err != nil {
@@ -131,38 +185,31 @@ func (m *fileStore) list(db *bolt.DB, limit, offset uint) []string {
 
 			if !storedRecord.ExpiresAt.IsZero() {
 				if storedRecord.ExpiresAt.Before(time.Now()) {
-					return nil
+					continue
 				}
 			}
 
-			allItems = append(allItems, string(k))
+			// skip the keys before the offset
+			if offset > 0 {
+				offset--
+				continue
+			}
 
-			return nil
-		}); err != nil {
-			return err
+			// there's more after the limit
+			if o.Limit != 0 && uint(len(allKeys)) == o.Limit {
+				if o.Next != nil {
+					*o.Next = store.NewCursor(allKeys[len(allKeys)-1])
+				}
+				break
+			}
+
+			allKeys = append(allKeys, key)
 		}
 
 		return nil
 	})
 
-	allKeys := make([]string, len(allItems))
-
-	for i, k := range allItems {
-		allKeys[i] = k
-	}
-
-	if limit != 0 || offset != 0 {
-		sort.Slice(allKeys, func(i, j int) bool { return allKeys[i] < allKeys[j] })
-		min := func(i, j uint) uint {
-			if i < j {
-				return i
-			}
-			return j
-		}
-		return allKeys[offset:min(limit, uint(len(allKeys)))]
-	}
-
-	return allKeys
+	return allKeys, err
 }
 
 func (m *fileStore) get(db *bolt.DB, k string) (*store.Record, error) {
@@ -205,16 +252,19 @@ func (m *fileStore) get(db *bolt.DB, k string) (*store.Record, error) {
 		newRecord.Expiry = time.Until(storedRecord.ExpiresAt)
 	}
 
+	newRecord.Version = storedRecord.Version
+
 	return newRecord, nil
 }
 
-func (m *fileStore) set(db *bolt.DB, r *store.Record) error {
-	// copy the incoming record and then
-	// convert the expiry in to a hard timestamp
+// encode the record for storage, converting the expiry in to a hard timestamp
+func (m *fileStore) encode(r *store.Record, version uint64) ([]byte, error) {
+	// copy the incoming record
 	item := &record{}
 	item.Key = r.Key
 	item.Value = r.Value
 	item.Metadata = make(map[string]interface{})
+	item.Version = version
 
 	if r.Expiry != 0 {
 		item.ExpiresAt = time.Now().Add(r.Expiry)
@@ -225,9 +275,49 @@ func (m *fileStore) set(db *bolt.DB, r *store.Record) error {
 	}
 
 	// marshal the data
-	data, _ := json.Marshal(item)
+	return json.Marshal(item)
+}
+
+// version returns the version of the stored record or 0 if it doesn't exist
+func (m *fileStore) version(b *bolt.Bucket, key string) uint64 {
+	value := b.Get([]byte(key))
+	if value == nil {
+		return 0
+	}
+
+	storedRecord := &record{}
+	if err := json.Unmarshal(value, storedRecord); err != nil {
+		return 0
+	}
+
+	if !storedRecord.ExpiresAt.IsZero() && storedRecord.ExpiresAt.Before(time.Now()) {
+		return 0
+	}
+
+	return storedRecord.Version
+}
+
+// put the record in the bucket, incrementing its version. If the write is
+// conditional the stored record must be at the given version. It returns
+// the version written.
+func (m *fileStore) put(b *bolt.Bucket, r *store.Record, conditional bool, version uint64) (uint64, error) {
+	current := m.version(b, r.Key)
+	if conditional && current != version {
+		return 0, store.ErrVersionConflict
+	}
+
+	data, err := m.encode(r, current+1)
+	if err != nil {
+		return 0, err
+	}
+
+	return current + 1, b.Put([]byte(r.Key), data)
+}
+
+func (m *fileStore) set(db *bolt.DB, r *store.Record, opts store.WriteOptions) (uint64, error) {
+	var version uint64
 
-	return db.Update(func(tx *bolt.Tx) error {
+	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(dataBucket))
 		if b == nil {
 			var err error
@@ -236,8 +326,12 @@ func (m *fileStore) set(db *bolt.DB, r *store.Record) error {
 				return err
 			}
 		}
-		return b.Put([]byte(r.Key), data)
+		var err error
+		version, err = m.put(b, r, opts.Conditional, opts.Version)
+		return err
 	})
+
+	return version, err
 }
 
 func (f *fileStore) Close() error {
@@ -260,7 +354,17 @@ func (m *fileStore) Delete(key string, opts ...store.DeleteOption) error {
 	}
 	defer db.Close()
 
-	return m.delete(db, key)
+	exists, err := m.delete(db, key)
+	if err != nil {
+		return err
+	}
+
+	// only send an event if there was something to delete
+	if exists {
+		m.sendEvent(deleteOptions.Database, deleteOptions.Table, deleteEvent(key))
+	}
+
+	return nil
 }
 
 func (m *fileStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
@@ -277,21 +381,25 @@ func (m *fileStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 
 	var keys []string
 
-	// Handle Prefix / suffix
-	// TODO: do range scan here rather than listing all keys
-	if readOpts.Prefix || readOpts.Suffix {
-		// list the keys
-		k := m.list(db, readOpts.Limit, readOpts.Offset)
+	// Handle Prefix / suffix and ranges
+	if readOpts.Many() {
+		listOpts := store.ListOptions{
+			Limit:  readOpts.Limit,
+			Offset: readOpts.Offset,
+			Start:  readOpts.Start,
+			End:    readOpts.End,
+			Cursor: readOpts.Cursor,
+			Next:   readOpts.Next,
+		}
+		if readOpts.Prefix {
+			listOpts.Prefix = key
+		}
+		if readOpts.Suffix {
+			listOpts.Suffix = key
+		}
 
-		// check for prefix and suffix
-		for _, v := range k {
-			if readOpts.Prefix && !strings.HasPrefix(v, key) {
-				continue
-			}
-			if readOpts.Suffix && !strings.HasSuffix(v, key) {
-				continue
-			}
-			keys = append(keys, v)
+		if keys, err = m.list(db, listOpts); err != nil {
+			return nil, err
 		}
 	} else {
 		keys = []string{key}
@@ -301,7 +409,10 @@ func (m *fileStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 
 	for _, k := range keys {
 		r, err := m.get(db, k)
-		if err != nil {
+		if err == store.ErrNotFound && readOpts.Many() {
+			// the record expired after it was listed
+			continue
+		} else if err != nil {
 			return results, err
 		}
 		results = append(results, r)
@@ -341,10 +452,17 @@ func (m *fileStore) Write(r *store.Record, opts ...store.WriteOption) error {
 			newRecord.Metadata[k] = v
 		}
 
-		return m.set(db, &newRecord)
+		r = &newRecord
+	}
+
+	version, err := m.set(db, r, writeOpts)
+	if err != nil {
+		return err
 	}
 
-	return m.set(db, r)
+	m.sendEvent(writeOpts.Database, writeOpts.Table, writeEvent(r, version))
+
+	return nil
 }
 
 func (m *fileStore) Options() store.Options {
@@ -364,32 +482,66 @@ func (m *fileStore) List(opts ...store.ListOption) ([]string, error) {
 	}
 	defer db.Close()
 
-	// TODO apply prefix/suffix in range query
-	allKeys := m.list(db, listOptions.Limit, listOptions.Offset)
+	return m.list(db, listOptions)
+}
 
-	if len(listOptions.Prefix) > 0 {
-		var prefixKeys []string
-		for _, k := range allKeys {
-			if strings.HasPrefix(k, listOptions.Prefix) {
-				prefixKeys = append(prefixKeys, k)
-			}
-		}
-		allKeys = prefixKeys
+func (m *fileStore) String() string {
+	return "file"
+}
+
+// Transact applies the operations within a single bolt transaction
+func (m *fileStore) Transact(ops []store.Operation, opts ...store.TransactOption) error {
+	var options store.TransactOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	db, err := m.getDB(options.Database, options.Table)
+	if err != nil {
+		return err
 	}
+	defer db.Close()
 
-	if len(listOptions.Suffix) > 0 {
-		var suffixKeys []string
-		for _, k := range allKeys {
-			if strings.HasSuffix(k, listOptions.Suffix) {
-				suffixKeys = append(suffixKeys, k)
+	var events []*store.Event
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(dataBucket))
+		if err != nil {
+			return err
+		}
+
+		for _, op := range ops {
+			if op.Record == nil {
+				current := m.version(b, op.Key)
+				if op.Conditional && (op.Version == 0 || current != op.Version) {
+					return store.ErrVersionConflict
+				}
+				if current > 0 {
+					events = append(events, deleteEvent(op.Key))
+				}
+				if err := b.Delete([]byte(op.Key)); err != nil {
+					return err
+				}
+				continue
 			}
+
+			version, err := m.put(b, op.Record, op.Conditional, op.Version)
+			if err != nil {
+				return err
+			}
+			events = append(events, writeEvent(op.Record, version))
 		}
-		allKeys = suffixKeys
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return allKeys, nil
-}
+	// send the events once the transaction is committed
+	for _, ev := range events {
+		m.sendEvent(options.Database, options.Table, ev)
+	}
 
-func (m *fileStore) String() string {
-	return "file"
+	return nil
 }
@@ -266,3 +266,9 @@ func fileTest(s store.Store, t *testing.T) {
 		}
 	}
 }
+
+func TestFileStoreConformance(t *testing.T) {
+	s := NewStore(store.Database("conformance"))
+	defer cleanup("conformance", s)
+	store.Tests(t, s)
+}
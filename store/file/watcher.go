@@ -0,0 +1,148 @@
+package file
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// how long to wait for a watcher to receive an event before dropping it
+	sendEventTime = 10 * time.Millisecond
+	// how many events are buffered for each watcher
+	watchBuffer = 64
+)
+
+type watcher struct {
+	id      string
+	options store.WatchOptions
+	events  chan *store.Event
+	exit    chan bool
+}
+
+func (w *watcher) Next() (*store.Event, error) {
+	select {
+	case ev := <-w.events:
+		return ev, nil
+	case <-w.exit:
+		return nil, store.ErrWatcherStopped
+	}
+}
+
+func (w *watcher) Stop() {
+	select {
+	case <-w.exit:
+		return
+	default:
+		close(w.exit)
+	}
+}
+
+// matches returns true if the watcher should receive the event
+func (w *watcher) matches(ev *store.Event) bool {
+	if len(w.options.Database) > 0 && w.options.Database != ev.Database {
+		return false
+	}
+	if len(w.options.Table) > 0 && w.options.Table != ev.Table {
+		return false
+	}
+	return strings.HasPrefix(ev.Key, w.options.Prefix)
+}
+
+// writeEvent returns the event for a write of the record at the version
+func writeEvent(r *store.Record, version uint64) *store.Event {
+	rec := &store.Record{
+		Key:      r.Key,
+		Value:    r.Value,
+		Metadata: make(map[string]interface{}),
+		Expiry:   r.Expiry,
+		Version:  version,
+	}
+	for k, v := range r.Metadata {
+		rec.Metadata[k] = v
+	}
+
+	ev := &store.Event{
+		Type:   store.Update,
+		Key:    r.Key,
+		Record: rec,
+	}
+	if version == 1 {
+		ev.Type = store.Create
+	}
+	return ev
+}
+
+// deleteEvent returns the event for a delete of the key
+func deleteEvent(key string) *store.Event {
+	return &store.Event{
+		Type: store.Delete,
+		Key:  key,
+	}
+}
+
+// Watch returns a watcher which streams changes made through this store.
+// Changes made by other processes sharing the files and records which
+// expire do not generate events.
+// Up to watchBuffer events are buffered for each watcher. Once the buffer is
+// full the store waits sendEventTime for the watcher to call Next and then
+// drops the event, so a slow watcher can't block writes.
+func (m *fileStore) Watch(opts ...store.WatchOption) (store.Watcher, error) {
+	var options store.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	w := &watcher{
+		id:      uuid.New().String(),
+		options: options,
+		events:  make(chan *store.Event, watchBuffer),
+		exit:    make(chan bool),
+	}
+
+	m.Lock()
+	m.watchers[w.id] = w
+	m.Unlock()
+
+	return w, nil
+}
+
+// sendEvent sends the event for the database and table to any watchers
+func (m *fileStore) sendEvent(database, table string, ev *store.Event) {
+	m.RLock()
+	watchers := make([]*watcher, 0, len(m.watchers))
+	for _, w := range m.watchers {
+		watchers = append(watchers, w)
+	}
+	m.RUnlock()
+
+	if len(watchers) == 0 {
+		return
+	}
+
+	ev.Database, ev.Table = m.names(database, table)
+	ev.Timestamp = time.Now()
+
+	for _, w := range watchers {
+		select {
+		case <-w.exit:
+			m.Lock()
+			delete(m.watchers, w.id)
+			m.Unlock()
+			continue
+		default:
+		}
+
+		if !w.matches(ev) {
+			continue
+		}
+
+		select {
+		case w.events <- ev:
+		case <-w.exit:
+		case <-time.After(sendEventTime):
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/micro/go-micro/v3/store"
@@ -19,7 +20,8 @@ func NewStore(opts ...store.Option) store.Store {
 			Database: "micro",
 			Table:    "micro",
 		},
-		store: cache.New(cache.NoExpiration, 5*time.Minute),
+		store:    cache.New(cache.NoExpiration, 5*time.Minute),
+		watchers: make(map[string]*watcher),
 	}
 	for _, o := range opts {
 		o(&s.options)
@@ -30,7 +32,10 @@ func NewStore(opts ...store.Option) store.Store {
 type memoryStore struct {
 	options store.Options
 
-	store *cache.Cache
+	// protects the store so transactions are applied atomically
+	sync.RWMutex
+	store    *cache.Cache
+	watchers map[string]*watcher
 }
 
 type storeRecord struct {
@@ -38,19 +43,27 @@ type storeRecord struct {
 	value     []byte
 	metadata  map[string]interface{}
 	expiresAt time.Time
+	version   uint64
 }
 
+// key joins the prefix and key without cleaning the key, which would change keys containing "//" or "./"
 func (m *memoryStore) key(prefix, key string) string {
-	return filepath.Join(prefix, key)
+	return prefix + "/" + key
 }
 
-func (m *memoryStore) prefix(database, table string) string {
+// names returns the database and table, falling back to the defaults
+func (m *memoryStore) names(database, table string) (string, string) {
 	if len(database) == 0 {
 		database = m.options.Database
 	}
 	if len(table) == 0 {
 		table = m.options.Table
 	}
+	return database, table
+}
+
+func (m *memoryStore) prefix(database, table string) string {
+	database, table = m.names(database, table)
 	return filepath.Join(database, table)
 }
 
@@ -87,9 +100,24 @@ func (m *memoryStore) get(prefix, key string) (*store.Record, error) {
 		newRecord.Metadata[k] = v
 	}
 
+	newRecord.Version = storedRecord.version
+
 	return newRecord, nil
 }
 
+// version returns the version of the stored record or 0 if it doesn't exist
+func (m *memoryStore) version(prefix, key string) uint64 {
+	r, found := m.store.Get(m.key(prefix, key))
+	if !found {
+		return 0
+	}
+	storedRecord, ok := r.(*storeRecord)
+	if !ok {
+		return 0
+	}
+	return storedRecord.version
+}
+
 func (m *memoryStore) set(prefix string, r *store.Record) {
 	key := m.key(prefix, r.Key)
 
@@ -113,39 +141,64 @@ func (m *memoryStore) set(prefix string, r *store.Record) {
 		i.metadata[k] = v
 	}
 
+	// increment the version
+	i.version = m.version(prefix, r.Key) + 1
+
 	m.store.Set(key, i, r.Expiry)
 }
 
 func (m *memoryStore) delete(prefix, key string) {
-	key = m.key(prefix, key)
-	m.store.Delete(key)
+	m.store.Delete(m.key(prefix, key))
 }
 
-func (m *memoryStore) list(prefix string, limit, offset uint) []string {
+// list returns the sorted keys in the table matching the options
+func (m *memoryStore) list(prefix string, o store.ListOptions) ([]string, error) {
+	var after string
+	if len(o.Cursor) > 0 {
+		var err error
+		if after, err = store.ParseCursor(o.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
 	allItems := m.store.Items()
-	allKeys := make([]string, len(allItems))
-	i := 0
+	allKeys := make([]string, 0, len(allItems))
 
 	for k := range allItems {
 		if !strings.HasPrefix(k, prefix+"/") {
 			continue
 		}
-		allKeys[i] = strings.TrimPrefix(k, prefix+"/")
-		i++
+		k = strings.TrimPrefix(k, prefix+"/")
+		if !strings.HasPrefix(k, o.Prefix) || !strings.HasSuffix(k, o.Suffix) {
+			continue
+		}
+		if k < o.Start || (len(o.End) > 0 && k >= o.End) || (len(o.Cursor) > 0 && k <= after) {
+			continue
+		}
+		allKeys = append(allKeys, k)
 	}
 
-	if limit != 0 || offset != 0 {
-		sort.Slice(allKeys, func(i, j int) bool { return allKeys[i] < allKeys[j] })
-		min := func(i, j uint) uint {
-			if i < j {
-				return i
-			}
-			return j
+	sort.Strings(allKeys)
+
+	return page(allKeys, o.Limit, o.Offset, o.Next), nil
+}
+
+// page returns the keys after the offset, up to the limit, setting next if there are more
+func page(keys []string, limit, offset uint, next *string) []string {
+	if next != nil {
+		*next = ""
+	}
+	if offset >= uint(len(keys)) {
+		return []string{}
+	}
+	keys = keys[offset:]
+	if limit != 0 && limit < uint(len(keys)) {
+		keys = keys[:limit]
+		if next != nil {
+			*next = store.NewCursor(keys[len(keys)-1])
 		}
-		return allKeys[offset:min(limit, uint(len(allKeys)))]
 	}
-
-	return allKeys
+	return keys
 }
 
 func (m *memoryStore) Close() error {
@@ -172,22 +225,31 @@ func (m *memoryStore) Read(key string, opts ...store.ReadOption) ([]*store.Recor
 
 	prefix := m.prefix(readOpts.Database, readOpts.Table)
 
-	var keys []string
-
-	// Handle Prefix / suffix
-	if readOpts.Prefix || readOpts.Suffix {
-		k := m.list(prefix, readOpts.Limit, readOpts.Offset)
+	m.RLock()
+	defer m.RUnlock()
 
-		for _, kk := range k {
-			if readOpts.Prefix && !strings.HasPrefix(kk, key) {
-				continue
-			}
+	var keys []string
 
-			if readOpts.Suffix && !strings.HasSuffix(kk, key) {
-				continue
-			}
+	// Handle Prefix / suffix and ranges
+	if readOpts.Many() {
+		listOpts := store.ListOptions{
+			Limit:  readOpts.Limit,
+			Offset: readOpts.Offset,
+			Start:  readOpts.Start,
+			End:    readOpts.End,
+			Cursor: readOpts.Cursor,
+			Next:   readOpts.Next,
+		}
+		if readOpts.Prefix {
+			listOpts.Prefix = key
+		}
+		if readOpts.Suffix {
+			listOpts.Suffix = key
+		}
 
-			keys = append(keys, kk)
+		var err error
+		if keys, err = m.list(prefix, listOpts); err != nil {
+			return nil, err
 		}
 	} else {
 		keys = []string{key}
@@ -197,7 +259,10 @@ func (m *memoryStore) Read(key string, opts ...store.ReadOption) ([]*store.Recor
 
 	for _, k := range keys {
 		r, err := m.get(prefix, k)
-		if err != nil {
+		if err == store.ErrNotFound && readOpts.Many() {
+			// the record expired after it was listed
+			continue
+		} else if err != nil {
 			return results, err
 		}
 		results = append(results, r)
@@ -214,6 +279,13 @@ func (m *memoryStore) Write(r *store.Record, opts ...store.WriteOption) error {
 
 	prefix := m.prefix(writeOpts.Database, writeOpts.Table)
 
+	m.Lock()
+	defer m.Unlock()
+
+	if writeOpts.Conditional && m.version(prefix, r.Key) != writeOpts.Version {
+		return store.ErrVersionConflict
+	}
+
 	if len(opts) > 0 {
 		// Copy the record before applying options, or the incoming record will be mutated
 		newRecord := store.Record{}
@@ -235,11 +307,13 @@ func (m *memoryStore) Write(r *store.Record, opts ...store.WriteOption) error {
 		}
 
 		m.set(prefix, &newRecord)
+		m.sendEvent(writeOpts.Database, writeOpts.Table, r.Key, false)
 		return nil
 	}
 
 	// set
 	m.set(prefix, r)
+	m.sendEvent(writeOpts.Database, writeOpts.Table, r.Key, false)
 
 	return nil
 }
@@ -251,7 +325,19 @@ func (m *memoryStore) Delete(key string, opts ...store.DeleteOption) error {
 	}
 
 	prefix := m.prefix(deleteOptions.Database, deleteOptions.Table)
+
+	m.Lock()
+	defer m.Unlock()
+
+	// only send an event if there was something to delete
+	exists := m.version(prefix, key) > 0
+
 	m.delete(prefix, key)
+
+	if exists {
+		m.sendEvent(deleteOptions.Database, deleteOptions.Table, key, true)
+	}
+
 	return nil
 }
 
@@ -267,27 +353,50 @@ func (m *memoryStore) List(opts ...store.ListOption) ([]string, error) {
 	}
 
 	prefix := m.prefix(listOptions.Database, listOptions.Table)
-	keys := m.list(prefix, listOptions.Limit, listOptions.Offset)
-
-	if len(listOptions.Prefix) > 0 {
-		var prefixKeys []string
-		for _, k := range keys {
-			if strings.HasPrefix(k, listOptions.Prefix) {
-				prefixKeys = append(prefixKeys, k)
-			}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.list(prefix, listOptions)
+}
+
+// Transact applies the operations under the store lock so readers never see them partially applied
+func (m *memoryStore) Transact(ops []store.Operation, opts ...store.TransactOption) error {
+	var options store.TransactOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	prefix := m.prefix(options.Database, options.Table)
+
+	m.Lock()
+	defer m.Unlock()
+
+	// check every condition before applying anything
+	for _, op := range ops {
+		if !op.Conditional {
+			continue
+		}
+		if op.Record != nil && m.version(prefix, op.Record.Key) != op.Version {
+			return store.ErrVersionConflict
+		}
+		if op.Record == nil && (op.Version == 0 || m.version(prefix, op.Key) != op.Version) {
+			return store.ErrVersionConflict
 		}
-		keys = prefixKeys
 	}
 
-	if len(listOptions.Suffix) > 0 {
-		var suffixKeys []string
-		for _, k := range keys {
-			if strings.HasSuffix(k, listOptions.Suffix) {
-				suffixKeys = append(suffixKeys, k)
-			}
+	for _, op := range ops {
+		if op.Record != nil {
+			m.set(prefix, op.Record)
+			m.sendEvent(options.Database, options.Table, op.Record.Key, false)
+			continue
+		}
+		exists := m.version(prefix, op.Key) > 0
+		m.delete(prefix, op.Key)
+		if exists {
+			m.sendEvent(options.Database, options.Table, op.Key, true)
 		}
-		keys = suffixKeys
 	}
 
-	return keys, nil
+	return nil
 }
@@ -276,3 +276,7 @@ func basictest(s store.Store, t *testing.T) {
 		}
 	}
 }
+
+func TestMemoryConformance(t *testing.T) {
+	store.Tests(t, NewStore())
+}
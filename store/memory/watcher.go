@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// how long to wait for a watcher to receive an event before dropping it
+	sendEventTime = 10 * time.Millisecond
+	// how many events are buffered for each watcher
+	watchBuffer = 64
+)
+
+type watcher struct {
+	id      string
+	options store.WatchOptions
+	events  chan *store.Event
+	exit    chan bool
+}
+
+func (w *watcher) Next() (*store.Event, error) {
+	select {
+	case ev := <-w.events:
+		return ev, nil
+	case <-w.exit:
+		return nil, store.ErrWatcherStopped
+	}
+}
+
+func (w *watcher) Stop() {
+	select {
+	case <-w.exit:
+		return
+	default:
+		close(w.exit)
+	}
+}
+
+// matches returns true if the watcher should receive the event
+func (w *watcher) matches(ev *store.Event) bool {
+	if len(w.options.Database) > 0 && w.options.Database != ev.Database {
+		return false
+	}
+	if len(w.options.Table) > 0 && w.options.Table != ev.Table {
+		return false
+	}
+	return strings.HasPrefix(ev.Key, w.options.Prefix)
+}
+
+// Watch returns a watcher which streams changes made through this store.
+// Records which expire do not generate events.
+// Up to watchBuffer events are buffered for each watcher. Once the buffer is
+// full the store waits sendEventTime for the watcher to call Next and then
+// drops the event, so a slow watcher can't block writes.
+func (m *memoryStore) Watch(opts ...store.WatchOption) (store.Watcher, error) {
+	var options store.WatchOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	w := &watcher{
+		id:      uuid.New().String(),
+		options: options,
+		events:  make(chan *store.Event, watchBuffer),
+		exit:    make(chan bool),
+	}
+
+	m.Lock()
+	m.watchers[w.id] = w
+	m.Unlock()
+
+	return w, nil
+}
+
+// sendEvent sends the change to the key to any watchers. The store lock must be held.
+func (m *memoryStore) sendEvent(database, table, key string, deleted bool) {
+	if len(m.watchers) == 0 {
+		return
+	}
+
+	database, table = m.names(database, table)
+
+	ev := &store.Event{
+		Type:      store.Delete,
+		Database:  database,
+		Table:     table,
+		Key:       key,
+		Timestamp: time.Now(),
+	}
+
+	if !deleted {
+		r, err := m.get(m.prefix(database, table), key)
+		if err != nil {
+			return
+		}
+		ev.Record = r
+		ev.Type = store.Update
+		if r.Version == 1 {
+			ev.Type = store.Create
+		}
+	}
+
+	for id, w := range m.watchers {
+		select {
+		case <-w.exit:
+			delete(m.watchers, id)
+			continue
+		default:
+		}
+
+		if !w.matches(ev) {
+			continue
+		}
+
+		select {
+		case w.events <- ev:
+		case <-w.exit:
+		case <-time.After(sendEventTime):
+		}
+	}
+}
@@ -63,6 +63,19 @@ type ReadOptions struct {
 	Limit uint
 	// Offset when combined with Limit supports pagination
 	Offset uint
+	// Start and End return the records with keys in the range [Start, End).
+	// An empty End reads to the end of the table.
+	Start, End string
+	// Cursor continues a previous read after the last record it returned
+	Cursor string
+	// Next is set to the cursor to continue the read from,
+	// or empty if there are no more records
+	Next *string
+}
+
+// Many returns true if the options read more than a single key
+func (r ReadOptions) Many() bool {
+	return r.Prefix || r.Suffix || len(r.Start) > 0 || len(r.End) > 0 || len(r.Cursor) > 0
 }
 
 // ReadOption sets values in ReadOptions
@@ -104,6 +117,29 @@ func ReadOffset(o uint) ReadOption {
 	}
 }
 
+// ReadRange returns the records with keys from start up to but not including end
+func ReadRange(start, end string) ReadOption {
+	return func(r *ReadOptions) {
+		r.Start = start
+		r.End = end
+	}
+}
+
+// ReadCursor continues a previous read from the cursor it returned
+func ReadCursor(c string) ReadOption {
+	return func(r *ReadOptions) {
+		r.Cursor = c
+	}
+}
+
+// ReadNext sets next to the cursor to continue the read from. It's empty
+// once there are no more records. Use in conjunction with Limit for pagination
+func ReadNext(next *string) ReadOption {
+	return func(r *ReadOptions) {
+		r.Next = next
+	}
+}
+
 // WriteOptions configures an individual Write operation
 // If Expiry and TTL are set TTL takes precedence
 type WriteOptions struct {
@@ -112,6 +148,11 @@ type WriteOptions struct {
 	Expiry time.Time
 	// TTL is the time until the record expires
 	TTL time.Duration
+	// Conditional writes only succeed if the stored record is at Version
+	Conditional bool
+	// Version of the stored record for a conditional write.
+	// A version of 0 means the record must not exist.
+	Version uint64
 }
 
 // WriteOption sets values in WriteOptions
@@ -139,6 +180,15 @@ func WriteTTL(d time.Duration) WriteOption {
 	}
 }
 
+// WriteIfVersion only writes the record if the stored record is at version v,
+// otherwise ErrVersionConflict is returned. Use 0 to write only if the record doesn't exist.
+func WriteIfVersion(v uint64) WriteOption {
+	return func(w *WriteOptions) {
+		w.Conditional = true
+		w.Version = v
+	}
+}
+
 // DeleteOptions configures an individual Delete operation
 type DeleteOptions struct {
 	Database, Table string
@@ -167,6 +217,14 @@ type ListOptions struct {
 	Limit uint
 	// Offset when combined with Limit supports pagination
 	Offset uint
+	// Start and End return the keys in the range [Start, End).
+	// An empty End lists to the end of the table.
+	Start, End string
+	// Cursor continues a previous list after the last key it returned
+	Cursor string
+	// Next is set to the cursor to continue the list from,
+	// or empty if there are no more keys
+	Next *string
 }
 
 // ListOption sets values in ListOptions
@@ -207,3 +265,68 @@ func ListOffset(o uint) ListOption {
 		l.Offset = o
 	}
 }
+
+// ListRange returns the keys from start up to but not including end
+func ListRange(start, end string) ListOption {
+	return func(l *ListOptions) {
+		l.Start = start
+		l.End = end
+	}
+}
+
+// ListCursor continues a previous list from the cursor it returned
+func ListCursor(c string) ListOption {
+	return func(l *ListOptions) {
+		l.Cursor = c
+	}
+}
+
+// ListNext sets next to the cursor to continue the list from. It's empty
+// once there are no more keys. Use in conjunction with Limit for pagination
+func ListNext(next *string) ListOption {
+	return func(l *ListOptions) {
+		l.Next = next
+	}
+}
+
+// TransactOptions configures an individual Transact operation
+type TransactOptions struct {
+	Database, Table string
+}
+
+// TransactOption sets values in TransactOptions
+type TransactOption func(t *TransactOptions)
+
+// TransactIn the database and table
+func TransactIn(database, table string) TransactOption {
+	return func(t *TransactOptions) {
+		t.Database = database
+		t.Table = table
+	}
+}
+
+// WatchOptions configures a Watch operation
+type WatchOptions struct {
+	// Database and Table to watch, all are watched if unset
+	Database, Table string
+	// Prefix only returns events for keys with the prefix
+	Prefix string
+}
+
+// WatchOption sets values in WatchOptions
+type WatchOption func(w *WatchOptions)
+
+// WatchFrom the database and table
+func WatchFrom(database, table string) WatchOption {
+	return func(w *WatchOptions) {
+		w.Database = database
+		w.Table = table
+	}
+}
+
+// WatchPrefix only returns events for keys that are prefixed with p
+func WatchPrefix(p string) WatchOption {
+	return func(w *WatchOptions) {
+		w.Prefix = p
+	}
+}
@@ -0,0 +1,639 @@
+// Package sqlite implements the sqlite store
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/micro/go-micro/v3/store"
+	"github.com/pkg/errors"
+)
+
+var (
+	// DefaultDatabase is the database that the sqlite store
+	// will use if no database is provided.
+	DefaultDatabase = "micro"
+	// DefaultTable when none is specified
+	DefaultTable = "micro"
+	// DefaultDir is the default directory for sqlite files
+	DefaultDir = filepath.Join(os.TempDir(), "micro", "sqlite")
+)
+
+var (
+	re = regexp.MustCompile("[^a-zA-Z0-9]+")
+
+	// the like pattern escaper
+	escaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+	statements = map[string]string{
+		"list":          "SELECT key FROM %s WHERE key LIKE ?1 ESCAPE '\\' AND key LIKE ?2 ESCAPE '\\' AND key >= ?3 AND (?4 = '' OR key < ?4) AND (?5 = '' OR key > ?5) AND (expiry IS NULL OR expiry > ?6) ORDER BY key ASC LIMIT ?7 OFFSET ?8;",
+		"read":          "SELECT key, value, metadata, expiry, version FROM %s WHERE key = ?1 AND (expiry IS NULL OR expiry > ?2);",
+		"readMany":      "SELECT key, value, metadata, expiry, version FROM %s WHERE key LIKE ?1 ESCAPE '\\' AND key LIKE ?2 ESCAPE '\\' AND key >= ?3 AND (?4 = '' OR key < ?4) AND (?5 = '' OR key > ?5) AND (expiry IS NULL OR expiry > ?6) ORDER BY key ASC LIMIT ?7 OFFSET ?8;",
+		"write":         "INSERT INTO %s (key, value, metadata, expiry, version) VALUES (?1, ?2, ?3, ?4, 1) ON CONFLICT (key) DO UPDATE SET value = excluded.value, metadata = excluded.metadata, expiry = excluded.expiry, version = CASE WHEN expiry IS NOT NULL AND expiry <= ?5 THEN 1 ELSE version + 1 END;",
+		"writeNew":      "INSERT INTO %s (key, value, metadata, expiry, version) VALUES (?1, ?2, ?3, ?4, 1) ON CONFLICT (key) DO UPDATE SET value = excluded.value, metadata = excluded.metadata, expiry = excluded.expiry, version = 1 WHERE expiry IS NOT NULL AND expiry <= ?5;",
+		"writeVersion":  "UPDATE %s SET value = ?2, metadata = ?3, expiry = ?4, version = version + 1 WHERE key = ?1 AND (expiry IS NULL OR expiry > ?5) AND version = ?6;",
+		"delete":        "DELETE FROM %s WHERE key = ?1;",
+		"deleteVersion": "DELETE FROM %s WHERE key = ?1 AND (expiry IS NULL OR expiry > ?2) AND version = ?3;",
+		"expire":        "DELETE FROM %s WHERE expiry IS NOT NULL AND expiry <= ?1;",
+	}
+)
+
+type sqlStore struct {
+	options store.Options
+
+	sync.RWMutex
+	// open database handles
+	dbs map[string]*sql.DB
+	// known tables
+	tables map[string]bool
+}
+
+// getDB returns the sanitised database and table names
+func (s *sqlStore) getDB(database, table string) (string, string) {
+	if len(database) == 0 {
+		if len(s.options.Database) > 0 {
+			database = s.options.Database
+		} else {
+			database = DefaultDatabase
+		}
+	}
+
+	if len(table) == 0 {
+		if len(s.options.Table) > 0 {
+			table = s.options.Table
+		} else {
+			table = DefaultTable
+		}
+	}
+
+	// database and table must only contain letters, numbers and underscores
+	database = re.ReplaceAllString(database, "_")
+	table = re.ReplaceAllString(table, "_")
+
+	return database, table
+}
+
+// dir returns the directory the database files are kept in
+func (s *sqlStore) dir() string {
+	if len(s.options.Nodes) > 0 {
+		return s.options.Nodes[0]
+	}
+	return DefaultDir
+}
+
+// createDB opens the database file and creates the table if not exists
+func (s *sqlStore) createDB(database, table string) (*sql.DB, error) {
+	database, table = s.getDB(database, table)
+
+	s.RLock()
+	db, ok := s.dbs[database]
+	created := s.tables[database+":"+table]
+	s.RUnlock()
+
+	if ok && created {
+		return db, nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	db, ok = s.dbs[database]
+	if !ok {
+		var err error
+		if db, err = s.openDB(database); err != nil {
+			return nil, err
+		}
+		s.dbs[database] = db
+	}
+
+	if s.tables[database+":"+table] {
+		return db, nil
+	}
+
+	if err := s.initDB(db, table); err != nil {
+		return nil, err
+	}
+
+	s.tables[database+":"+table] = true
+	return db, nil
+}
+
+func (s *sqlStore) openDB(database string) (*sql.DB, error) {
+	dir := s.dir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	// like is case sensitive so prefix and suffix reads match the other stores
+	source := fmt.Sprintf("file:%s?_cslike=1&_busy_timeout=5000", filepath.Join(dir, database+".db"))
+
+	db, err := sql.Open("sqlite3", source)
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite only allows a single writer
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (s *sqlStore) initDB(db *sql.DB, table string) error {
+	// Create a table for the namespace's prefix
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s
+	(
+		key TEXT NOT NULL PRIMARY KEY,
+		value BLOB,
+		metadata TEXT,
+		expiry INTEGER,
+		version INTEGER NOT NULL DEFAULT 1
+	);`, table))
+	if err != nil {
+		return errors.Wrap(err, "Couldn't create table")
+	}
+
+	// Create Expiry Index
+	_, err = db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS expiry_index_%s ON %s (expiry);`, table, table))
+	if err != nil {
+		return err
+	}
+
+	// Remove anything which expired while the store was closed
+	_, err = db.Exec(fmt.Sprintf(statements["expire"], table), time.Now().UnixNano())
+	return err
+}
+
+// statement returns the query for the table
+func (s *sqlStore) statement(database, table, query string) (string, error) {
+	st, ok := statements[query]
+	if !ok {
+		return "", errors.New("unsupported statement")
+	}
+
+	// get the table
+	_, table = s.getDB(database, table)
+
+	return fmt.Sprintf(st, table), nil
+}
+
+func (s *sqlStore) prepare(database, table, query string) (*sql.Stmt, error) {
+	q, err := s.statement(database, table, query)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := s.createDB(database, table)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := db.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (s *sqlStore) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	for name, db := range s.dbs {
+		if err := db.Close(); err != nil {
+			return err
+		}
+		delete(s.dbs, name)
+	}
+
+	s.tables = make(map[string]bool)
+	return nil
+}
+
+func (s *sqlStore) Init(opts ...store.Option) error {
+	for _, o := range opts {
+		o(&s.options)
+	}
+
+	// close the handles so they're reopened with the new options
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	_, err := s.createDB(s.options.Database, s.options.Table)
+	return err
+}
+
+// List all the known records
+func (s *sqlStore) List(opts ...store.ListOption) ([]string, error) {
+	var options store.ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	st, err := s.prepare(options.Database, options.Table, "list")
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	args, err := rangeArgs(options)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := st.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return keys, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return keys, err
+	}
+
+	if options.Next != nil {
+		*options.Next = ""
+	}
+
+	// the extra key means there's another page
+	if more(options.Limit, len(keys)) {
+		keys = keys[:options.Limit]
+		if options.Next != nil {
+			*options.Next = store.NewCursor(keys[len(keys)-1])
+		}
+	}
+
+	return keys, nil
+}
+
+// Read a single key
+func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
+	var options store.ReadOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Many() {
+		return s.read(key, options)
+	}
+
+	st, err := s.prepare(options.Database, options.Table, "read")
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	record, err := scan(st.QueryRow(key, time.Now().UnixNano()))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return []*store.Record{record}, nil
+}
+
+// Read Many records
+func (s *sqlStore) read(key string, options store.ReadOptions) ([]*store.Record, error) {
+	listOptions := store.ListOptions{
+		Limit:  options.Limit,
+		Offset: options.Offset,
+		Start:  options.Start,
+		End:    options.End,
+		Cursor: options.Cursor,
+		Next:   options.Next,
+	}
+	if options.Prefix {
+		listOptions.Prefix = key
+	}
+	if options.Suffix {
+		listOptions.Suffix = key
+	}
+
+	args, err := rangeArgs(listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := s.prepare(options.Database, options.Table, "readMany")
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "sqlStore.read failed")
+	}
+	defer rows.Close()
+
+	var records []*store.Record
+
+	for rows.Next() {
+		record, err := scan(rows)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return records, err
+	}
+
+	if options.Next != nil {
+		*options.Next = ""
+	}
+
+	// the extra record means there's another page
+	if more(options.Limit, len(records)) {
+		records = records[:options.Limit]
+		if options.Next != nil {
+			*options.Next = store.NewCursor(records[len(records)-1].Key)
+		}
+	}
+
+	return records, nil
+}
+
+// Write records
+func (s *sqlStore) Write(r *store.Record, opts ...store.WriteOption) error {
+	var options store.WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var expiry sql.NullInt64
+	// the ttl takes precedence over the expiry from options and the record
+	switch {
+	case options.TTL != 0:
+		expiry.Int64, expiry.Valid = time.Now().Add(options.TTL).UnixNano(), true
+	case !options.Expiry.IsZero():
+		expiry.Int64, expiry.Valid = options.Expiry.UnixNano(), true
+	case r.Expiry != 0:
+		expiry.Int64, expiry.Valid = time.Now().Add(r.Expiry).UnixNano(), true
+	}
+
+	exec := func(query string, args ...interface{}) (sql.Result, error) {
+		st, err := s.prepare(options.Database, options.Table, query)
+		if err != nil {
+			return nil, err
+		}
+		defer st.Close()
+		return st.Exec(args...)
+	}
+
+	return s.put(exec, r, expiry, options.Conditional, options.Version)
+}
+
+// put writes the record with exec, checking the stored version if the write is conditional
+func (s *sqlStore) put(exec func(string, ...interface{}) (sql.Result, error), r *store.Record, expiry sql.NullInt64, conditional bool, version uint64) error {
+	metadata, err := json.Marshal(r.Metadata)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixNano()
+
+	var result sql.Result
+
+	switch {
+	case !conditional:
+		result, err = exec("write", r.Key, r.Value, string(metadata), expiry, now)
+	case version == 0:
+		result, err = exec("writeNew", r.Key, r.Value, string(metadata), expiry, now)
+	default:
+		result, err = exec("writeVersion", r.Key, r.Value, string(metadata), expiry, now, int64(version))
+	}
+	if err != nil {
+		return errors.Wrap(err, "Couldn't insert record "+r.Key)
+	}
+
+	if !conditional {
+		return nil
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrVersionConflict
+	}
+
+	return nil
+}
+
+// remove deletes the record, if conditional only when it's at the version
+func (s *sqlStore) remove(exec func(string, ...interface{}) (sql.Result, error), key string, conditional bool, version uint64) error {
+	if !conditional {
+		if _, err := exec("delete", key); err != nil {
+			return errors.Wrap(err, "Couldn't delete record "+key)
+		}
+		return nil
+	}
+
+	result, err := exec("deleteVersion", key, time.Now().UnixNano(), int64(version))
+	if err != nil {
+		return errors.Wrap(err, "Couldn't delete record "+key)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrVersionConflict
+	}
+
+	return nil
+}
+
+// Delete records with keys
+func (s *sqlStore) Delete(key string, opts ...store.DeleteOption) error {
+	var options store.DeleteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	st, err := s.prepare(options.Database, options.Table, "delete")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(key)
+	return err
+}
+
+// Transact applies the operations within a single sql transaction
+func (s *sqlStore) Transact(ops []store.Operation, opts ...store.TransactOption) error {
+	var options store.TransactOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	db, err := s.createDB(options.Database, options.Table)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// statements are run on the transaction since it holds the only connection
+	exec := func(query string, args ...interface{}) (sql.Result, error) {
+		q, err := s.statement(options.Database, options.Table, query)
+		if err != nil {
+			return nil, err
+		}
+		return tx.Exec(q, args...)
+	}
+
+	for _, op := range ops {
+		if op.Record == nil {
+			if err := s.remove(exec, op.Key, op.Conditional, op.Version); err != nil {
+				tx.Rollback()
+				return err
+			}
+			continue
+		}
+
+		var expiry sql.NullInt64
+		if op.Record.Expiry != 0 {
+			expiry.Int64, expiry.Valid = time.Now().Add(op.Record.Expiry).UnixNano(), true
+		}
+
+		if err := s.put(exec, op.Record, expiry, op.Conditional, op.Version); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) Options() store.Options {
+	return s.options
+}
+
+func (s *sqlStore) String() string {
+	return "sqlite"
+}
+
+// scanner is implemented by sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan a record from the row
+func scan(row scanner) (*store.Record, error) {
+	record := &store.Record{}
+
+	var metadata sql.NullString
+	var expiry sql.NullInt64
+	var version int64
+
+	if err := row.Scan(&record.Key, &record.Value, &metadata, &expiry, &version); err != nil {
+		return nil, err
+	}
+
+	record.Metadata = make(map[string]interface{})
+	if metadata.Valid && len(metadata.String) > 0 {
+		if err := json.Unmarshal([]byte(metadata.String), &record.Metadata); err != nil {
+			return nil, err
+		}
+		if record.Metadata == nil {
+			record.Metadata = make(map[string]interface{})
+		}
+	}
+
+	if expiry.Valid {
+		record.Expiry = time.Until(time.Unix(0, expiry.Int64))
+	}
+
+	record.Version = uint64(version)
+
+	return record, nil
+}
+
+// rangeArgs returns the arguments of a list query for the options. One more
+// than the limit is queried so we know whether there's a next page.
+func rangeArgs(options store.ListOptions) ([]interface{}, error) {
+	var after string
+	if len(options.Cursor) > 0 {
+		var err error
+		if after, err = store.ParseCursor(options.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	// -1 is unlimited
+	limit := int64(-1)
+	if options.Limit != 0 {
+		limit = int64(options.Limit) + 1
+	}
+
+	return []interface{}{
+		escaper.Replace(options.Prefix) + "%",
+		"%" + escaper.Replace(options.Suffix),
+		options.Start,
+		options.End,
+		after,
+		time.Now().UnixNano(),
+		limit,
+		int64(options.Offset),
+	}, nil
+}
+
+// more returns true if the query returned more than the limit
+func more(limit uint, n int) bool {
+	return limit != 0 && n > int(limit)
+}
+
+// NewStore returns a new micro Store backed by sqlite
+func NewStore(opts ...store.Option) store.Store {
+	options := store.Options{
+		Database: DefaultDatabase,
+		Table:    DefaultTable,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// new store
+	s := new(sqlStore)
+	// set the options
+	s.options = options
+	// open databases
+	s.dbs = make(map[string]*sql.DB)
+	// mark known tables
+	s.tables = make(map[string]bool)
+
+	// return store
+	return s
+}
@@ -10,6 +10,8 @@ import (
 var (
 	// ErrNotFound is returned when a key doesn't exist
 	ErrNotFound = errors.New("not found")
+	// ErrVersionConflict is returned when a conditional write finds the stored version has changed
+	ErrVersionConflict = errors.New("version conflict")
 	// DefaultStore is the memory store.
 	DefaultStore Store = new(noopStore)
 )
@@ -34,6 +36,44 @@ type Store interface {
 	String() string
 }
 
+// Transactional is implemented by stores which can apply a set of writes and deletes
+// atomically. Check whether a store supports transactions with a type assertion.
+type Transactional interface {
+	// Transact applies all of the operations to a single database and table, or none of them.
+	Transact(ops []Operation, opts ...TransactOption) error
+}
+
+// Operation is a single write or delete applied as part of a transaction
+type Operation struct {
+	// Record is written to the store if set
+	Record *Record
+	// Key is deleted from the store if Record is nil
+	Key string
+	// Conditional writes and deletes only succeed if the stored record is at Version
+	Conditional bool
+	// Version of the stored record for a conditional write or delete
+	Version uint64
+}
+
+// WriteOp returns an operation which writes the record
+func WriteOp(r *Record) Operation {
+	return Operation{Record: r}
+}
+
+// DeleteOp returns an operation which deletes the key
+func DeleteOp(key string) Operation {
+	return Operation{Key: key}
+}
+
+// IfVersion makes a write or delete conditional on the stored record being at version v.
+// A conditional delete only succeeds if the record exists, so v must be greater than zero.
+// If any condition fails the transaction returns ErrVersionConflict and nothing is applied.
+func (o Operation) IfVersion(v uint64) Operation {
+	o.Conditional = true
+	o.Version = v
+	return o
+}
+
 // Record is an item stored or retrieved from a Store
 type Record struct {
 	// The key to store the record
@@ -44,4 +84,8 @@ type Record struct {
 	Metadata map[string]interface{} `json:"metadata"`
 	// Time to expire a record: TODO: change to timestamp
 	Expiry time.Duration `json:"expiry,omitempty"`
+	// Version of the record, incremented by the store on every write. A record which is
+	// deleted or expires starts again at 1 when it's written, so a version only identifies
+	// a write whilst the record exists.
+	Version uint64 `json:"version,omitempty"`
 }
@@ -0,0 +1,472 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests runs all the tests against a store to ensure the implementations are consistent.
+// Transactions and watching are only tested if the store supports them.
+func Tests(t *testing.T, s Store) {
+	database := s.Options().Database
+
+	// every test uses its own table so they don't interfere with each other
+	var n int
+	newTable := func() string {
+		n++
+		return fmt.Sprintf("tests%d%d", time.Now().UnixNano(), n)
+	}
+
+	// write the keys with their own key as the value
+	write := func(t *testing.T, table string, keys ...string) {
+		for _, k := range keys {
+			err := s.Write(&Record{Key: k, Value: []byte(k)}, WriteTo(database, table))
+			assert.Nil(t, err, "Error writing %s", k)
+		}
+	}
+
+	// read returns the keys of the records read
+	read := func(t *testing.T, key string, opts ...ReadOption) []string {
+		recs, err := s.Read(key, opts...)
+		assert.Nil(t, err, "Error reading %s", key)
+		keys := make([]string, 0, len(recs))
+		for _, r := range recs {
+			keys = append(keys, r.Key)
+		}
+		return keys
+	}
+
+	list := func(t *testing.T, opts ...ListOption) []string {
+		keys, err := s.List(opts...)
+		assert.Nil(t, err, "Error listing keys")
+		if keys == nil {
+			keys = []string{}
+		}
+		return keys
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		t.Run("NotFound", func(t *testing.T) {
+			recs, err := s.Read("missing", ReadFrom(database, newTable()))
+			assert.Equal(t, ErrNotFound, err, "Expected not found")
+			assert.Len(t, recs, 0, "Expected no records")
+		})
+
+		t.Run("Key", func(t *testing.T) {
+			table := newTable()
+			err := s.Write(&Record{
+				Key:      "foo",
+				Value:    []byte("bar"),
+				Metadata: map[string]interface{}{"baz": "qux"},
+			}, WriteTo(database, table))
+			assert.Nil(t, err, "Error writing")
+
+			recs, err := s.Read("foo", ReadFrom(database, table))
+			assert.Nil(t, err, "Error reading")
+			if assert.Len(t, recs, 1, "Expected one record") {
+				assert.Equal(t, "foo", recs[0].Key)
+				assert.Equal(t, []byte("bar"), recs[0].Value)
+				assert.Equal(t, "qux", recs[0].Metadata["baz"], "Expected the metadata to be read")
+				assert.Equal(t, time.Duration(0), recs[0].Expiry, "Expected no expiry")
+			}
+		})
+
+		t.Run("DefaultTable", func(t *testing.T) {
+			key := fmt.Sprintf("default%d", time.Now().UnixNano())
+			err := s.Write(&Record{Key: key, Value: []byte("foo")})
+			assert.Nil(t, err, "Error writing")
+			defer s.Delete(key)
+
+			keys := read(t, key, ReadFrom(database, s.Options().Table))
+			assert.Equal(t, []string{key}, keys, "Expected the record in the default table")
+		})
+
+		t.Run("Table", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "foo")
+
+			_, err := s.Read("foo", ReadFrom(database, newTable()))
+			assert.Equal(t, ErrNotFound, err, "Expected tables to be isolated")
+		})
+
+		t.Run("Prefix", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "b1", "a2", "a1", "ba")
+
+			keys := read(t, "a", ReadFrom(database, table), ReadPrefix())
+			assert.Equal(t, []string{"a1", "a2"}, keys, "Expected the prefixed keys in order")
+		})
+
+		t.Run("Suffix", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "foo", "foobar", "bazbarfoo", "barfoo")
+
+			keys := read(t, "foo", ReadFrom(database, table), ReadSuffix())
+			assert.Equal(t, []string{"barfoo", "bazbarfoo", "foo"}, keys, "Expected the suffixed keys in order")
+		})
+
+		t.Run("PrefixSuffix", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "foo", "foobar", "foobarfoo", "barfoo")
+
+			keys := read(t, "foo", ReadFrom(database, table), ReadPrefix(), ReadSuffix())
+			assert.Equal(t, []string{"foo", "foobarfoo"}, keys, "Expected keys with both the prefix and suffix")
+		})
+
+		t.Run("LimitOffset", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "k4", "k0", "k3", "x1", "k1", "k2")
+
+			keys := read(t, "k", ReadFrom(database, table), ReadPrefix(), ReadLimit(2))
+			assert.Equal(t, []string{"k0", "k1"}, keys, "Expected the first page")
+
+			keys = read(t, "k", ReadFrom(database, table), ReadPrefix(), ReadLimit(2), ReadOffset(2))
+			assert.Equal(t, []string{"k2", "k3"}, keys, "Expected the second page")
+
+			keys = read(t, "k", ReadFrom(database, table), ReadPrefix(), ReadOffset(4))
+			assert.Equal(t, []string{"k4"}, keys, "Expected the remaining keys")
+
+			keys = read(t, "k", ReadFrom(database, table), ReadPrefix(), ReadLimit(2), ReadOffset(10))
+			assert.Len(t, keys, 0, "Expected no keys past the end")
+		})
+
+		t.Run("Range", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "d", "a", "c", "b", "e")
+
+			keys := read(t, "", ReadFrom(database, table), ReadRange("b", "d"))
+			assert.Equal(t, []string{"b", "c"}, keys, "Expected the keys in the range")
+
+			keys = read(t, "", ReadFrom(database, table), ReadRange("c", ""))
+			assert.Equal(t, []string{"c", "d", "e"}, keys, "Expected the keys to the end of the table")
+		})
+
+		t.Run("Cursor", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "k4", "k0", "k3", "x1", "k1", "k2")
+
+			var next string
+			var pages [][]string
+			for {
+				keys := read(t, "k", ReadFrom(database, table), ReadPrefix(), ReadLimit(2), ReadCursor(next), ReadNext(&next))
+				pages = append(pages, keys)
+				if len(next) == 0 || len(pages) > 3 {
+					break
+				}
+			}
+			assert.Equal(t, [][]string{{"k0", "k1"}, {"k2", "k3"}, {"k4"}}, pages, "Expected the pages in order")
+
+			_, err := s.Read("k", ReadFrom(database, table), ReadPrefix(), ReadCursor("!"))
+			assert.Equal(t, ErrInvalidCursor, err, "Expected an invalid cursor")
+		})
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		t.Run("Overwrite", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "foo")
+			err := s.Write(&Record{Key: "foo", Value: []byte("bar")}, WriteTo(database, table))
+			assert.Nil(t, err, "Error writing")
+
+			recs, err := s.Read("foo", ReadFrom(database, table))
+			assert.Nil(t, err, "Error reading")
+			if assert.Len(t, recs, 1, "Expected one record") {
+				assert.Equal(t, []byte("bar"), recs[0].Value, "Expected the value to be overwritten")
+			}
+		})
+
+		expiryTests := []struct {
+			name   string
+			record *Record
+			opts   func() []WriteOption
+		}{
+			{
+				name:   "RecordExpiry",
+				record: &Record{Key: "foo", Expiry: 100 * time.Millisecond},
+			},
+			{
+				name:   "WriteExpiry",
+				record: &Record{Key: "foo"},
+				opts: func() []WriteOption {
+					return []WriteOption{WriteExpiry(time.Now().Add(100 * time.Millisecond))}
+				},
+			},
+			{
+				name:   "WriteTTL",
+				record: &Record{Key: "foo"},
+				opts: func() []WriteOption {
+					return []WriteOption{WriteTTL(100 * time.Millisecond)}
+				},
+			},
+			{
+				name:   "TTLPrecedence",
+				record: &Record{Key: "foo"},
+				opts: func() []WriteOption {
+					return []WriteOption{WriteExpiry(time.Now().Add(time.Hour)), WriteTTL(100 * time.Millisecond)}
+				},
+			},
+		}
+
+		for _, e := range expiryTests {
+			e := e
+			t.Run(e.name, func(t *testing.T) {
+				table := newTable()
+				opts := []WriteOption{WriteTo(database, table)}
+				if e.opts != nil {
+					opts = append(opts, e.opts()...)
+				}
+				err := s.Write(e.record, opts...)
+				assert.Nil(t, err, "Error writing")
+
+				recs, err := s.Read("foo", ReadFrom(database, table))
+				assert.Nil(t, err, "Error reading")
+				if assert.Len(t, recs, 1, "Expected one record") {
+					assert.True(t, recs[0].Expiry > 0 && recs[0].Expiry <= 100*time.Millisecond,
+						"Expected the remaining expiry, got %v", recs[0].Expiry)
+				}
+
+				time.Sleep(150 * time.Millisecond)
+
+				_, err = s.Read("foo", ReadFrom(database, table))
+				assert.Equal(t, ErrNotFound, err, "Expected the record to expire")
+				assert.Len(t, list(t, ListFrom(database, table)), 0, "Expected expired records not to be listed")
+			})
+		}
+
+		t.Run("NoMutation", func(t *testing.T) {
+			r := &Record{Key: "foo", Value: []byte("bar")}
+			err := s.Write(r, WriteTo(database, newTable()), WriteTTL(time.Hour))
+			assert.Nil(t, err, "Error writing")
+			assert.Equal(t, time.Duration(0), r.Expiry, "Expected the record not to be mutated")
+		})
+
+		t.Run("Version", func(t *testing.T) {
+			table := newTable()
+			version := func(key string) uint64 {
+				recs, err := s.Read(key, ReadFrom(database, table))
+				assert.Nil(t, err, "Error reading")
+				if len(recs) == 0 {
+					return 0
+				}
+				return recs[0].Version
+			}
+
+			write(t, table, "foo")
+			write(t, table, "foo")
+			assert.Equal(t, uint64(2), version("foo"), "Expected the version to be incremented")
+
+			err := s.Write(&Record{Key: "foo"}, WriteTo(database, table), WriteIfVersion(1))
+			assert.Equal(t, ErrVersionConflict, err, "Expected a conflict writing an old version")
+
+			err = s.Write(&Record{Key: "foo"}, WriteTo(database, table), WriteIfVersion(2))
+			assert.Nil(t, err, "Error writing the current version")
+			assert.Equal(t, uint64(3), version("foo"), "Expected the version to be incremented")
+
+			err = s.Write(&Record{Key: "bar"}, WriteTo(database, table), WriteIfVersion(0))
+			assert.Nil(t, err, "Error writing a new record")
+
+			err = s.Write(&Record{Key: "bar"}, WriteTo(database, table), WriteIfVersion(0))
+			assert.Equal(t, ErrVersionConflict, err, "Expected a conflict writing a new record which exists")
+
+			// a deleted record is new when it's written again
+			assert.Nil(t, s.Delete("foo", DeleteFrom(database, table)), "Error deleting")
+			err = s.Write(&Record{Key: "foo"}, WriteTo(database, table), WriteIfVersion(0))
+			assert.Nil(t, err, "Error writing a deleted record")
+			assert.Equal(t, uint64(1), version("foo"), "Expected the version to start again")
+		})
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		table := newTable()
+		write(t, table, "foo", "bar")
+
+		assert.Nil(t, s.Delete("foo", DeleteFrom(database, table)), "Error deleting")
+		assert.Nil(t, s.Delete("missing", DeleteFrom(database, table)), "Expected deleting a missing key to succeed")
+
+		_, err := s.Read("foo", ReadFrom(database, table))
+		assert.Equal(t, ErrNotFound, err, "Expected the record to be deleted")
+		assert.Equal(t, []string{"bar"}, list(t, ListFrom(database, table)), "Expected the other record to remain")
+	})
+
+	t.Run("List", func(t *testing.T) {
+		t.Run("Empty", func(t *testing.T) {
+			assert.Len(t, list(t, ListFrom(database, newTable())), 0, "Expected no keys")
+		})
+
+		table := newTable()
+		write(t, table, "foo", "foobar", "barfoo", "bar", "baz")
+
+		listTests := []struct {
+			name   string
+			opts   []ListOption
+			expect []string
+		}{
+			{
+				name:   "Sorted",
+				expect: []string{"bar", "barfoo", "baz", "foo", "foobar"},
+			},
+			{
+				name:   "Prefix",
+				opts:   []ListOption{ListPrefix("foo")},
+				expect: []string{"foo", "foobar"},
+			},
+			{
+				name:   "Suffix",
+				opts:   []ListOption{ListSuffix("foo")},
+				expect: []string{"barfoo", "foo"},
+			},
+			{
+				name:   "PrefixSuffix",
+				opts:   []ListOption{ListPrefix("bar"), ListSuffix("foo")},
+				expect: []string{"barfoo"},
+			},
+			{
+				name:   "Limit",
+				opts:   []ListOption{ListLimit(2)},
+				expect: []string{"bar", "barfoo"},
+			},
+			{
+				name:   "LimitOffset",
+				opts:   []ListOption{ListLimit(2), ListOffset(2)},
+				expect: []string{"baz", "foo"},
+			},
+			{
+				name:   "Offset",
+				opts:   []ListOption{ListOffset(3)},
+				expect: []string{"foo", "foobar"},
+			},
+			{
+				name:   "PrefixLimitOffset",
+				opts:   []ListOption{ListPrefix("ba"), ListLimit(1), ListOffset(1)},
+				expect: []string{"barfoo"},
+			},
+			{
+				name:   "Range",
+				opts:   []ListOption{ListRange("barfoo", "foo")},
+				expect: []string{"barfoo", "baz"},
+			},
+			{
+				name:   "RangeEnd",
+				opts:   []ListOption{ListRange("baz", "")},
+				expect: []string{"baz", "foo", "foobar"},
+			},
+			{
+				name:   "RangePrefix",
+				opts:   []ListOption{ListPrefix("ba"), ListRange("bara", "zzz")},
+				expect: []string{"barfoo", "baz"},
+			},
+			{
+				name:   "Cursor",
+				opts:   []ListOption{ListCursor(NewCursor("baz"))},
+				expect: []string{"foo", "foobar"},
+			},
+			{
+				name:   "CursorLimit",
+				opts:   []ListOption{ListCursor(NewCursor("bar")), ListLimit(2)},
+				expect: []string{"barfoo", "baz"},
+			},
+		}
+
+		t.Run("Next", func(t *testing.T) {
+			var next string
+			keys := list(t, ListFrom(database, table), ListLimit(3), ListNext(&next))
+			assert.Equal(t, []string{"bar", "barfoo", "baz"}, keys)
+			assert.NotEmpty(t, next, "Expected a cursor to the next page")
+
+			keys = list(t, ListFrom(database, table), ListLimit(3), ListCursor(next), ListNext(&next))
+			assert.Equal(t, []string{"foo", "foobar"}, keys)
+			assert.Empty(t, next, "Expected no cursor after the last page")
+
+			keys = list(t, ListFrom(database, table), ListLimit(5), ListNext(&next))
+			assert.Len(t, keys, 5)
+			assert.Empty(t, next, "Expected no cursor when the page ends the keys")
+		})
+
+		for _, l := range listTests {
+			l := l
+			t.Run(l.name, func(t *testing.T) {
+				keys := list(t, append(l.opts, ListFrom(database, table))...)
+				assert.Equal(t, l.expect, keys)
+			})
+		}
+	})
+
+	if tx, ok := s.(Transactional); ok {
+		t.Run("Transact", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "foo")
+
+			err := tx.Transact([]Operation{
+				WriteOp(&Record{Key: "bar", Value: []byte("bar")}),
+				WriteOp(&Record{Key: "baz", Value: []byte("baz")}),
+				DeleteOp("foo"),
+			}, TransactIn(database, table))
+			assert.Nil(t, err, "Error applying the transaction")
+			assert.Equal(t, []string{"bar", "baz"}, list(t, ListFrom(database, table)))
+
+			// a failed condition must not apply any of the transaction
+			err = tx.Transact([]Operation{
+				WriteOp(&Record{Key: "qux"}),
+				DeleteOp("bar"),
+				WriteOp(&Record{Key: "baz"}).IfVersion(5),
+			}, TransactIn(database, table))
+			assert.Equal(t, ErrVersionConflict, err, "Expected a conflict")
+			assert.Equal(t, []string{"bar", "baz"}, list(t, ListFrom(database, table)), "Expected nothing to be applied")
+		})
+
+		t.Run("ConditionalDelete", func(t *testing.T) {
+			table := newTable()
+			write(t, table, "foo")
+			write(t, table, "foo")
+
+			// deletes of a changed or missing record conflict
+			for _, op := range []Operation{DeleteOp("foo").IfVersion(1), DeleteOp("bar").IfVersion(1), DeleteOp("bar").IfVersion(0)} {
+				err := tx.Transact([]Operation{op}, TransactIn(database, table))
+				assert.Equal(t, ErrVersionConflict, err, "Expected a conflict deleting %s at %d", op.Key, op.Version)
+			}
+			assert.Equal(t, []string{"foo"}, list(t, ListFrom(database, table)), "Expected nothing to be deleted")
+
+			err := tx.Transact([]Operation{DeleteOp("foo").IfVersion(2)}, TransactIn(database, table))
+			assert.Nil(t, err, "Error deleting at the current version")
+			assert.Equal(t, []string{}, list(t, ListFrom(database, table)))
+		})
+	}
+
+	if ws, ok := s.(Watchable); ok {
+		t.Run("Watch", func(t *testing.T) {
+			table := newTable()
+
+			w, err := ws.Watch(WatchFrom(database, table), WatchPrefix("foo"))
+			if !assert.Nil(t, err, "Error watching") {
+				return
+			}
+			defer w.Stop()
+
+			write(t, table, "foo", "bar")
+			write(t, newTable(), "foo")
+			write(t, table, "foo")
+			s.Delete("foo", DeleteFrom(database, table))
+			s.Delete("foo", DeleteFrom(database, table))
+
+			for _, typ := range []EventType{Create, Update, Delete} {
+				ev, err := w.Next()
+				if !assert.Nil(t, err, "Error getting the next event") {
+					return
+				}
+				assert.Equal(t, typ, ev.Type, "Unexpected event type")
+				assert.Equal(t, "foo", ev.Key, "Unexpected event key")
+				assert.Equal(t, table, ev.Table, "Unexpected event table")
+				if typ != Delete && assert.NotNil(t, ev.Record, "Expected the record") {
+					assert.Equal(t, []byte("foo"), ev.Record.Value)
+				}
+			}
+
+			w.Stop()
+			_, err = w.Next()
+			assert.Equal(t, ErrWatcherStopped, err, "Expected the watcher to be stopped")
+		})
+	}
+}
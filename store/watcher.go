@@ -0,0 +1,68 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrWatcherStopped is returned when Next is called on a stopped watcher
+	ErrWatcherStopped = errors.New("watcher stopped")
+)
+
+// Watchable is implemented by stores which can stream changes to their records.
+// Check whether a store supports watching with a type assertion. Implementations
+// may drop events for a watcher which falls behind, so watchers should call Next
+// promptly and not rely on seeing every change.
+type Watchable interface {
+	// Watch returns a watcher which streams changes to the store
+	Watch(opts ...WatchOption) (Watcher, error)
+}
+
+// Watcher is an interface that returns changes to records within the store
+type Watcher interface {
+	// Next is a blocking call
+	Next() (*Event, error)
+	// Stop the watcher
+	Stop()
+}
+
+// EventType defines the type of change
+type EventType int
+
+const (
+	// Create is emitted when a new record is written
+	Create EventType = iota
+	// Update is emitted when an existing record is written
+	Update
+	// Delete is emitted when a record is deleted
+	Delete
+)
+
+// String returns human readable event type
+func (t EventType) String() string {
+	switch t {
+	case Create:
+		return "create"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a record in the store
+type Event struct {
+	// Type defines type of event
+	Type EventType
+	// Database and table of the record
+	Database, Table string
+	// Key of the record
+	Key string
+	// Record is the written record, nil for deletes
+	Record *Record
+	// Timestamp is event timestamp
+	Timestamp time.Time
+}
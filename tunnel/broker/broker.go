@@ -3,19 +3,36 @@ package broker
 
 import (
 	"context"
+	"math/rand"
+	"sync"
 
 	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/logger"
 	"github.com/micro/go-micro/v3/transport"
 	"github.com/micro/go-micro/v3/tunnel"
 	"github.com/micro/go-micro/v3/tunnel/mucp"
 )
 
+// WildcardChannel is the channel messages are also published to, with the topic in the
+// wildcardHeader, when there are subscribers to wildcard topics listening on it
+var WildcardChannel = "go.micro.broker.wildcard"
+
+// wildcardHeader is the header of the topic of the messages published to the wildcard
+// channel, it's removed before they're handled
+const wildcardHeader = "Micro-Wildcard-Topic"
+
 type tunBroker struct {
 	opts   broker.Options
 	tunnel tunnel.Tunnel
+
+	sync.RWMutex
+	// subscribers to wildcard topics share the listener on the wildcard channel
+	wildcard  *tunSubscriber
+	wildcards []*tunSubscriber
 }
 
 type tunSubscriber struct {
+	broker  *tunBroker
 	topic   string
 	handler broker.Handler
 	opts    broker.SubscribeOptions
@@ -27,6 +44,7 @@ type tunSubscriber struct {
 type tunEvent struct {
 	topic   string
 	message *broker.Message
+	err     error
 }
 
 // used to access tunnel from options context
@@ -53,13 +71,46 @@ func (t *tunBroker) Connect() error {
 }
 
 func (t *tunBroker) Disconnect() error {
+	t.Lock()
+	if t.wildcard != nil {
+		t.wildcard.Unsubscribe()
+		t.wildcard = nil
+		t.wildcards = nil
+	}
+	t.Unlock()
+
 	return t.tunnel.Close()
 }
 
 func (t *tunBroker) Publish(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+	if err := t.publish(topic, m); err != nil {
+		return err
+	}
+
+	// publish to the wildcard channel with the topic so the wildcard subscribers can match it.
+	// The channel isn't discovered, the message is only sent if the links have announced
+	// a listener on it, so there's no cost without wildcard subscribers.
+	header := make(map[string]string, len(m.Header)+1)
+	for k, v := range m.Header {
+		header[k] = v
+	}
+	header[wildcardHeader] = topic
+
+	err := t.publish(WildcardChannel, &broker.Message{
+		Header: header,
+		Body:   m.Body,
+	}, tunnel.DialDiscover(false))
+	if err == tunnel.ErrDiscoverChan {
+		return nil
+	}
+	return err
+}
+
+func (t *tunBroker) publish(channel string, m *broker.Message, opts ...tunnel.DialOption) error {
 	// TODO: this is probably inefficient, we might want to just maintain an open connection
 	// it may be easier to add broadcast to the tunnel
-	c, err := t.tunnel.Dial(topic, tunnel.DialMode(tunnel.Multicast))
+	opts = append([]tunnel.DialOption{tunnel.DialMode(tunnel.Multicast)}, opts...)
+	c, err := t.tunnel.Dial(channel, opts...)
 	if err != nil {
 		return err
 	}
@@ -72,30 +123,145 @@ func (t *tunBroker) Publish(topic string, m *broker.Message, opts ...broker.Publ
 }
 
 func (t *tunBroker) Subscribe(topic string, h broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
-	l, err := t.tunnel.Listen(topic, tunnel.ListenMode(tunnel.Multicast))
-	if err != nil {
-		return nil, err
-	}
-
 	var options broker.SubscribeOptions
 	for _, o := range opts {
 		o(&options)
 	}
 
 	tunSub := &tunSubscriber{
-		topic:    topic,
-		handler:  h,
-		opts:     options,
-		closed:   make(chan bool),
-		listener: l,
+		broker:  t,
+		topic:   topic,
+		handler: h,
+		opts:    options,
+		closed:  make(chan bool),
 	}
 
+	if broker.IsWildcard(topic) {
+		if err := t.subscribeWildcard(tunSub); err != nil {
+			return nil, err
+		}
+		return tunSub, nil
+	}
+
+	l, err := t.tunnel.Listen(topic, tunnel.ListenMode(tunnel.Multicast))
+	if err != nil {
+		return nil, err
+	}
+	tunSub.listener = l
+
 	// start processing
 	go tunSub.run()
 
 	return tunSub, nil
 }
 
+// subscribeWildcard adds the subscriber to those matched against the messages on the wildcard
+// channel, listening on it if this is the first
+func (t *tunBroker) subscribeWildcard(sub *tunSubscriber) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.wildcard == nil {
+		// announce the listener since it isn't discovered when publishing
+		l, err := t.tunnel.Listen(WildcardChannel, tunnel.ListenMode(tunnel.Multicast), tunnel.ListenAnnounce(true))
+		if err != nil {
+			return err
+		}
+
+		t.wildcard = &tunSubscriber{
+			broker:   t,
+			topic:    WildcardChannel,
+			handler:  t.handleWildcard,
+			closed:   make(chan bool),
+			listener: l,
+		}
+		go t.wildcard.run()
+	}
+
+	t.wildcards = append(t.wildcards, sub)
+	return nil
+}
+
+// unsubscribeWildcard removes the subscriber, closing the listener on the wildcard channel
+// if it was the last
+func (t *tunBroker) unsubscribeWildcard(sub *tunSubscriber) error {
+	t.Lock()
+	defer t.Unlock()
+
+	for i, s := range t.wildcards {
+		if s == sub {
+			t.wildcards = append(t.wildcards[:i], t.wildcards[i+1:]...)
+			break
+		}
+	}
+
+	if len(t.wildcards) > 0 || t.wildcard == nil {
+		return nil
+	}
+
+	err := t.wildcard.Unsubscribe()
+	t.wildcard = nil
+	return err
+}
+
+// handleWildcard passes the message to the wildcard subscribers matching its topic, those
+// without a queue and a member of each queue
+func (t *tunBroker) handleWildcard(e broker.Event) error {
+	msg := e.Message()
+	topic := msg.Header[wildcardHeader]
+	if len(topic) == 0 {
+		return nil
+	}
+
+	header := make(map[string]string, len(msg.Header))
+	for k, v := range msg.Header {
+		if k != wildcardHeader {
+			header[k] = v
+		}
+	}
+
+	t.RLock()
+	var subs []*tunSubscriber
+	queues := make(map[string][]*tunSubscriber)
+	for _, sub := range t.wildcards {
+		if !broker.MatchTopic(sub.topic, topic) {
+			continue
+		}
+		if len(sub.opts.Queue) == 0 {
+			subs = append(subs, sub)
+			continue
+		}
+		name := sub.topic + "/" + sub.opts.Queue
+		queues[name] = append(queues[name], sub)
+	}
+	t.RUnlock()
+
+	for _, members := range queues {
+		subs = append(subs, members[rand.Intn(len(members))])
+	}
+
+	for _, sub := range subs {
+		sub.handle(&tunEvent{
+			topic:   topic,
+			message: &broker.Message{Header: header, Body: msg.Body},
+		})
+	}
+
+	return nil
+}
+
+// handleError passes the event which failed to the ErrorHandler, or logs the error without one
+func (t *tunBroker) handleError(ev *tunEvent, err error) {
+	ev.err = err
+	if eh := t.opts.ErrorHandler; eh != nil {
+		eh(ev)
+		return
+	}
+	if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+		logger.Errorf("[tunnel] failed to handle message on %s: %v", ev.topic, err)
+	}
+}
+
 func (t *tunBroker) String() string {
 	return "tunnel"
 }
@@ -124,7 +290,7 @@ func (t *tunSubscriber) run() {
 		c.Close()
 
 		// handle the message
-		go t.handler(&tunEvent{
+		go t.handle(&tunEvent{
 			topic: t.topic,
 			message: &broker.Message{
 				Header: m.Header,
@@ -134,6 +300,13 @@ func (t *tunSubscriber) run() {
 	}
 }
 
+// handle the event, passing any error to the broker
+func (t *tunSubscriber) handle(ev *tunEvent) {
+	if err := t.handler(ev); err != nil {
+		t.broker.handleError(ev, err)
+	}
+}
+
 func (t *tunSubscriber) Options() broker.SubscribeOptions {
 	return t.opts
 }
@@ -143,6 +316,11 @@ func (t *tunSubscriber) Topic() string {
 }
 
 func (t *tunSubscriber) Unsubscribe() error {
+	// wildcard subscribers don't have their own listener
+	if t.listener == nil {
+		return t.broker.unsubscribeWildcard(t)
+	}
+
 	select {
 	case <-t.closed:
 		return nil
@@ -165,7 +343,7 @@ func (t *tunEvent) Ack() error {
 }
 
 func (t *tunEvent) Error() error {
-	return nil
+	return t.err
 }
 
 func NewBroker(opts ...broker.Option) broker.Broker {
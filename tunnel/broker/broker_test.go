@@ -0,0 +1,100 @@
+package broker
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/broker"
+	"github.com/micro/go-micro/v3/tunnel"
+	"github.com/micro/go-micro/v3/tunnel/mucp"
+)
+
+func TestWildcard(t *testing.T) {
+	// the subscribers are on the server of the tunnel, the publisher is its client
+	tunB := mucp.NewTunnel(
+		tunnel.Address("127.0.0.1:9098"),
+	)
+	tunA := mucp.NewTunnel(
+		tunnel.Address("127.0.0.1:9099"),
+		tunnel.Nodes("127.0.0.1:9098"),
+	)
+
+	failed := make(chan broker.Event, 10)
+	sub := NewBroker(WithTunnel(tunB), broker.ErrorHandler(func(e broker.Event) error {
+		failed <- e
+		return nil
+	}))
+	if err := sub.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer sub.Disconnect()
+
+	pub := NewBroker(WithTunnel(tunA))
+	if err := pub.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer pub.Disconnect()
+
+	var mtx sync.Mutex
+	received := make(map[string][]*broker.Message)
+	handler := func(name string) broker.Handler {
+		return func(e broker.Event) error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			received[name] = append(received[name], e.Message())
+			return nil
+		}
+	}
+
+	// the members of a queue share the messages
+	for _, name := range []string{"a", "b"} {
+		if _, err := sub.Subscribe("orders.*", handler(name), broker.Queue("q")); err != nil {
+			t.Fatalf("Unexpected subscribe error %v", err)
+		}
+	}
+	if _, err := sub.Subscribe("orders.*", handler("c")); err != nil {
+		t.Fatalf("Unexpected subscribe error %v", err)
+	}
+	if _, err := sub.Subscribe("orders.*", func(e broker.Event) error {
+		return errors.New("failed")
+	}); err != nil {
+		t.Fatalf("Unexpected subscribe error %v", err)
+	}
+
+	// wait for the link and the listener on the wildcard channel to be announced
+	time.Sleep(time.Second)
+
+	msg := &broker.Message{Header: map[string]string{"id": "1"}, Body: []byte(`{}`)}
+	if err := pub.Publish("orders.created", msg); err != nil {
+		t.Fatalf("Unexpected publish error %v", err)
+	}
+
+	// errors are passed to the error handler
+	select {
+	case e := <-failed:
+		if e.Error() == nil || e.Topic() != "orders.created" {
+			t.Fatalf("Expected the failed event of orders.created, got %v %v", e.Topic(), e.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the error to be handled")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if len(received["c"]) != 1 {
+		t.Fatalf("Expected the message to be received once, got %d", len(received["c"]))
+	}
+	if n := len(received["a"]) + len(received["b"]); n != 1 {
+		t.Fatalf("Expected the message to be received by one member of the queue, got %d", n)
+	}
+
+	// the internal header isn't seen by the handlers
+	header := received["c"][0].Header
+	if _, ok := header[wildcardHeader]; ok || header["id"] != "1" {
+		t.Fatalf("Expected the header of the published message, got %v", header)
+	}
+}
@@ -1170,8 +1170,9 @@ func (t *tun) Close() error {
 func (t *tun) Dial(channel string, opts ...tunnel.DialOption) (tunnel.Session, error) {
 	// get the options
 	options := tunnel.DialOptions{
-		Timeout: tunnel.DefaultDialTimeout,
-		Wait:    true,
+		Timeout:  tunnel.DefaultDialTimeout,
+		Wait:     true,
+		Discover: true,
 	}
 
 	for _, o := range opts {
@@ -1267,6 +1268,12 @@ func (t *tun) Dial(channel string, opts ...tunnel.DialOption) (tunnel.Session, e
 		}
 	}
 
+	// the channel isn't mapped and we've been asked not to discover it
+	if !c.discovered && !options.Discover {
+		t.delSession(c.channel, c.session)
+		return nil, tunnel.ErrDiscoverChan
+	}
+
 	// if its not already discovered we need to attempt to do so
 	if !c.discovered {
 		// piggy back roundtrip
@@ -1402,6 +1409,15 @@ func (t *tun) Listen(channel string, opts ...tunnel.ListenOption) (tunnel.Listen
 	// to the existign sessions
 	go tl.process()
 
+	// announce the listener so it's mapped by the links without waiting for discovery
+	if options.Announce {
+		t.RLock()
+		for _, link := range t.links {
+			go t.announce(channel, "listener", link)
+		}
+		t.RUnlock()
+	}
+
 	// return the listener
 	return tl, nil
 }
@@ -40,6 +40,8 @@ type DialOptions struct {
 	Mode Mode
 	// Wait for connection to be accepted
 	Wait bool
+	// Discover the channel if it isn't mapped to a link
+	Discover bool
 	// the dial timeout
 	Timeout time.Duration
 }
@@ -51,6 +53,8 @@ type ListenOptions struct {
 	Mode Mode
 	// The read timeout
 	Timeout time.Duration
+	// Announce the listener to the links
+	Announce bool
 }
 
 // The tunnel id
@@ -102,6 +106,14 @@ func ListenTimeout(t time.Duration) ListenOption {
 	}
 }
 
+// ListenAnnounce announces the listener to the links once listening, so it's
+// reached by dials which don't discover the channel, see DialDiscover
+func ListenAnnounce(b bool) ListenOption {
+	return func(o *ListenOptions) {
+		o.Announce = b
+	}
+}
+
 // Dial options
 
 // Dial multicast sets the multicast option to send only to those mapped
@@ -134,6 +146,14 @@ func DialWait(b bool) DialOption {
 	}
 }
 
+// DialDiscover specifies whether to discover the channel if it isn't
+// already mapped to a link, ErrDiscoverChan is returned if not
+func DialDiscover(b bool) DialOption {
+	return func(o *DialOptions) {
+		o.Discover = b
+	}
+}
+
 // DefaultOptions returns router default options
 func DefaultOptions() Options {
 	return Options{
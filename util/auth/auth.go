@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,6 +9,16 @@ import (
 	"github.com/micro/go-micro/v3/logger"
 )
 
+var (
+	// TokenExpiry is how long the tokens generated for the service are valid for
+	TokenExpiry = time.Minute * 10
+	// RefreshWindow is how long before expiring the token is refreshed
+	RefreshWindow = time.Minute
+
+	// refresh tokens can only be used once so refreshes are serialised
+	refreshMtx sync.Mutex
+)
+
 // Verify the auth credentials and refresh the auth token periodically
 func Verify(a auth.Auth) error {
 	// extract the account creds from options, these can be set by flags
@@ -36,7 +47,7 @@ func Verify(a auth.Auth) error {
 	// generate the first token
 	token, err := a.Token(
 		auth.WithCredentials(accID, accSecret),
-		auth.WithExpiry(time.Minute*10),
+		auth.WithExpiry(TokenExpiry),
 	)
 	if err != nil {
 		return err
@@ -55,26 +66,42 @@ func Verify(a auth.Auth) error {
 		for {
 			<-timer.C
 
-			// don't refresh the token if it's not close to expiring
-			tok := a.Options().Token
-			if tok.Expiry.Unix() > time.Now().Add(time.Minute).Unix() {
-				continue
-			}
-
-			// generate the first token
-			tok, err := a.Token(
-				auth.WithToken(tok.RefreshToken),
-				auth.WithExpiry(time.Minute*10),
-			)
-			if err != nil {
+			if _, err := Refresh(a, ""); err != nil {
 				logger.Warnf("[Auth] Error refreshing token: %v", err)
-				continue
 			}
-
-			// set the token
-			a.Init(auth.ClientToken(tok))
 		}
 	}()
 
 	return nil
 }
+
+// Refresh the auth token if it's close to expiring or it's the rejected access token, e.g. when
+// a call returned unauthorized. The current token is returned, or nil if there isn't one.
+func Refresh(a auth.Auth, rejected string) (*auth.Token, error) {
+	refreshMtx.Lock()
+	defer refreshMtx.Unlock()
+
+	opts := a.Options()
+	tok := opts.Token
+	if tok == nil {
+		return nil, nil
+	}
+
+	// don't refresh the token if it's not close to expiring
+	if tok.AccessToken != rejected && tok.Expiry.After(time.Now().Add(RefreshWindow)) {
+		return tok, nil
+	}
+
+	tok, err := a.Token(auth.WithToken(tok.RefreshToken), auth.WithExpiry(TokenExpiry))
+	if err != nil && len(opts.ID) > 0 && len(opts.Secret) > 0 {
+		// the refresh token may have been revoked, login again
+		tok, err = a.Token(auth.WithCredentials(opts.ID, opts.Secret), auth.WithExpiry(TokenExpiry))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// set the token
+	a.Init(auth.ClientToken(tok))
+	return tok, nil
+}
@@ -0,0 +1,235 @@
+// Package limit enforces the rate and concurrency limits of a server
+package limit
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/logger"
+	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/server/health"
+	"github.com/micro/go-micro/v3/store"
+)
+
+// service is the key of the limit of the whole service
+const service = ""
+
+// Limiter enforces the limits of the service, endpoints and subscribers of a server.
+// The counts are kept in memory unless a store is provided, in which case the limits hold
+// across all the instances of the service. The health service is never limited so the
+// server can always be probed.
+type Limiter struct {
+	sync.Mutex
+	name  string
+	store store.Store
+	// limits set using the server options
+	options map[string]server.Limit
+	// limits set using the handler and subscriber options
+	limits   map[string]server.Limit
+	buckets  map[string]*bucket
+	inflight map[string]int
+}
+
+// NewLimiter returns a limiter for the server options
+func NewLimiter(opts server.Options) *Limiter {
+	l := &Limiter{
+		limits:   make(map[string]server.Limit),
+		buckets:  make(map[string]*bucket),
+		inflight: make(map[string]int),
+	}
+	l.Init(opts)
+	return l
+}
+
+// Init updates the limits set using the server options
+func (l *Limiter) Init(opts server.Options) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.name = opts.Name
+	l.store = opts.LimitStore
+	l.options = map[string]server.Limit{service: opts.Limit}
+	for k, v := range opts.Limits {
+		l.options[k] = v
+	}
+}
+
+// Set the limit of an endpoint, server options take precedence
+func (l *Limiter) Set(name string, limit server.Limit) {
+	l.Lock()
+	defer l.Unlock()
+	l.limits[name] = limit
+}
+
+func (l *Limiter) limit(name string) server.Limit {
+	if limit, ok := l.options[name]; ok {
+		return limit
+	}
+	return l.limits[name]
+}
+
+// Acquire a request to the endpoint, returning a func to release it once complete.
+// False is returned if the request would exceed the limits.
+func (l *Limiter) Acquire(name string) (func(), bool) {
+	l.Lock()
+	lim := l.limit(name)
+	l.Unlock()
+	return l.acquireLimits(name, lim)
+}
+
+// subscriber returns the key the counts of the subscriber are kept under and its limit.
+// Subscribers to the same topic and queue share their counts, so the limit of a queue
+// subscriber holds across the instances of the service.
+func (l *Limiter) subscriber(sb server.Subscriber) (string, server.Limit) {
+	key := "subscriber/" + sb.Topic()
+	if q := sb.Options().Queue; len(q) > 0 {
+		key += "/" + q
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if limit, ok := l.options[sb.Topic()]; ok {
+		return key, limit
+	}
+	return key, sb.Options().Limit
+}
+
+// acquireLimits acquires the request under the limits of the service and the name
+func (l *Limiter) acquireLimits(name string, lim server.Limit) (func(), bool) {
+	l.Lock()
+	svc, s := l.limit(service), l.store
+	l.Unlock()
+
+	var releases []func()
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+
+	for _, k := range []string{service, name} {
+		limit := svc
+		if k != service {
+			limit = lim
+		}
+		if limit.Unlimited() {
+			continue
+		}
+
+		var r func()
+		var ok bool
+		if s != nil {
+			r, ok = l.acquireStore(s, k, limit)
+		} else {
+			r, ok = l.acquire(k, limit)
+		}
+		if !ok {
+			release()
+			return nil, false
+		}
+		releases = append(releases, r)
+	}
+
+	return release, true
+}
+
+// acquire the request using the counts in memory
+func (l *Limiter) acquire(name string, limit server.Limit) (func(), bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if limit.Concurrency > 0 && l.inflight[name] >= limit.Concurrency {
+		return nil, false
+	}
+
+	if limit.Rate > 0 {
+		b, ok := l.buckets[name]
+		if !ok {
+			b = &bucket{tokens: float64(burst(limit)), last: time.Now()}
+			l.buckets[name] = b
+		}
+		if !b.take(limit, time.Now()) {
+			return nil, false
+		}
+	}
+
+	if limit.Concurrency <= 0 {
+		return func() {}, true
+	}
+
+	l.inflight[name]++
+	return func() {
+		l.Lock()
+		l.inflight[name]--
+		l.Unlock()
+	}, true
+}
+
+// burst returns the number of requests allowed at once
+func burst(limit server.Limit) int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	return int(math.Max(1, math.Ceil(limit.Rate)))
+}
+
+// bucket is a token bucket refilled at the rate
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) take(limit server.Limit, now time.Time) bool {
+	b.tokens = math.Min(float64(burst(limit)), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// HandlerWrapper rejects the requests, including streams, which exceed the limits
+func (l *Limiter) HandlerWrapper(h server.HandlerFunc) server.HandlerFunc {
+	return func(ctx context.Context, req server.Request, rsp interface{}) error {
+		if strings.HasPrefix(req.Endpoint(), health.Name+".") {
+			return h(ctx, req, rsp)
+		}
+
+		release, ok := l.Acquire(req.Endpoint())
+		if !ok {
+			return errors.New(req.Service(), "Too many requests to "+req.Endpoint(), 429)
+		}
+		defer release()
+		return h(ctx, req, rsp)
+	}
+}
+
+// SubscriberWrapper returns a wrapper which rejects the messages to the subscriber which
+// exceed the limits, they aren't acked so they can be redelivered by the broker
+func (l *Limiter) SubscriberWrapper(sb server.Subscriber) server.SubscriberWrapper {
+	return func(h server.SubscriberFunc) server.SubscriberFunc {
+		return func(ctx context.Context, msg server.Message) error {
+			release, ok := l.acquireLimits(l.subscriber(sb))
+			if !ok {
+				l.Lock()
+				name := l.name
+				l.Unlock()
+				return errors.New(name, "Too many messages for "+sb.Topic(), 429)
+			}
+			defer release()
+			return h(ctx, msg)
+		}
+	}
+}
+
+func logError(err error) {
+	if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+		logger.Errorf("Error enforcing limits, allowing the request: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package limit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/registry"
+	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+type testRequest struct {
+	server.Request
+}
+
+func (r *testRequest) Service() string  { return "go.micro.service.foo" }
+func (r *testRequest) Endpoint() string { return "Foo.Bar" }
+
+type testMessage struct {
+	server.Message
+}
+
+func (m *testMessage) Topic() string { return "foo.events" }
+
+type testSubscriber struct {
+	topic string
+	limit server.Limit
+}
+
+func (s *testSubscriber) Topic() string                   { return s.topic }
+func (s *testSubscriber) Subscriber() interface{}         { return nil }
+func (s *testSubscriber) Endpoints() []*registry.Endpoint { return nil }
+func (s *testSubscriber) Options() server.SubscriberOptions {
+	return server.SubscriberOptions{Limit: s.limit}
+}
+
+func newOptions(opts ...server.Option) server.Options {
+	var options server.Options
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// count returns the number of requests allowed of n
+func count(l *Limiter, name string, n int) int {
+	var allowed int
+	for i := 0; i < n; i++ {
+		if _, ok := l.Acquire(name); ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestLimiter(t *testing.T) {
+	stores := map[string]server.Option{
+		"Memory": func(o *server.Options) {},
+		"Store":  server.LimitStore(memory.NewStore()),
+	}
+
+	for name, opt := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Rate", func(t *testing.T) {
+				opts := newOptions(server.Name("rate"), opt)
+				l := NewLimiter(opts)
+				l.Set("Foo.Bar", server.Limit{Rate: 4, Burst: 2})
+
+				if n := count(l, "Foo.Bar", 5); n != 2 {
+					t.Errorf("Expected the burst of 2 requests to be allowed, got %v", n)
+				}
+				if n := count(l, "Foo.Baz", 5); n != 5 {
+					t.Errorf("Expected other endpoints to be unlimited, got %v", n)
+				}
+
+				// the bucket is refilled at the rate
+				time.Sleep(600 * time.Millisecond)
+				if n := count(l, "Foo.Bar", 5); n != 2 {
+					t.Errorf("Expected the requests to be allowed again, got %v", n)
+				}
+			})
+
+			t.Run("Concurrency", func(t *testing.T) {
+				opts := newOptions(server.Name("concurrency"), opt)
+				l := NewLimiter(opts)
+				l.Set("Foo.Bar", server.Limit{Concurrency: 2})
+
+				r1, ok1 := l.Acquire("Foo.Bar")
+				_, ok2 := l.Acquire("Foo.Bar")
+				_, ok3 := l.Acquire("Foo.Bar")
+				if !ok1 || !ok2 || ok3 {
+					t.Fatalf("Expected 2 requests in flight, got %v %v %v", ok1, ok2, ok3)
+				}
+
+				r1()
+				if _, ok := l.Acquire("Foo.Bar"); !ok {
+					t.Errorf("Expected the released request to allow another")
+				}
+			})
+
+			t.Run("Service", func(t *testing.T) {
+				opts := newOptions(
+					server.Name("service"),
+					server.ServiceLimit(server.Limit{Concurrency: 3}),
+					server.Limits("Foo.Bar", server.Limit{Concurrency: 1}),
+					opt,
+				)
+				l := NewLimiter(opts)
+
+				// the server options take precedence over the handler options
+				l.Set("Foo.Bar", server.Limit{Concurrency: 5})
+
+				if n := count(l, "Foo.Bar", 5); n != 1 {
+					t.Errorf("Expected 1 request to the endpoint, got %v", n)
+				}
+				if n := count(l, "Foo.Baz", 5); n != 2 {
+					t.Errorf("Expected 3 requests to the service, got %v", n+1)
+				}
+			})
+		})
+	}
+}
+
+func TestWrappers(t *testing.T) {
+	l := NewLimiter(newOptions(server.Name("go.micro.service.foo")))
+	l.Set("Foo.Bar", server.Limit{Rate: 1})
+
+	h := l.HandlerWrapper(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		return nil
+	})
+	// the limit applies to the subscriber whatever the topic of the message
+	sub := &testSubscriber{topic: "foo.*", limit: server.Limit{Rate: 1}}
+	s := l.SubscriberWrapper(sub)(func(ctx context.Context, msg server.Message) error {
+		return nil
+	})
+
+	for i, code := range []int32{0, 429} {
+		err := h(context.Background(), &testRequest{}, nil)
+		if code == 0 && err != nil {
+			t.Fatalf("Request %v returned %v error, expected nil", i, err)
+		} else if code != 0 && errors.FromError(err).Code != code {
+			t.Fatalf("Request %v returned %v error, expected %v", i, err, code)
+		}
+
+		err = s(context.Background(), &testMessage{})
+		if code == 0 && err != nil {
+			t.Fatalf("Message %v returned %v error, expected nil", i, err)
+		} else if code != 0 && errors.FromError(err).Code != code {
+			t.Fatalf("Message %v returned %v error, expected %v", i, err, code)
+		}
+	}
+}
+
+type healthRequest struct {
+	server.Request
+}
+
+func (r *healthRequest) Service() string  { return "go.micro.service.foo" }
+func (r *healthRequest) Endpoint() string { return "MicroHealth.Check" }
+
+func TestHealthUnlimited(t *testing.T) {
+	l := NewLimiter(newOptions(
+		server.Name("go.micro.service.foo"),
+		server.ServiceLimit(server.Limit{Concurrency: 1}),
+	))
+
+	release, ok := l.Acquire("Foo.Bar")
+	if !ok {
+		t.Fatal("Expected the request to be allowed")
+	}
+	defer release()
+
+	h := l.HandlerWrapper(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		return nil
+	})
+	if err := h(context.Background(), &healthRequest{}, nil); err != nil {
+		t.Fatalf("Expected the health check to be allowed, got %v", err)
+	}
+}
+
+func TestStoreConcurrency(t *testing.T) {
+	l := NewLimiter(newOptions(server.Name("concurrent"), server.LimitStore(memory.NewStore())))
+	l.Set("Foo.Bar", server.Limit{Concurrency: 5})
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	var allowed int
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := l.Acquire("Foo.Bar"); ok {
+				mtx.Lock()
+				allowed++
+				mtx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed > 5 {
+		t.Errorf("Expected at most 5 requests in flight, got %v", allowed)
+	}
+}
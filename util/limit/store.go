@@ -0,0 +1,157 @@
+package limit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/store"
+)
+
+var (
+	// StorePrefix to isolate the counts in the store
+	StorePrefix = "limits/"
+	// LeaseExpiry is how long a request counts towards the concurrency limit if it's never
+	// released, e.g. because the instance handling it crashed
+	LeaseExpiry = time.Minute
+	// conflictRetries is how many times the counts are retried when updated concurrently
+	conflictRetries = 5
+)
+
+// acquireStore acquires the request using the counts in the store. Errors from the store are
+// logged and the request is allowed so an unavailable store doesn't take down the service.
+func (l *Limiter) acquireStore(s store.Store, name string, limit server.Limit) (func(), bool) {
+	l.Lock()
+	prefix := fmt.Sprintf("%v%v/%v/", StorePrefix, l.name, name)
+	l.Unlock()
+
+	release := func() {}
+
+	// each request in flight holds a lease, the leases are updated together so the
+	// concurrency limit can't be exceeded by requests acquired at the same time
+	if limit.Concurrency > 0 {
+		id := uuid.New().String()
+		key := prefix + "inflight"
+
+		ok, err := updateLeases(s, key, func(leases map[string]time.Time) bool {
+			if len(leases) >= limit.Concurrency {
+				return false
+			}
+			leases[id] = time.Now().Add(LeaseExpiry)
+			return true
+		})
+		if err != nil {
+			logError(err)
+			return release, true
+		} else if !ok {
+			return nil, false
+		}
+
+		release = func() {
+			// a lease which can't be released counts towards the limit until it expires
+			if _, err := updateLeases(s, key, func(leases map[string]time.Time) bool {
+				delete(leases, id)
+				return true
+			}); err != nil {
+				logError(err)
+			}
+		}
+	}
+
+	if limit.Rate <= 0 {
+		return release, true
+	}
+
+	// the rate is enforced by allowing the burst in each window, e.g. with a rate of 10
+	// per second and a burst of 20, 20 requests are allowed every 2 seconds
+	b := burst(limit)
+	window := time.Duration(float64(b) / limit.Rate * float64(time.Second))
+	key := prefix + "rate/" + strconv.FormatInt(time.Now().UnixNano()/int64(window), 10)
+
+	for i := 0; i < conflictRetries; i++ {
+		var count int
+		var version uint64
+
+		recs, err := s.Read(key)
+		if err == nil {
+			version = recs[0].Version
+			count, _ = strconv.Atoi(string(recs[0].Value))
+		} else if err != store.ErrNotFound {
+			logError(err)
+			return release, true
+		}
+
+		if count >= b {
+			release()
+			return nil, false
+		}
+
+		err = s.Write(&store.Record{
+			Key:    key,
+			Value:  []byte(strconv.Itoa(count + 1)),
+			Expiry: window * 2,
+		}, store.WriteIfVersion(version))
+		if err == nil {
+			return release, true
+		} else if err != store.ErrVersionConflict {
+			logError(err)
+			return release, true
+		}
+	}
+
+	// the count is too contended to update, so the limit has been reached
+	release()
+	return nil, false
+}
+
+// updateLeases applies the update to the unexpired leases, writing them back if it returns
+// true. Conflicting updates are retried, and false is returned if they keep conflicting.
+func updateLeases(s store.Store, key string, update func(map[string]time.Time) bool) (bool, error) {
+	for i := 0; i < conflictRetries; i++ {
+		leases := make(map[string]time.Time)
+		var version uint64
+
+		recs, err := s.Read(key)
+		if err == nil {
+			version = recs[0].Version
+			if err := json.Unmarshal(recs[0].Value, &leases); err != nil {
+				return false, err
+			}
+		} else if err != store.ErrNotFound {
+			return false, err
+		}
+
+		// leases of requests which were never released expire
+		now := time.Now()
+		for id, expiry := range leases {
+			if now.After(expiry) {
+				delete(leases, id)
+			}
+		}
+
+		if !update(leases) {
+			return false, nil
+		}
+
+		bytes, err := json.Marshal(leases)
+		if err != nil {
+			return false, err
+		}
+		err = s.Write(&store.Record{
+			Key:    key,
+			Value:  bytes,
+			Expiry: LeaseExpiry,
+		}, store.WriteIfVersion(version))
+		if err == nil {
+			return true, nil
+		} else if err != store.ErrVersionConflict {
+			return false, err
+		}
+	}
+
+	// the leases are too contended to update, so the limit has been reached
+	return false, nil
+}
@@ -1,4 +1,4 @@
-// Package ring provides a simple ring buffer for storing local data
+// Package ring provides a simple ring buffer for storing local data and a consistent hash ring
 package ring
 
 import (
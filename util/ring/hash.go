@@ -0,0 +1,92 @@
+package ring
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultReplicas is the number of points each member has on the hash ring
+var DefaultReplicas = 100
+
+// Hash is a consistent hash ring. Keys are assigned to the member with the next point on the
+// ring, so only the keys of a member are reassigned when it's added or removed.
+type Hash struct {
+	replicas int
+
+	sync.RWMutex
+	points  []uint32
+	members map[uint32]string
+}
+
+// NewHash returns a hash ring of the members
+func NewHash(members ...string) *Hash {
+	h := &Hash{
+		replicas: DefaultReplicas,
+		members:  make(map[uint32]string),
+	}
+	h.Add(members...)
+	return h
+}
+
+func (h *Hash) hash(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
+
+// Add the members to the ring
+func (h *Hash) Add(members ...string) {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, member := range members {
+		for i := 0; i < h.replicas; i++ {
+			point := h.hash(strconv.Itoa(i) + member)
+			if _, ok := h.members[point]; !ok {
+				h.points = append(h.points, point)
+			}
+			h.members[point] = member
+		}
+	}
+
+	sort.Slice(h.points, func(i, j int) bool { return h.points[i] < h.points[j] })
+}
+
+// Remove the members from the ring
+func (h *Hash) Remove(members ...string) {
+	h.Lock()
+	defer h.Unlock()
+
+	remove := make(map[string]bool, len(members))
+	for _, member := range members {
+		remove[member] = true
+	}
+
+	points := h.points[:0]
+	for _, point := range h.points {
+		if remove[h.members[point]] {
+			delete(h.members, point)
+			continue
+		}
+		points = append(points, point)
+	}
+	h.points = points
+}
+
+// Get returns the member the key is assigned to, false if the ring is empty
+func (h *Hash) Get(key string) (string, bool) {
+	h.RLock()
+	defer h.RUnlock()
+
+	if len(h.points) == 0 {
+		return "", false
+	}
+
+	point := h.hash(key)
+	i := sort.Search(len(h.points), func(i int) bool { return h.points[i] >= point })
+	if i == len(h.points) {
+		i = 0
+	}
+
+	return h.members[h.points[i]], true
+}
@@ -0,0 +1,53 @@
+package ring
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	h := NewHash()
+	if _, ok := h.Get("foo"); ok {
+		t.Fatal("expected no member from an empty ring")
+	}
+
+	h.Add("a", "b", "c")
+
+	keys := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		member, ok := h.Get(key)
+		if !ok {
+			t.Fatalf("expected a member for %s", key)
+		}
+		keys[key] = member
+		counts[member]++
+	}
+
+	// every member is assigned some of the keys
+	for _, member := range []string{"a", "b", "c"} {
+		if counts[member] == 0 {
+			t.Fatalf("expected keys assigned to %s", member)
+		}
+	}
+
+	// the same key is always assigned the same member
+	for key, member := range keys {
+		if got, _ := h.Get(key); got != member {
+			t.Fatalf("expected %s assigned to %s got %s", key, member, got)
+		}
+	}
+
+	// only the keys of a removed member are reassigned
+	h.Remove("b")
+	for key, member := range keys {
+		got, _ := h.Get(key)
+		if got == "b" {
+			t.Fatalf("expected %s not assigned to the removed member", key)
+		}
+		if member != "b" && got != member {
+			t.Fatalf("expected %s to stay assigned to %s got %s", key, member, got)
+		}
+	}
+}
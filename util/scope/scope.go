@@ -24,6 +24,16 @@ func (s *Scope) Options() store.Options {
 }
 
 func (s *Scope) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
+	var rops store.ReadOptions
+	for _, o := range opts {
+		o(&rops)
+	}
+
+	// ranges are within the scope
+	if len(rops.Start) > 0 || len(rops.End) > 0 {
+		opts = append(opts, store.ReadRange(s.key(rops.Start), s.end(rops.End)))
+	}
+
 	key = fmt.Sprintf("%v/%v", s.prefix, key)
 	return s.Store.Read(key, opts...)
 }
@@ -47,5 +57,23 @@ func (s *Scope) List(opts ...store.ListOption) ([]string, error) {
 	key := fmt.Sprintf("%v/%v", s.prefix, lops.Prefix)
 	opts = append(opts, store.ListPrefix(key))
 
+	// ranges are within the scope
+	if len(lops.Start) > 0 || len(lops.End) > 0 {
+		opts = append(opts, store.ListRange(s.key(lops.Start), s.end(lops.End)))
+	}
+
 	return s.Store.List(opts...)
 }
+
+// key returns the key within the scope
+func (s *Scope) key(k string) string {
+	return fmt.Sprintf("%v/%v", s.prefix, k)
+}
+
+// end returns the end of a range within the scope, which is unbounded if empty
+func (s *Scope) end(k string) string {
+	if len(k) == 0 {
+		return ""
+	}
+	return s.key(k)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	"github.com/micro/go-micro/v3/auth"
 	"github.com/micro/go-micro/v3/util/token"
 )
@@ -51,8 +52,11 @@ func (j *JWT) Generate(acc *auth.Account, opts ...token.GenerateOption) (*token.
 	expiry := time.Now().Add(options.Expiry)
 	t := jwt.NewWithClaims(jwt.SigningMethodRS256, authClaims{
 		acc.Type, acc.Scopes, acc.Metadata, jwt.StandardClaims{
+			// the id makes every token unique so they can be revoked individually
+			Id:        uuid.New().String(),
 			Subject:   acc.ID,
 			Issuer:    acc.Issuer,
+			IssuedAt:  time.Now().Unix(),
 			ExpiresAt: expiry.Unix(),
 		},
 	})
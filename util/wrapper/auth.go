@@ -0,0 +1,87 @@
+// Package wrapper provides server wrappers shared by the server implementations
+package wrapper
+
+import (
+	"context"
+	"strings"
+
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/metadata"
+	"github.com/micro/go-micro/v3/server"
+)
+
+// AuthHandler returns a handler wrapper which inspects the bearer token or API key in the
+// request metadata and verifies the account has access to the service endpoint. Streams
+// are wrapped by the handler wrappers so they're verified when opened. The account is
+// added to the context using auth.ContextWithAccount. Requests are verified in the
+// namespace of the service, which is the issuer of the auth options.
+func AuthHandler(a auth.Auth) server.HandlerWrapper {
+	return func(h server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			res := &auth.Resource{Type: "service", Name: req.Service(), Endpoint: req.Endpoint()}
+
+			ctx, err := authenticate(ctx, a, req.Service(), res)
+			if err != nil {
+				return err
+			}
+			return h(ctx, req, rsp)
+		}
+	}
+}
+
+// AuthSubscriber returns a subscriber wrapper which verifies the account which published the
+// message has access to the topic, using the topic as the endpoint of the service.
+func AuthSubscriber(a auth.Auth, service string) server.SubscriberWrapper {
+	return func(h server.SubscriberFunc) server.SubscriberFunc {
+		return func(ctx context.Context, msg server.Message) error {
+			res := &auth.Resource{Type: "service", Name: service, Endpoint: msg.Topic()}
+
+			ctx, err := authenticate(ctx, a, service, res)
+			if err != nil {
+				return err
+			}
+			return h(ctx, msg)
+		}
+	}
+}
+
+// token returns the bearer token or API key in the metadata
+func token(ctx context.Context) string {
+	if key, ok := metadata.Get(ctx, auth.KeyHeader); ok && len(key) > 0 {
+		return key
+	}
+	if h, ok := metadata.Get(ctx, "Authorization"); ok && strings.HasPrefix(h, auth.BearerScheme) {
+		return strings.TrimPrefix(h, auth.BearerScheme)
+	}
+	return ""
+}
+
+// authenticate inspects the token in the context and verifies access to the resource
+func authenticate(ctx context.Context, a auth.Auth, id string, res *auth.Resource) (context.Context, error) {
+	var acc *auth.Account
+	if tok := token(ctx); len(tok) > 0 {
+		var err error
+		if acc, err = a.Inspect(tok); err != nil {
+			return ctx, errors.Unauthorized(id, "Invalid token or API key")
+		}
+		ctx = auth.ContextWithAccount(ctx, acc)
+	}
+
+	// the namespace is never taken from the request, or callers could choose the rules
+	ns := a.Options().Issuer
+	if len(ns) == 0 {
+		ns = auth.DefaultNamespace
+	}
+
+	switch err := a.Verify(acc, res, auth.VerifyNamespace(ns), auth.VerifyContext(ctx)); {
+	case err == nil:
+		return ctx, nil
+	case err != auth.ErrForbidden:
+		return ctx, errors.InternalServerError(id, "Error verifying access to %v: %v", res.Endpoint, err)
+	case acc == nil:
+		return ctx, errors.Unauthorized(id, "Unauthorized call to %v", res.Endpoint)
+	default:
+		return ctx, errors.Forbidden(id, "Forbidden call to %v", res.Endpoint)
+	}
+}
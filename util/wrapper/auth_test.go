@@ -0,0 +1,140 @@
+package wrapper
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/auth/jwt"
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/metadata"
+	"github.com/micro/go-micro/v3/server"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+type testRequest struct {
+	server.Request
+	stream bool
+}
+
+func (r *testRequest) Service() string  { return "go.micro.service.foo" }
+func (r *testRequest) Endpoint() string { return "Foo.Bar" }
+func (r *testRequest) Stream() bool     { return r.stream }
+
+type testMessage struct {
+	server.Message
+}
+
+func (m *testMessage) Topic() string { return "foo.events" }
+
+func TestAuth(t *testing.T) {
+	pubKey, err := ioutil.ReadFile("../token/jwt/test/sample_key.pub")
+	if err != nil {
+		t.Fatalf("Unable to read public key: %v", err)
+	}
+	privKey, err := ioutil.ReadFile("../token/jwt/test/sample_key")
+	if err != nil {
+		t.Fatalf("Unable to read private key: %v", err)
+	}
+
+	a := jwt.NewAuth(
+		auth.Store(memory.NewStore()),
+		auth.PublicKey(string(pubKey)),
+		auth.PrivateKey(string(privKey)),
+	)
+	if err := a.Grant(&auth.Rule{
+		ID:       "admin",
+		Scope:    "admin",
+		Access:   auth.AccessGranted,
+		Resource: &auth.Resource{Type: "service", Name: "go.micro.service.foo", Endpoint: "*"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	admin, err := a.Generate("admin", auth.WithScopes("admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := a.Token(auth.WithCredentials(admin.ID, admin.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := a.Generate("user", auth.WithScopes("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := a.(auth.KeyManager).GenerateKey(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant, err := a.Generate("tenant", auth.WithScopes("admin"), auth.WithIssuer("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantTok, err := a.Token(auth.WithCredentials(tenant.ID, tenant.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var account *auth.Account
+	handler := AuthHandler(a)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		account, _ = auth.AccountFromContext(ctx)
+		return nil
+	})
+	subscriber := AuthSubscriber(a, "go.micro.service.foo")(func(ctx context.Context, msg server.Message) error {
+		account, _ = auth.AccountFromContext(ctx)
+		return nil
+	})
+
+	tt := []struct {
+		Name     string
+		Metadata map[string]string
+		Account  string
+		Code     int32
+	}{
+		{Name: "NoToken", Code: 401},
+		{Name: "InvalidToken", Metadata: map[string]string{"Authorization": auth.BearerScheme + "foo"}, Code: 401},
+		{Name: "Token", Metadata: map[string]string{"Authorization": auth.BearerScheme + tok.AccessToken}, Account: "admin"},
+		{Name: "Forbidden", Metadata: map[string]string{auth.KeyHeader: key.Token}, Code: 403},
+		{Name: "OtherNamespace", Metadata: map[string]string{"Authorization": auth.BearerScheme + tenantTok.AccessToken}, Code: 403},
+		{Name: "NamespaceHeader", Metadata: map[string]string{
+			"Authorization":   auth.BearerScheme + tenantTok.AccessToken,
+			"Micro-Namespace": "foo",
+		}, Code: 403},
+	}
+
+	calls := map[string]func(ctx context.Context) error{
+		"Handler": func(ctx context.Context) error {
+			return handler(ctx, &testRequest{}, nil)
+		},
+		"Stream": func(ctx context.Context) error {
+			return handler(ctx, &testRequest{stream: true}, nil)
+		},
+		"Subscriber": func(ctx context.Context) error {
+			return subscriber(ctx, &testMessage{})
+		},
+	}
+
+	for name, call := range calls {
+		for _, tc := range tt {
+			t.Run(name+tc.Name, func(t *testing.T) {
+				account = nil
+
+				err := call(metadata.NewContext(context.Background(), tc.Metadata))
+				if tc.Code == 0 && err != nil {
+					t.Fatalf("Expected nil error but got %v", err)
+				} else if tc.Code != 0 {
+					if verr, ok := err.(*errors.Error); !ok || verr.Code != tc.Code {
+						t.Fatalf("Expected %v error but got %v", tc.Code, err)
+					}
+					return
+				}
+
+				if account == nil || account.ID != tc.Account {
+					t.Errorf("Expected the account %v in the context, got %v", tc.Account, account)
+				}
+			})
+		}
+	}
+}
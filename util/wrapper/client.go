@@ -0,0 +1,81 @@
+package wrapper
+
+import (
+	"context"
+
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/metadata"
+	authutil "github.com/micro/go-micro/v3/util/auth"
+)
+
+// AuthClient returns a client wrapper which sets the service's auth token as the authorization
+// header of outbound calls, streams and publications. A token is refreshed before it expires and
+// requests which are rejected as unauthorized are retried once with a refreshed token. Requests
+// which already have an authorization header keep it unless the WithAuthToken call option is used.
+func AuthClient(a auth.Auth) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &authClient{Client: c, auth: a}
+	}
+}
+
+type authClient struct {
+	client.Client
+	auth auth.Auth
+}
+
+func (a *authClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return a.do(ctx, override(opts), func(ctx context.Context) error {
+		return a.Client.Call(ctx, req, rsp, opts...)
+	})
+}
+
+func (a *authClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	var stream client.Stream
+	err := a.do(ctx, override(opts), func(ctx context.Context) error {
+		var err error
+		stream, err = a.Client.Stream(ctx, req, opts...)
+		return err
+	})
+	return stream, err
+}
+
+func (a *authClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	return a.do(ctx, false, func(ctx context.Context) error {
+		return a.Client.Publish(ctx, msg, opts...)
+	})
+}
+
+// override returns true if the call options override the authorization header
+func override(opts []client.CallOption) bool {
+	var options client.CallOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	return options.AuthToken
+}
+
+// do the request with the auth token, retrying once with a refreshed token if it's unauthorized
+func (a *authClient) do(ctx context.Context, override bool, fn func(ctx context.Context) error) error {
+	if _, ok := metadata.Get(ctx, "Authorization"); ok && !override {
+		return fn(ctx)
+	}
+
+	tok, err := authutil.Refresh(a.auth, "")
+	if err != nil || tok == nil {
+		// make the request without a token, it may not need one
+		return fn(ctx)
+	}
+
+	err = fn(metadata.Set(ctx, "Authorization", auth.BearerScheme+tok.AccessToken))
+	if err == nil || errors.FromError(err).Code != 401 {
+		return err
+	}
+
+	retry, rerr := authutil.Refresh(a.auth, tok.AccessToken)
+	if rerr != nil || retry == nil || retry.AccessToken == tok.AccessToken {
+		return err
+	}
+	return fn(metadata.Set(ctx, "Authorization", auth.BearerScheme+retry.AccessToken))
+}
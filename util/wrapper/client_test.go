@@ -0,0 +1,141 @@
+package wrapper
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v3/auth"
+	"github.com/micro/go-micro/v3/auth/jwt"
+	"github.com/micro/go-micro/v3/client"
+	"github.com/micro/go-micro/v3/errors"
+	"github.com/micro/go-micro/v3/metadata"
+	"github.com/micro/go-micro/v3/store/memory"
+)
+
+// testClient records the authorization headers and rejects the rejected token
+type testClient struct {
+	client.Client
+	headers  []string
+	rejected string
+}
+
+func (c *testClient) do(ctx context.Context) error {
+	h, _ := metadata.Get(ctx, "Authorization")
+	c.headers = append(c.headers, h)
+	if len(c.rejected) > 0 && h == auth.BearerScheme+c.rejected {
+		return errors.Unauthorized("go.micro.service.foo", "Invalid token")
+	}
+	return nil
+}
+
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return c.do(ctx)
+}
+
+func (c *testClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return nil, c.do(ctx)
+}
+
+func (c *testClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	return c.do(ctx)
+}
+
+func TestAuthClient(t *testing.T) {
+	pubKey, err := ioutil.ReadFile("../token/jwt/test/sample_key.pub")
+	if err != nil {
+		t.Fatalf("Unable to read public key: %v", err)
+	}
+	privKey, err := ioutil.ReadFile("../token/jwt/test/sample_key")
+	if err != nil {
+		t.Fatalf("Unable to read private key: %v", err)
+	}
+
+	a := jwt.NewAuth(
+		auth.Store(memory.NewStore()),
+		auth.PublicKey(string(pubKey)),
+		auth.PrivateKey(string(privKey)),
+	)
+	acc, err := a.Generate("service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := func() *auth.Token {
+		tok, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret), auth.WithExpiry(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Init(auth.ClientToken(tok))
+		return tok
+	}
+
+	calls := map[string]func(c client.Client, ctx context.Context, opts ...client.CallOption) error{
+		"Call": func(c client.Client, ctx context.Context, opts ...client.CallOption) error {
+			return c.Call(ctx, nil, nil, opts...)
+		},
+		"Stream": func(c client.Client, ctx context.Context, opts ...client.CallOption) error {
+			_, err := c.Stream(ctx, nil, opts...)
+			return err
+		},
+		"Publish": func(c client.Client, ctx context.Context, opts ...client.CallOption) error {
+			return c.Publish(ctx, nil)
+		},
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			tc := &testClient{}
+			c := AuthClient(a)(tc)
+
+			// the token is injected
+			tok := login()
+			if err := call(c, context.Background()); err != nil {
+				t.Fatalf("Expected nil error, got %v", err)
+			}
+			if tc.headers[0] != auth.BearerScheme+tok.AccessToken {
+				t.Errorf("Expected the token to be injected, got %v", tc.headers[0])
+			}
+
+			// existing authorization headers are kept
+			ctx := metadata.Set(context.Background(), "Authorization", auth.BearerScheme+"foo")
+			if err := call(c, ctx); err != nil {
+				t.Fatalf("Expected nil error, got %v", err)
+			}
+			if tc.headers[1] != auth.BearerScheme+"foo" {
+				t.Errorf("Expected the header to be kept, got %v", tc.headers[1])
+			}
+
+			// tokens close to expiry are refreshed
+			tok.Expiry = time.Now().Add(time.Second)
+			a.Init(auth.ClientToken(tok))
+			if err := call(c, context.Background()); err != nil {
+				t.Fatalf("Expected nil error, got %v", err)
+			}
+			if tc.headers[2] == auth.BearerScheme+tok.AccessToken {
+				t.Errorf("Expected the token to be refreshed")
+			}
+
+			// unauthorized requests are retried once with a new token
+			tc.headers = nil
+			tc.rejected = login().AccessToken
+			if err := call(c, context.Background()); err != nil {
+				t.Fatalf("Expected nil error, got %v", err)
+			}
+			if len(tc.headers) != 2 || tc.headers[1] == auth.BearerScheme+tc.rejected {
+				t.Errorf("Expected the request to be retried with a new token, got %v", tc.headers)
+			}
+		})
+	}
+
+	// the auth token option overrides the header
+	tc := &testClient{}
+	tok := login()
+	ctx := metadata.Set(context.Background(), "Authorization", auth.BearerScheme+"foo")
+	if err := AuthClient(a)(tc).Call(ctx, nil, nil, client.WithAuthToken()); err != nil {
+		t.Fatalf("Expected nil error, got %v", err)
+	}
+	if tc.headers[0] != auth.BearerScheme+tok.AccessToken {
+		t.Errorf("Expected the header to be overridden, got %v", tc.headers[0])
+	}
+}